@@ -1,14 +1,13 @@
 package main
 
 import (
-	"bytes"
-	"context"
 	"database/sql"
+	"flag"
 	"fmt"
 	"log"
-	"text/template"
-	"time"
+	"os"
 
+	"github.com/davidkuda/bellevue/internal/email"
 	"github.com/davidkuda/bellevue/internal/envcfg"
 	"github.com/davidkuda/bellevue/internal/models"
 	"github.com/davidkuda/bellevue/internal/viewmodels"
@@ -18,128 +17,63 @@ type application struct {
 	db         *sql.DB
 	models     models.Models
 	viewmodels viewmodels.Models
-	templates  *template.Template
-	config     config
+	config     email.EmailConfig
+	mailer     email.Mailer
 }
 
 func main() {
-	log.Println("starting invoice and email flow")
-
-	app := newApplication()
-	defer app.db.Close()
-
-	users, err := app.models.Users.GetAllWithUninvoicedActivities()
+	modeFlag := flag.String("mode", modeBeforeCurrentMonth, "what to invoice: all, month=YYYY-MM, range=FROM..TO (e.g. 2026-01..2026-03) or before-current-month")
+	userFlag := flag.Int("user", 0, "only invoice the user with this ID, the billing contact for a household")
+	emailFlag := flag.String("email", "", "only invoice the user with this email address")
+	dryRun := flag.Bool("dry-run", false, "roll back every invoice and write the emails to -out instead of sending them")
+	out := flag.String("out", "preview", "dry-run output: a directory, or an mbox file if it ends in .mbox")
+	flag.Parse()
+
+	m, err := parseMode(*modeFlag)
 	if err != nil {
-		log.Fatalf("failed fetching users from DB: %v", err)
+		log.Fatal(err)
 	}
+	f := userFilter{ID: *userFlag, Email: *emailFlag}
 
-	for _, user := range users {
-
-		if app.config.TestEmail != "" {
-			if user.Email != app.config.TestEmail {
-				continue
-			}
-		}
-
-		log.Printf("starting invoicing flow for user id=%d email=%s\n", user.ID, user.Email)
-
-		numUninvoicedActivities, err := app.models.Activities.CountUninvoicedActivitiesForUser(user.ID)
-		if err != nil {
-			log.Fatalf("could not count activities: %s\n", err)
-		}
-		if numUninvoicedActivities == 0 {
-			// TODO: maybe I can send a reminder here to advertise for this app?
-			log.Println("no consumptions, skipping")
-			continue
-		}
-
-		log.Printf("sending invoice with %d activities to %s (userID=%d)\n", numUninvoicedActivities, user.Email, user.ID)
-
-		ctx := context.TODO()
-		tx, err := app.db.BeginTx(ctx, nil)
-		if err != nil {
-			log.Fatalf("failed starting transaction: %v\n", err)
-			return
-		}
-		defer tx.Rollback()
-
-		invoice, err := app.models.InvoicesV2.NewInvoiceTx(user.ID, tx)
-		if err != nil {
-			log.Fatalf("could not create a new invoice user.ID=%d: %s\n", user.ID, err)
-		}
-
-		// Comment in/out one of the next blocks:
-
-		// Either: Assign all uninvoiced activities:
-		// N, err := app.models.InvoicesV2.AssignAllOpenActivitiesToInvoiceTx(user.ID, invoice.ID, tx)
-
-		// Or: Assign activities by month:
-		// MONTH := time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)
-		// N, err := app.models.InvoicesV2.AssignOpenActivitiesByMonthToInvoiceForUserTx(
-		// 	MONTH, user.ID, invoice.ID, tx,
-		// )
-
-		// Or: Assign activities by range (Q1 2026):
-		// start := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
-		// end := time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)
-		// N, err := app.models.InvoicesV2.AssignOpenActivitiesByRangeToInvoiceForUserTx(
-		// 	start, end, user.ID, invoice.ID, tx,
-		// )
-
-		// Or: Assign all open activites prior to current month
-		N, err := app.models.InvoicesV2.AssignOpenActivitiesBeforeCurrentMonthForUserTx(
-			user.ID, invoice.ID, tx,
-		)
-
-		app.config.EmailSubject = "Deine Rechnung für den letzten Monat im Bellevue"
-
-		if err != nil {
-			log.Fatalf("could not assign activities to invoice userID=%d invoiceID=%d: %s\n", user.ID, invoice.ID, err)
-		}
-
-		log.Printf("number of invoiced activities for %s: %d\n", user.Email, N)
-		if N == 0 {
-			log.Fatalln("no activities, skipping")
-			// log.Println("no activities, skipping")
-			// continue
-		}
+	log.Printf("starting invoice and email flow, mode=%s\n", *modeFlag)
 
-		tx.Commit()
+	app := newApplication()
+	defer app.db.Close()
 
-		viewInvoice, err := app.viewmodels.Activities.GetInvoiceForUser(invoice.ID, user.ID)
-		if viewInvoice == nil {
-			log.Fatal("for this to work, you need an invoice...")
-		}
+	// EMAIL_SUBJECT overrides the subject of the mode.
+	if app.config.EmailSubject == "" {
+		app.config.EmailSubject = m.subject()
+	}
 
-		data := newTemplateData(
-			app.config, &user, &invoice, viewInvoice,
-		)
-		var buf bytes.Buffer
-		if err := app.templates.ExecuteTemplate(&buf, "email", data); err != nil {
+	if *dryRun {
+		if err := app.dryRun(m, f, *out); err != nil {
 			log.Fatal(err)
 		}
+		log.Printf("dry run: nothing was sent or saved, see %s\n", *out)
+		return
+	}
 
-		em := email{
-			from:    app.config.SMTP.User,
-			to:      []string{user.Email},
-			subject: data.Subject,
-			body:    buf.Bytes(),
-			// body:    normalizeCRLF(buf.Bytes()),
-		}
-
-		// fmt.Println(buf.String())
-
-		if err := sendViaImplicitTLS(app.config, em); err != nil {
-			log.Fatal(err)
-		}
+	res, err := app.run(*modeFlag, m, f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("invoice run finished: sent %d, failed %d\n", res.Sent, res.Failed)
+	if res.Failed > 0 {
+		// the failed invoices are retried by the next run.
+		os.Exit(1)
 	}
 }
 
 func newApplication() application {
 	app := application{}
 
-	cfg := loadConfigFromEnv()
-	app.config = cfg
+	app.config = email.LoadConfigFromEnv()
+
+	mailer, err := email.NewMailer(app.config)
+	if err != nil {
+		log.Fatalf("could not initialise mailer: %v\n", err)
+	}
+	app.mailer = mailer
 
 	db, err := envcfg.DB()
 	if err != nil {
@@ -153,24 +87,6 @@ func newApplication() application {
 	vm := viewmodels.New(db)
 	app.viewmodels = vm
 
-	funcs := template.FuncMap{
-		"fmtCHF":  formatCurrency,
-		"fmtDate": formatDate,
-	}
-
-	// Parse template file
-	files := []string{
-		"email.tmpl",
-		"email.txt.tmpl",
-		"email.html.tmpl",
-	}
-	tmpl := template.New("email").Funcs(funcs)
-	t, err := tmpl.ParseFiles(files...)
-	if err != nil {
-		log.Fatal(err)
-	}
-	app.templates = t
-
 	return app
 }
 
@@ -178,7 +94,3 @@ func newApplication() application {
 func formatCurrency(value int) string {
 	return fmt.Sprintf("%.2f", float64(value)/100)
 }
-
-func formatDate(t time.Time) string {
-	return t.Format("2.01.2006")
-}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/davidkuda/bellevue/internal/models"
+)
+
+// invoicing modes, see -mode:
+const (
+	modeAll                = "all"
+	modeMonth              = "month"
+	modeRange              = "range"
+	modeBeforeCurrentMonth = "before-current-month"
+)
+
+// mode selects which open activities go on the invoice.
+type mode struct {
+	Kind string
+	// From and Until are the first day of the first month and the first day
+	// after the last month, only set for month and range.
+	From  time.Time
+	Until time.Time
+}
+
+// parseMode parses the -mode flag:
+//
+//	all
+//	month=2026-03
+//	range=2026-01..2026-03 (both months included)
+//	before-current-month
+func parseMode(s string) (mode, error) {
+	kind, value, _ := strings.Cut(s, "=")
+
+	switch kind {
+	case modeAll, modeBeforeCurrentMonth:
+		if value != "" {
+			return mode{}, fmt.Errorf("mode %s takes no value", kind)
+		}
+		return mode{Kind: kind}, nil
+
+	case modeMonth:
+		month, err := parseMonth(value)
+		if err != nil {
+			return mode{}, err
+		}
+		return mode{Kind: kind, From: month, Until: month.AddDate(0, 1, 0)}, nil
+
+	case modeRange:
+		from, until, ok := strings.Cut(value, "..")
+		if !ok {
+			return mode{}, fmt.Errorf("invalid range %q, expected FROM..TO, e.g. 2026-01..2026-03", value)
+		}
+		start, err := parseMonth(from)
+		if err != nil {
+			return mode{}, err
+		}
+		end, err := parseMonth(until)
+		if err != nil {
+			return mode{}, err
+		}
+		if end.Before(start) {
+			return mode{}, fmt.Errorf("invalid range %q, %s is before %s", value, until, from)
+		}
+		return mode{Kind: kind, From: start, Until: end.AddDate(0, 1, 0)}, nil
+	}
+
+	return mode{}, fmt.Errorf("invalid mode %q, expected all, month=YYYY-MM, range=FROM..TO or before-current-month", s)
+}
+
+func parseMonth(s string) (time.Time, error) {
+	month, err := time.Parse("2006-01", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid month %q, expected YYYY-MM", s)
+	}
+	return month, nil
+}
+
+// assignTx assigns the open activities of the user that fall into the mode to
+// the invoice and returns how many there were.
+func (m mode) assignTx(invoices *models.InvoiceV2Model, userID, invoiceID int, tx *sql.Tx) (int, error) {
+	switch m.Kind {
+	case modeAll:
+		return invoices.AssignAllOpenActivitiesToInvoiceTx(userID, invoiceID, tx)
+	case modeMonth:
+		return invoices.AssignOpenActivitiesByMonthToInvoiceForUserTx(m.From, userID, invoiceID, tx)
+	case modeRange:
+		return invoices.AssignOpenActivitiesByRangeToInvoiceForUserTx(m.From, m.Until, userID, invoiceID, tx)
+	case modeBeforeCurrentMonth:
+		return invoices.AssignOpenActivitiesBeforeCurrentMonthForUserTx(userID, invoiceID, tx)
+	}
+	return 0, fmt.Errorf("invalid mode %q", m.Kind)
+}
+
+var monthNames = [...]string{
+	"Januar", "Februar", "März", "April", "Mai", "Juni",
+	"Juli", "August", "September", "Oktober", "November", "Dezember",
+}
+
+func monthName(t time.Time) string {
+	return fmt.Sprintf("%s %d", monthNames[t.Month()-1], t.Year())
+}
+
+// subject is the email subject for the mode, e.g.
+// "Deine Rechnung für März 2026 im Bellevue".
+func (m mode) subject() string {
+	switch m.Kind {
+	case modeMonth:
+		return fmt.Sprintf("Deine Rechnung für %s im Bellevue", monthName(m.From))
+	case modeRange:
+		last := m.Until.AddDate(0, -1, 0)
+		if last.Equal(m.From) {
+			return fmt.Sprintf("Deine Rechnung für %s im Bellevue", monthName(m.From))
+		}
+		return fmt.Sprintf("Deine Rechnung für %s bis %s im Bellevue", monthName(m.From), monthName(last))
+	case modeBeforeCurrentMonth:
+		return "Deine Rechnung für den letzten Monat im Bellevue"
+	}
+	return "Deine Rechnung vom Bellevue"
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseMode(t *testing.T) {
+	month := func(year int, m time.Month) time.Time {
+		return time.Date(year, m, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		in      string
+		want    mode
+		subject string
+	}{
+		{"all", mode{Kind: modeAll}, "Deine Rechnung vom Bellevue"},
+		{"before-current-month", mode{Kind: modeBeforeCurrentMonth}, "Deine Rechnung für den letzten Monat im Bellevue"},
+		{"month=2026-03", mode{Kind: modeMonth, From: month(2026, time.March), Until: month(2026, time.April)}, "Deine Rechnung für März 2026 im Bellevue"},
+		{"month=2025-12", mode{Kind: modeMonth, From: month(2025, time.December), Until: month(2026, time.January)}, "Deine Rechnung für Dezember 2025 im Bellevue"},
+		{"range=2026-01..2026-03", mode{Kind: modeRange, From: month(2026, time.January), Until: month(2026, time.April)}, "Deine Rechnung für Januar 2026 bis März 2026 im Bellevue"},
+		{"range=2026-02..2026-02", mode{Kind: modeRange, From: month(2026, time.February), Until: month(2026, time.March)}, "Deine Rechnung für Februar 2026 im Bellevue"},
+	}
+
+	for _, tt := range tests {
+		got, err := parseMode(tt.in)
+		if err != nil {
+			t.Errorf("parseMode(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseMode(%q): expected %+v, got %+v", tt.in, tt.want, got)
+		}
+		if s := got.subject(); s != tt.subject {
+			t.Errorf("parseMode(%q).subject(): expected %q, got %q", tt.in, tt.subject, s)
+		}
+	}
+
+	for _, in := range []string{"", "monthly", "all=1", "month=2026-13", "month=03-2026", "range=2026-01", "range=2026-03..2026-01"} {
+		if _, err := parseMode(in); err == nil {
+			t.Errorf("parseMode(%q): expected an error", in)
+		}
+	}
+}
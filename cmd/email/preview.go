@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/davidkuda/bellevue/internal/email"
+	"github.com/davidkuda/bellevue/internal/models"
+)
+
+// preview collects the emails of a dry run, either as .eml files with their
+// attachments in a directory or as messages in one mbox file.
+type preview struct {
+	dir  string
+	mbox *os.File
+	rows []previewRow
+}
+
+// previewRow is a line in the summary of a dry run.
+type previewRow struct {
+	UserID     int
+	Email      string
+	Number     string
+	Activities int
+	Total      int
+	Credit     int
+	Categories string
+}
+
+// dryRun renders the emails of a run to out without sending or saving
+// anything. All invoices are created in one transaction, so the invoice
+// numbers are the ones of a real run, and rolled back at the end. Unsent
+// invoices of earlier runs, which a real run would retry, are included.
+func (app *application) dryRun(m mode, f userFilter, out string) error {
+	p, err := newPreview(out)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+
+	tx, err := app.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	items, err := app.models.InvoiceRuns.GetUnsentItems()
+	if err != nil {
+		return fmt.Errorf("could not get unsent invoices: %v", err)
+	}
+
+	for _, item := range items {
+		if !f.match(item.UserID, item.Email) {
+			continue
+		}
+		invoice, err := app.models.InvoicesV2.Get(item.InvoiceID)
+		if err != nil {
+			return fmt.Errorf("could not get invoice %d: %v", item.InvoiceID, err)
+		}
+		if err := app.preview(p, invoice, 0, tx); err != nil {
+			return err
+		}
+	}
+
+	users, err := app.models.Users.GetAllWithUninvoicedActivities()
+	if err != nil {
+		return fmt.Errorf("failed fetching users from DB: %v", err)
+	}
+
+	for _, user := range users {
+		if !f.match(user.ID, user.Email) {
+			continue
+		}
+
+		invoice, n, err := app.createInvoiceTx(m, user.ID, tx)
+		if err != nil {
+			return fmt.Errorf("userID=%d: %v", user.ID, err)
+		}
+		if n == 0 {
+			continue
+		}
+
+		if err := app.preview(p, invoice, n, tx); err != nil {
+			return err
+		}
+	}
+
+	p.printSummary(os.Stdout)
+	return nil
+}
+
+// preview renders the invoice within tx and adds it to p. n is the number of
+// activities, 0 to count them.
+func (app *application) preview(p *preview, invoice models.InvoiceV2, n int, tx *sql.Tx) error {
+	user, err := app.models.Users.GetUserByID(invoice.UserID)
+	if err != nil {
+		return fmt.Errorf("could not get user %d: %v", invoice.UserID, err)
+	}
+
+	// the number the invoice would get when it is sent, tx is rolled back.
+	invoice.Number, err = app.models.InvoicesV2.AssignNumberTx(invoice.ID, tx)
+	if err != nil {
+		return fmt.Errorf("could not assign invoice number invoiceID=%d: %v", invoice.ID, err)
+	}
+
+	viewInvoice, err := app.viewmodels.Activities.GetInvoiceForUserTx(invoice.ID, user.ID, tx)
+	if err != nil {
+		return fmt.Errorf("could not get invoice invoiceID=%d: %v", invoice.ID, err)
+	}
+	if n == 0 {
+		n = len(viewInvoice.Activities)
+	}
+
+	msg, credit, err := app.render(&user, &invoice, viewInvoice)
+	if err != nil {
+		return fmt.Errorf("could not render invoice %d: %v", invoice.ID, err)
+	}
+
+	return p.add(app.config.SenderEmail, msg, previewRow{
+		UserID:     user.ID,
+		Email:      user.Email,
+		Number:     invoice.Number,
+		Activities: n,
+		Total:      viewInvoice.TotalPrice,
+		Credit:     credit,
+		Categories: email.CategorySummary(viewInvoice),
+	})
+}
+
+// newPreview writes to path, an mbox file if path ends in .mbox and a
+// directory otherwise.
+func newPreview(path string) (*preview, error) {
+	if strings.HasSuffix(path, ".mbox") {
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not create mbox: %v", err)
+		}
+		return &preview{mbox: f}, nil
+	}
+
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create preview directory: %v", err)
+	}
+	return &preview{dir: path}, nil
+}
+
+// add stores msg, from is the sender in the mbox.
+func (p *preview) add(from string, msg email.Message, row previewRow) error {
+	p.rows = append(p.rows, row)
+
+	if p.mbox != nil {
+		return writeMbox(p.mbox, from, msg.Body)
+	}
+
+	name := fmt.Sprintf("%s-%s", row.Number, row.Email)
+	if err := os.WriteFile(filepath.Join(p.dir, name+".eml"), msg.Body, 0o644); err != nil {
+		return fmt.Errorf("could not write %s.eml: %v", name, err)
+	}
+	for _, a := range msg.Attachments {
+		path := filepath.Join(p.dir, name+"-"+a.Filename)
+		if err := os.WriteFile(path, a.Content, 0o644); err != nil {
+			return fmt.Errorf("could not write %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+func (p *preview) Close() error {
+	if p.mbox != nil {
+		return p.mbox.Close()
+	}
+	return nil
+}
+
+// writeMbox appends msg in the mboxrd format: lines starting with "From ",
+// optionally quoted with ">", get one more ">". Lines end in LF, not in the
+// CRLF of the message.
+func writeMbox(w io.Writer, from string, msg []byte) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From %s %s\n", from, time.Now().UTC().Format(time.ANSIC))
+
+	msg = bytes.ReplaceAll(msg, []byte("\r\n"), []byte("\n"))
+	for _, line := range strings.Split(strings.TrimRight(string(msg), "\n"), "\n") {
+		if strings.HasPrefix(strings.TrimLeft(line, ">"), "From ") {
+			buf.WriteString(">")
+		}
+		buf.WriteString(line)
+		buf.WriteString("\n")
+	}
+	buf.WriteString("\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// printSummary prints one line per invoice and the totals.
+func (p *preview) printSummary(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "USER\tEMAIL\tNUMBER\tACTIVITIES\tTOTAL\tCREDIT\tCATEGORIES")
+
+	var activities, total, credit int
+	for _, r := range p.rows {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%s\t%s\t%s\n",
+			r.UserID,
+			r.Email,
+			r.Number,
+			r.Activities,
+			formatCurrency(r.Total),
+			formatCurrency(r.Credit),
+			r.Categories,
+		)
+		activities += r.Activities
+		total += r.Total
+		credit += r.Credit
+	}
+
+	fmt.Fprintf(tw, "\t%d invoices\t\t%d\t%s\t%s\t\n",
+		len(p.rows),
+		activities,
+		formatCurrency(total),
+		formatCurrency(credit),
+	)
+	tw.Flush()
+}
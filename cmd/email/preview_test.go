@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteMbox(t *testing.T) {
+	var buf bytes.Buffer
+	msg := "Subject: Test\r\n\r\nFrom here on\r\n>From quoted\r\nnot From\r\n"
+	if err := writeMbox(&buf, "kasse@example.com", []byte(msg)); err != nil {
+		t.Fatalf("writeMbox(): %v", err)
+	}
+
+	lines := strings.Split(buf.String(), "\n")
+	if !strings.HasPrefix(lines[0], "From kasse@example.com ") {
+		t.Errorf("unexpected separator line: %q", lines[0])
+	}
+	want := []string{"Subject: Test", "", ">From here on", ">>From quoted", "not From", "", ""}
+	if got := lines[1:]; strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestPrintSummary(t *testing.T) {
+	p := preview{rows: []previewRow{
+		{UserID: 1, Email: "anna@example.com", Number: "BV-2026-0001", Activities: 3, Total: 4850, Categories: "Essen 45.00, Kiosk 3.50"},
+		{UserID: 2, Email: "ben@example.com", Number: "BV-2026-0002", Activities: 1, Total: 1200, Credit: 500, Categories: "Essen 12.00"},
+	}}
+
+	var buf bytes.Buffer
+	p.printSummary(&buf)
+
+	out := buf.String()
+	for _, s := range []string{"BV-2026-0002", "Essen 45.00, Kiosk 3.50", "2 invoices", "60.50", "5.00"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("expected %q in summary:\n%s", s, out)
+		}
+	}
+}
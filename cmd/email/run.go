@@ -0,0 +1,223 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/davidkuda/bellevue/internal/email"
+	"github.com/davidkuda/bellevue/internal/models"
+	"github.com/davidkuda/bellevue/internal/viewmodels"
+)
+
+// userFilter restricts a run to one member, see -user and -email.
+type userFilter struct {
+	ID    int
+	Email string
+}
+
+func (f userFilter) match(userID int, email string) bool {
+	if f.ID != 0 && userID != f.ID {
+		return false
+	}
+	if f.Email != "" && !strings.EqualFold(email, f.Email) {
+		return false
+	}
+	return true
+}
+
+type runResult struct {
+	Sent   int
+	Failed int
+}
+
+// run first retries the invoices that earlier runs could not send, then
+// creates and sends the new invoices. Every invoice is recorded in the run
+// before it is sent, so a failing member is skipped and retried next time
+// instead of stopping the run or being invoiced twice.
+func (app *application) run(modeName string, m mode, f userFilter) (runResult, error) {
+	var res runResult
+
+	runID, err := app.models.InvoiceRuns.Insert(modeName)
+	if err != nil {
+		return res, err
+	}
+
+	items, err := app.models.InvoiceRuns.GetUnsentItems()
+	if err != nil {
+		return res, fmt.Errorf("could not get unsent invoices: %v", err)
+	}
+
+	for _, item := range items {
+		if !f.match(item.UserID, item.Email) {
+			continue
+		}
+		log.Printf("retrying invoice %d of run %d for %s (attempts=%d)\n", item.InvoiceID, item.RunID, item.Email, item.Attempts)
+		app.send(&res, item.InvoiceID, item.UserID)
+	}
+
+	users, err := app.models.Users.GetAllWithUninvoicedActivities()
+	if err != nil {
+		return res, fmt.Errorf("failed fetching users from DB: %v", err)
+	}
+
+	for _, user := range users {
+		if !f.match(user.ID, user.Email) {
+			continue
+		}
+
+		log.Printf("starting invoicing flow for user id=%d email=%s\n", user.ID, user.Email)
+
+		invoiceID, ok, err := app.newInvoice(runID, m, user.ID)
+		if err != nil {
+			log.Printf("could not create invoice for userID=%d: %v\n", user.ID, err)
+			res.Failed++
+			continue
+		}
+		if !ok {
+			// the user only has activities outside of the mode.
+			log.Println("no activities in this period, skipping")
+			continue
+		}
+
+		app.send(&res, invoiceID, user.ID)
+	}
+
+	if err := app.models.InvoiceRuns.Finish(runID, res.Sent, res.Failed); err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
+
+// newInvoice creates the invoice of the user and records it as pending in
+// the run. ok is false if the user has no activities in the mode.
+func (app *application) newInvoice(runID int, m mode, userID int) (invoiceID int, ok bool, err error) {
+	tx, err := app.db.Begin()
+	if err != nil {
+		return 0, false, fmt.Errorf("failed starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	invoice, n, err := app.createInvoiceTx(m, userID, tx)
+	if err != nil || n == 0 {
+		return 0, false, err
+	}
+
+	if err := app.models.InvoiceRuns.InsertItemTx(runID, invoice.ID, userID, tx); err != nil {
+		return 0, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, false, fmt.Errorf("failed committing transaction: %v", err)
+	}
+
+	log.Printf("created invoice %d with %d activities for userID=%d\n", invoice.ID, n, userID)
+	return invoice.ID, true, nil
+}
+
+// createInvoiceTx creates an invoice with the open activities of the user in
+// the mode and returns how many there were. If there were none, the caller
+// rolls back.
+func (app *application) createInvoiceTx(m mode, userID int, tx *sql.Tx) (models.InvoiceV2, int, error) {
+	invoice, err := app.models.InvoicesV2.NewInvoiceTx(userID, tx)
+	if err != nil {
+		return invoice, 0, fmt.Errorf("could not create a new invoice: %v", err)
+	}
+
+	n, err := m.assignTx(&app.models.InvoicesV2, userID, invoice.ID, tx)
+	if err != nil {
+		return invoice, 0, fmt.Errorf("could not assign activities to invoice %d: %v", invoice.ID, err)
+	}
+	if n == 0 {
+		return invoice, 0, nil
+	}
+
+	return invoice, n, nil
+}
+
+// send sends the invoice and records the outcome in res and in the run.
+func (app *application) send(res *runResult, invoiceID, userID int) {
+	if err := app.sendInvoice(invoiceID, userID); err != nil {
+		log.Printf("could not send invoice %d to userID=%d: %v\n", invoiceID, userID, err)
+		res.Failed++
+		if err := app.models.InvoiceRuns.MarkFailed(invoiceID, err); err != nil {
+			log.Println(err)
+		}
+		return
+	}
+	res.Sent++
+}
+
+// sendInvoice marks the invoice as sent, which assigns its number, renders
+// the email with it and sends it, all in one transaction. If the mailer
+// fails, the invoice stays a draft without a number.
+func (app *application) sendInvoice(invoiceID, userID int) error {
+	user, err := app.models.Users.GetUserByID(userID)
+	if err != nil {
+		return fmt.Errorf("could not get user: %v", err)
+	}
+
+	tx, err := app.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := app.models.InvoicesV2.SetStatusTx(invoiceID, models.InvoiceStatusSent, 0, tx); err != nil {
+		return fmt.Errorf("could not mark invoice as sent: %v", err)
+	}
+
+	invoice, err := app.models.InvoicesV2.GetTx(invoiceID, tx)
+	if err != nil {
+		return fmt.Errorf("could not get invoice: %v", err)
+	}
+
+	viewInvoice, err := app.viewmodels.Activities.GetInvoiceForUserTx(invoiceID, userID, tx)
+	if err != nil {
+		return fmt.Errorf("could not get view invoice: %v", err)
+	}
+
+	msg, _, err := app.render(&user, &invoice, viewInvoice)
+	if err != nil {
+		return err
+	}
+
+	if err := app.mailer.Send(msg); err != nil {
+		return err
+	}
+
+	if err := app.models.InvoiceRuns.MarkSentTx(invoiceID, tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed committing transaction: %v", err)
+	}
+
+	log.Printf("sent invoice %s to %s\n", invoice.Number, user.Email)
+
+	// the credit that was deducted in the email pays the invoice now. The
+	// email is out, so this is only logged.
+	if _, err := app.models.Payments.Allocate(userID); err != nil {
+		log.Printf("could not allocate payments of userID=%d: %v\n", userID, err)
+	}
+
+	return nil
+}
+
+// render returns the invoice email, with the credit of the user deducted and
+// the PDF attached, and the credit.
+func (app *application) render(user *models.User, invoice *models.InvoiceV2, viewInvoice *viewmodels.Invoice) (email.Message, int, error) {
+	credit, err := app.models.Payments.CreditForUser(user.ID)
+	if err != nil {
+		return email.Message{}, 0, fmt.Errorf("could not get credit: %v", err)
+	}
+
+	msg, err := email.RenderInvoice(app.config, user, invoice, viewInvoice, credit)
+	if err != nil {
+		return email.Message{}, 0, err
+	}
+
+	return msg, credit, nil
+}
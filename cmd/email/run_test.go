@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestUserFilter(t *testing.T) {
+	tests := []struct {
+		filter userFilter
+		id     int
+		email  string
+		want   bool
+	}{
+		{userFilter{}, 7, "anna@example.com", true},
+		{userFilter{ID: 7}, 7, "anna@example.com", true},
+		{userFilter{ID: 7}, 8, "ben@example.com", false},
+		{userFilter{Email: "Anna@Example.com"}, 7, "anna@example.com", true},
+		{userFilter{Email: "anna@example.com"}, 8, "ben@example.com", false},
+		{userFilter{ID: 8, Email: "anna@example.com"}, 7, "anna@example.com", false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.filter.match(tt.id, tt.email); got != tt.want {
+			t.Errorf("%+v.match(%d, %q): expected %v, got %v", tt.filter, tt.id, tt.email, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// reconcile imports camt.053 / camt.054 files from the bank and marks the
+// matching invoices as paid. Unmatched entries are reviewed under
+// /settings/bank-transactions.
+//
+// usage: go run ./cmd/reconcile statement-1.xml [statement-2.xml ...]
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/davidkuda/bellevue/internal/envcfg"
+	"github.com/davidkuda/bellevue/internal/models"
+	"github.com/davidkuda/bellevue/internal/reconcile"
+)
+
+func main() {
+	iban := flag.String("iban", os.Getenv("RECIPIENT_IBAN"), "IBAN of the account that members pay to")
+	flag.Parse()
+
+	if *iban == "" {
+		log.Fatal("make sure to either pass -iban or define env var RECIPIENT_IBAN")
+	}
+	if flag.NArg() == 0 {
+		log.Fatal("usage: reconcile [-iban IBAN] FILE...")
+	}
+
+	db, err := envcfg.DB()
+	if err != nil {
+		log.Fatalf("could not open DB: %v\n", err)
+	}
+	defer db.Close()
+
+	importer := reconcile.Importer{
+		DB:     db,
+		Models: models.New(db),
+		IBAN:   *iban,
+	}
+
+	for _, name := range flag.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		res, err := importer.Import(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("could not import %s: %v\n", name, err)
+		}
+
+		log.Printf(
+			"%s: imported=%d matched=%d unmatched=%d duplicates=%d skipped=%d\n",
+			name, res.Imported, res.Matched, res.Unmatched, res.Duplicates, res.Skipped,
+		)
+	}
+}
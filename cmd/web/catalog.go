@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/davidkuda/bellevue/internal/models"
+)
+
+// catalogSnapshot is what the activity form needs of the catalog, loaded at
+// one version with the prices valid on Day. It is shared between requests and
+// must not be modified; use ProductFormConfig.WithValues for a copy with
+// values.
+type catalogSnapshot struct {
+	Version            int64
+	Day                string // 2006-01-02
+	ProductFormConfig  models.ProductFormConfig
+	PriceCategoryIDMap models.PriceCategoryIDMap
+}
+
+// catalogStore holds the current catalogSnapshot. A request takes one
+// snapshot and uses it throughout, so a reload in between does not mix two
+// versions of the catalog.
+type catalogStore struct {
+	products        *models.ProductModel
+	priceCategories *models.PriceCategoryModel
+
+	current atomic.Pointer[catalogSnapshot]
+	mu      sync.Mutex // one refresh at a time
+}
+
+func newCatalogStore(m *models.Models) (*catalogStore, error) {
+	s := &catalogStore{
+		products:        &m.Products,
+		priceCategories: &m.PriceCategories,
+	}
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Snapshot returns the current catalog.
+func (s *catalogStore) Snapshot() *catalogSnapshot {
+	return s.current.Load()
+}
+
+// refresh reloads the catalog if its version in the database changed, or on
+// a new day, when scheduled prices may have become valid.
+func (s *catalogStore) refresh() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// the version is read first: a change while loading makes the next
+	// refresh load again rather than miss it.
+	version, err := s.products.GetCatalogVersion()
+	if err != nil {
+		return fmt.Errorf("could not get catalog version: %v", err)
+	}
+	day := time.Now().Format("2006-01-02")
+	if cur := s.current.Load(); cur != nil && cur.Version == version && cur.Day == day {
+		return nil
+	}
+
+	next := &catalogSnapshot{Version: version, Day: day}
+
+	next.ProductFormConfig, err = s.products.GetProductFormConfig()
+	if err != nil {
+		return fmt.Errorf("could not load productFormConfig: %v", err)
+	}
+
+	next.PriceCategoryIDMap, err = s.priceCategories.GetPriceCatMap()
+	if err != nil {
+		return fmt.Errorf("could not load priceCategoryIDMap: %v", err)
+	}
+
+	s.current.Store(next)
+	return nil
+}
+
+// catalogWatcher refreshes the catalog every interval, e.g. after a product
+// was changed by another instance or directly in the database.
+func (app *application) catalogWatcher(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := app.catalog.refresh(); err != nil {
+			log.Printf("could not refresh catalog: %v", err)
+		}
+	}
+}
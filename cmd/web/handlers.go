@@ -2,11 +2,15 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/davidkuda/bellevue/internal/email"
+	"github.com/davidkuda/bellevue/internal/models"
+	"github.com/davidkuda/bellevue/internal/qrbill"
+	"github.com/davidkuda/bellevue/internal/viewmodels"
 )
 
 // GET /
@@ -30,21 +34,103 @@ func (app *application) getActivities(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, r, fmt.Errorf("could not get uninvoiced activities: %v", err))
 		return
 	}
+	if t.ViewModels.UninvoicedActivities != nil {
+		contact, err := app.models.Households.GetBillingContact(t.User.ID)
+		if err != nil {
+			app.serverError(w, r, fmt.Errorf("could not get billing contact of userID=%v: %v", t.User.ID, err))
+			return
+		}
+		if contact.ID != t.User.ID {
+			t.ViewModels.UninvoicedActivities.BilledTo = contact.FirstName + " " + contact.LastName
+		}
+	}
+
+	t.ViewModels.EnteredForOthers, err = app.viewmodels.Activities.GetActivitiesEnteredForOthers(t.User.ID)
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get activities entered for others: %v", err))
+		return
+	}
+
+	t.ViewModels.ActivityDrafts, err = app.models.ActivityTemplates.GetDrafts(t.User.ID, today())
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get drafts of activity templates: %v", err))
+		return
+	}
 
 	t.ViewModels.SentInvoices, err = app.viewmodels.Activities.GetAllInvoicesForUser(t.User.ID)
 	if err != nil {
 		app.serverError(w, r, fmt.Errorf("could not get sent invoices: %v", err))
 		return
 	}
+	// the reference of the QR-bill, which members copy.
+	for _, invoice := range t.ViewModels.SentInvoices {
+		invoice.Reference = qrbill.ReferenceFor(app.EmailConfig.Recipient.IBAN, invoice.Reference)
+	}
+
+	t.ViewModels.Balance, err = app.models.Payments.BalanceForUser(t.User.ID)
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get balance: %v", err))
+		return
+	}
 
 	app.render(w, r, http.StatusOK, "activities.tmpl.html", &t)
 }
 
-// GET /activities/new
+// GET /activities/new, prefilled with ?template={id}, the draft of a
+// recurring template with ?template={id}&date=2006-01-02, or the previous
+// activity with ?copy=previous.
 func (app *application) getActivitiesNew(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	form := productForm{}
+	prefill, err := app.activityPrefill(r, user.ID, &form)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.renderClientError(w, r, http.StatusNotFound)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.renderActivitiesNew(w, r, form, prefill)
+}
+
+// renderActivitiesNew renders the form of a new activity, prefilled with the
+// products of prefill unless it is nil.
+func (app *application) renderActivitiesNew(w http.ResponseWriter, r *http.Request, form productForm, prefill *viewmodels.Activity) {
+	var err error
+
 	t := app.newTemplateData(r)
 	t.Title = "New Bellevue Activity"
-	t.Form = productForm{}
+	t.Form = form
+
+	t.ViewModels.ActivityTemplates, err = app.models.ActivityTemplates.GetAllForUser(t.User.ID)
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get activity templates: %v", err))
+		return
+	}
+
+	// members and staff pick the member in the form, whose price categories
+	// are checked when the activity is saved.
+	t.ViewModels.Users, err = app.models.Users.GetAll()
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get users: %v", err))
+		return
+	}
+	if !t.IsStaff {
+		priceCategories, err := app.models.Users.GetPriceCategories(t.User.ID)
+		if err != nil {
+			app.serverError(w, r, fmt.Errorf("could not get price categories of user: %v", err))
+			return
+		}
+		t.ProductFormConfig = t.ProductFormConfig.WithPriceCategories(priceCategories)
+	}
+
+	if prefill != nil {
+		t.ProductFormConfig = t.ProductFormConfig.WithValues(prefill)
+	}
+
 	app.render(w, r, http.StatusOK, "activities.new.tmpl.html", &t)
 }
 
@@ -59,16 +145,34 @@ func (app *application) getActivitiesIDEdit(w http.ResponseWriter, r *http.Reque
 
 	t := app.newTemplateData(r)
 
-	viewActivity, err := app.viewmodels.Activities.GetActivityByIDForUser(activityID, t.User.ID)
+	activity, ok := app.getEditableActivity(w, r, activityID)
+	if !ok {
+		return
+	}
+
+	viewActivity, err := app.viewmodels.Activities.GetActivityByIDForUser(activityID, activity.UserID)
 	if err != nil {
 		app.serverError(w, r, fmt.Errorf("could not get uninvoiced activities: %v", err))
 		return
 	}
 
+	// the prices of the day of the activity, not of today.
+	formConfig, err := app.models.Products.GetProductFormConfigAt(viewActivity.Date)
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get product form config: %v", err))
+		return
+	}
+
+	priceCategories, err := app.models.Users.GetPriceCategories(activity.UserID)
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get price categories of user: %v", err))
+		return
+	}
+
 	t.ViewModels.Activity = viewActivity
 	t.Edit = true
 	t.Title = "Edit Bellevue Activity"
-	t.ProductFormConfig = app.productFormConfig.WithValues(viewActivity)
+	t.ProductFormConfig = formConfig.WithPriceCategories(priceCategories).WithValues(viewActivity)
 	t.Form = productForm{}
 
 	app.render(w, r, http.StatusOK, "activities.new.tmpl.html", &t)
@@ -83,6 +187,10 @@ func (app *application) bellevueActivityDelete(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	if _, ok := app.getEditableActivity(w, r, activityID); !ok {
+		return
+	}
+
 	ctx := context.TODO()
 	tx, err := app.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -93,8 +201,14 @@ func (app *application) bellevueActivityDelete(w http.ResponseWriter, r *http.Re
 
 	// NOTE: If there was a cascade delete, I wouldn't need a transaction and two funcs.
 	// however, I don't want ease at deleting consumptions.
-	app.models.Consumptions.DeleteByActivityID(activityID, tx)
-	app.models.Activities.Delete(activityID, tx)
+	if err := app.models.Consumptions.DeleteByActivityID(activityID, tx); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if err := app.models.Activities.Delete(activityID, tx); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
 	if err := tx.Commit(); err != nil {
 		app.serverError(w, r, fmt.Errorf("failed committing transaction: %s", err))
@@ -122,17 +236,31 @@ func (app *application) invoicePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	app.models.InvoicesV2.AssignAllOpenActivitiesToInvoiceTx(userID, invoice.ID, tx)
-
-	tx.Commit()
-
-	viewInvoice, err := app.viewmodels.Activities.GetInvoiceForUser(invoice.ID, user.ID)
+	n, err := app.models.InvoicesV2.AssignAllOpenActivitiesToInvoiceTx(userID, invoice.ID, tx)
 	if err != nil {
-		err = fmt.Errorf("could not get invoice invoiceID=%v userID=%v: %v", invoice.ID, user.ID, err)
+		app.serverError(w, r, fmt.Errorf("could not assign activities to invoice: %v", err))
+		return
+	}
+	// e.g. a member of a household, whose activities are invoiced to the
+	// billing contact. /activities tells them so instead of the button.
+	if n == 0 {
+		w.Header().Set("HX-Redirect", "/activities")
+		return
+	}
+
+	// the outbox worker numbers the invoice, marks it as sent and renders
+	// the email in the transaction that delivers it.
+	msg := email.Message{To: user.Email, Subject: app.EmailConfig.EmailSubject}
+	if err := app.enqueueTx(models.OutboxKindInvoice, msg, invoice.ID, tx); err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
-	email.Send(app.EmailConfig, user, &invoice, viewInvoice)
+	if err := tx.Commit(); err != nil {
+		app.serverError(w, r, fmt.Errorf("failed committing transaction: %v", err))
+		return
+	}
+	app.wakeOutbox()
+
 	w.Header().Set("HX-Redirect", "/activities")
 }
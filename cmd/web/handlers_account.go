@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/davidkuda/bellevue/internal/models"
+)
+
+type accountForm struct {
+	PriceCategories models.UserPriceCategories
+	Saved           bool
+	Error           string
+}
+
+// GET /account
+func (app *application) getAccount(w http.ResponseWriter, r *http.Request) {
+	app.renderAccount(w, r, accountForm{})
+}
+
+// POST /account sets the default price category of the member.
+func (app *application) postAccount(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	var form accountForm
+	err := app.models.Users.SetDefaultPriceCategory(user.ID, r.PostForm.Get("default"))
+	if err != nil {
+		if !errors.Is(err, models.ErrPriceCategoryNotAllowed) {
+			app.serverError(w, r, err)
+			return
+		}
+		// a 200, htmx does not swap 4xx responses.
+		form.Error = "you may not pick this price category"
+	} else {
+		form.Saved = true
+	}
+
+	app.renderAccount(w, r, form)
+}
+
+func (app *application) renderAccount(w http.ResponseWriter, r *http.Request, form accountForm) {
+	var err error
+
+	t := app.newTemplateData(r)
+	t.Title = "Account"
+
+	form.PriceCategories, err = app.models.Users.GetPriceCategories(t.User.ID)
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get price categories of user: %v", err))
+		return
+	}
+	t.Form = form
+
+	t.ViewModels.PriceCategories, err = app.models.PriceCategories.GetAll()
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get price categories: %v", err))
+		return
+	}
+
+	app.render(w, r, http.StatusOK, "account.tmpl.html", &t)
+}
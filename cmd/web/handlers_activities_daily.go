@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davidkuda/bellevue/internal/models"
+	"github.com/davidkuda/bellevue/internal/viewmodels"
+)
+
+// dailyRow is a member in the daily grid of staff, with the products of
+// their activity of the day.
+type dailyRow struct {
+	User       models.User
+	ActivityID int // 0 if the member has none on the day yet
+	Specs      []models.ProductFormSpec
+	Errors     []string
+}
+
+type dailyForm struct {
+	Date  time.Time
+	Rows  []dailyRow
+	Saved bool
+}
+
+// GET /activities/daily?date=2006-01-02 shows the grid of members by products
+// of the day, today by default.
+func (app *application) getActivitiesDaily(w http.ResponseWriter, r *http.Request) {
+	date, err := dateParam(r)
+	if err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	app.renderActivitiesDaily(w, r, date, nil, nil, false)
+}
+
+// dailyAction is what postActivitiesDaily does with a row of the grid.
+type dailyAction int
+
+const (
+	dailySkip dailyAction = iota
+	dailyCreate
+	dailyUpdate
+	dailyDelete
+	dailyConflict // the activity changed since the grid was rendered
+)
+
+// decideDaily returns the action for a row that was rendered with the
+// activity rendered, where stored is the activity of the day now, 0 for
+// none. A row without products that was rendered without an activity is
+// skipped, whatever happened since. Else an activity that was created,
+// deleted or invoiced since the grid was rendered is neither overwritten nor
+// deleted.
+func decideDaily(rendered, stored int, empty bool) dailyAction {
+	switch {
+	case empty && rendered == 0:
+		return dailySkip
+	case rendered != stored:
+		return dailyConflict
+	case empty:
+		return dailyDelete
+	case stored == 0:
+		return dailyCreate
+	default:
+		return dailyUpdate
+	}
+}
+
+// dailySubmission is a submitted row of the grid.
+type dailySubmission struct {
+	UserID     int
+	ActivityID int // rendered, 0 if the member had none
+	Form       *productForm
+}
+
+// parseDailyRows parses the rows the grid rendered: the user IDs of the rows
+// field and the fields of each row, prefixed with "{userID}:".
+func parseDailyRows(r *http.Request, date time.Time, catalog *catalogSnapshot) ([]dailySubmission, error) {
+	var rows []dailySubmission
+	for _, s := range r.PostForm["rows"] {
+		userID, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid row %q", s)
+		}
+		prefix := s + ":"
+
+		activityID, err := strconv.Atoi(r.PostForm.Get(prefix + "activity_id"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid activity of row %d", userID)
+		}
+
+		form := &productForm{
+			UserID:      userID,
+			Date:        date,
+			FieldErrors: map[string]string{},
+		}
+		parseProducts(form, func(name string) string {
+			return r.PostForm.Get(prefix + name)
+		}, catalog)
+
+		rows = append(rows, dailySubmission{UserID: userID, ActivityID: activityID, Form: form})
+	}
+	return rows, nil
+}
+
+// POST /activities/daily creates, updates or deletes the activity of the day
+// of every member of the submitted grid, in one transaction. A member
+// without products keeps no activity of the day. If an activity changed
+// since the grid was rendered, nothing is saved and the grid shows the
+// activities as they are now.
+func (app *application) postActivitiesDaily(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", r.PostForm.Get("date"))
+	if err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	rows, err := parseDailyRows(r, date, app.catalog.Snapshot())
+	if err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	users, err := app.models.Users.GetAll()
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get users: %v", err))
+		return
+	}
+	exists := map[int]bool{}
+	for _, u := range users {
+		exists[u.ID] = true
+	}
+
+	priceCategories, err := app.models.Users.GetAllPriceCategories()
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get price categories of users: %v", err))
+		return
+	}
+
+	// the same validation as the form of a single activity, per member.
+	forms := map[int]*productForm{}
+	valid := true
+	for _, row := range rows {
+		if !exists[row.UserID] {
+			app.renderClientError(w, r, http.StatusBadRequest)
+			return
+		}
+
+		upc, ok := priceCategories[row.UserID]
+		if !ok {
+			upc = models.UserPriceCategories{UserID: row.UserID}
+		}
+		row.Form.checkPriceCategories(upc)
+
+		if err := app.setPrices(row.Form); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		forms[row.UserID] = row.Form
+		if len(row.Form.FieldErrors) > 0 {
+			valid = false
+		}
+	}
+
+	// the grid is rendered again with its errors. That is a 200, htmx does
+	// not swap 4xx responses.
+	if !valid {
+		app.renderActivitiesDaily(w, r, date, forms, nil, false)
+		return
+	}
+
+	daily, err := app.viewmodels.Activities.GetDailyActivities(date)
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get activities of the day: %v", err))
+		return
+	}
+
+	actions := map[int]dailyAction{}
+	conflicts := map[int]bool{}
+	for _, row := range rows {
+		var stored int
+		if a := daily[row.UserID]; a != nil {
+			stored = a.ID
+		}
+		actions[row.UserID] = decideDaily(row.ActivityID, stored, row.Form.isEmpty())
+		if actions[row.UserID] == dailyConflict {
+			conflicts[row.UserID] = true
+		}
+	}
+
+	if len(conflicts) > 0 {
+		// the other rows keep what was submitted.
+		for userID := range conflicts {
+			delete(forms, userID)
+		}
+		app.renderActivitiesDaily(w, r, date, forms, conflicts, false)
+		return
+	}
+
+	ctx := context.TODO()
+	tx, err := app.db.BeginTx(ctx, nil)
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("failed starting transaction: %e", err))
+		return
+	}
+	defer tx.Rollback()
+
+	for _, row := range rows {
+		activityID := row.ActivityID
+
+		switch actions[row.UserID] {
+		case dailySkip:
+			continue
+		case dailyDelete:
+			if err := app.models.Consumptions.DeleteByActivityID(activityID, tx); err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			if err := app.models.Activities.Delete(activityID, tx); err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			continue
+		case dailyCreate:
+			activity := row.Form.toActivity(row.UserID)
+			activity.EnteredBy = user.ID
+			activityID, err = app.models.Activities.InsertWithTransaction(activity, tx)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+		}
+
+		consumptions := row.Form.toConsumptions(activityID)
+		err = app.models.Consumptions.InsertManyWithTransaction(activityID, consumptions, tx)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		app.serverError(w, r, fmt.Errorf("failed committing transaction: %s", err))
+		return
+	}
+
+	app.renderActivitiesDaily(w, r, date, nil, nil, true)
+}
+
+// renderActivitiesDaily renders the grid of date, with the submitted forms
+// if they had errors, else with the activities of the day. The rows of
+// conflicts show the activities of the day with an error.
+func (app *application) renderActivitiesDaily(w http.ResponseWriter, r *http.Request, date time.Time, forms map[int]*productForm, conflicts map[int]bool, saved bool) {
+	users, err := app.models.Users.GetAll()
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get users: %v", err))
+		return
+	}
+
+	priceCategories, err := app.models.Users.GetAllPriceCategories()
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get price categories of users: %v", err))
+		return
+	}
+
+	daily, err := app.viewmodels.Activities.GetDailyActivities(date)
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get activities of the day: %v", err))
+		return
+	}
+
+	t := app.newTemplateData(r)
+	t.Title = "Daily Activities"
+
+	// the columns are the products that postActivitiesDaily parses. The grid
+	// has no prices, setPrices checks that the products exist on date.
+	formConfig := t.ProductFormConfig
+
+	form := dailyForm{Date: date, Saved: saved}
+	for _, u := range users {
+		row := dailyRow{User: u}
+
+		upc, ok := priceCategories[u.ID]
+		if !ok {
+			upc = models.UserPriceCategories{UserID: u.ID}
+		}
+		config := formConfig.WithPriceCategories(upc)
+
+		a := daily[u.ID]
+		if a != nil {
+			row.ActivityID = a.ID
+		}
+		switch f := forms[u.ID]; {
+		case f != nil:
+			config = config.WithValues(f.toViewModel())
+			row.Errors = f.errorsBySpec(config.Specs)
+		case a != nil:
+			config = config.WithValues(a)
+		}
+		if conflicts[u.ID] {
+			row.Errors = append(row.Errors, "changed since the grid was loaded, check and save again")
+		}
+
+		row.Specs = config.Specs
+		form.Rows = append(form.Rows, row)
+	}
+
+	t.Form = form
+
+	app.render(w, r, http.StatusOK, "activities.daily.tmpl.html", &t)
+}
+
+// isEmpty reports whether the form has no product with a quantity.
+func (pf *productForm) isEmpty() bool {
+	for _, p := range pf.Products {
+		if p.Quantity > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// toViewModel returns the products of the form as an activity, to render
+// the form again with the submitted values.
+func (pf *productForm) toViewModel() *viewmodels.Activity {
+	activity := viewmodels.Activity{UserID: pf.UserID, Date: pf.Date}
+	for _, p := range pf.Products {
+		activity.Consumptions = append(activity.Consumptions, viewmodels.Consumption{
+			ProductCode:   p.Code,
+			PriceCategory: p.PriceCategory,
+			Quantity:      p.Quantity,
+			UnitPrice:     p.AmountCHF,
+		})
+	}
+	return &activity
+}
+
+// errorsBySpec returns the field errors of the form labelled by product.
+func (pf *productForm) errorsBySpec(specs []models.ProductFormSpec) []string {
+	var errs []string
+	for _, spec := range specs {
+		for field, msg := range pf.FieldErrors {
+			if field == spec.Code || strings.HasPrefix(field, spec.Code+"-") {
+				errs = append(errs, spec.Label+": "+msg)
+			}
+		}
+	}
+	return errs
+}
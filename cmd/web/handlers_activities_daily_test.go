@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkuda/bellevue/internal/models"
+)
+
+func TestDecideDaily(t *testing.T) {
+	tests := []struct {
+		name     string
+		rendered int
+		stored   int
+		empty    bool
+		want     dailyAction
+	}{
+		{"nothing entered", 0, 0, true, dailySkip},
+		{"entered by the member since", 0, 5, true, dailySkip},
+		{"new activity", 0, 0, false, dailyCreate},
+		{"created since", 0, 5, false, dailyConflict},
+		{"update", 5, 5, false, dailyUpdate},
+		{"delete", 5, 5, true, dailyDelete},
+		{"deleted or invoiced since", 5, 0, false, dailyConflict},
+		{"deleted since, not to delete", 5, 0, true, dailyConflict},
+		{"replaced since", 5, 6, true, dailyConflict},
+	}
+
+	for _, tt := range tests {
+		if got := decideDaily(tt.rendered, tt.stored, tt.empty); got != tt.want {
+			t.Errorf("%s: expected action %d, got %d", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestParseDailyRows(t *testing.T) {
+	catalog := &catalogSnapshot{
+		ProductFormConfig: models.ProductFormConfig{
+			Specs: []models.ProductFormSpec{
+				{Code: "lunch", HasCategories: true},
+				{Code: "snacks", IsCustomAmount: true},
+			},
+		},
+		PriceCategoryIDMap: models.PriceCategoryIDMap{"regular": 1},
+	}
+	date := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+
+	form := url.Values{
+		"rows":                                {"7", "9"},
+		"7:activity_id":                       {"0"},
+		"7:activities[lunch][quantity]":       {"2"},
+		"7:activities[lunch][price_category]": {"regular"},
+		"7:activities[snacks][amount_chf]":    {"3.50"},
+		"9:activity_id":                       {"12"},
+		"9:activities[lunch][quantity]":       {"0"},
+		"9:activities[snacks][amount_chf]":    {"0"},
+		// a member who signed up after the grid was rendered has no row.
+		"11:activities[lunch][quantity]": {"1"},
+	}
+	r := httptest.NewRequest(http.MethodPost, "/activities/daily", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := r.ParseForm(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := parseDailyRows(r, date, catalog)
+	if err != nil {
+		t.Fatalf("parseDailyRows(): %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+
+	anna := rows[0]
+	if anna.UserID != 7 || anna.ActivityID != 0 || len(anna.Form.FieldErrors) != 0 {
+		t.Errorf("unexpected row %+v with errors %v", anna, anna.Form.FieldErrors)
+	}
+	want := []parsedProduct{
+		{Code: "lunch", PriceCategory: "regular", Quantity: 2},
+		{Code: "snacks", Price: 350, Quantity: 1, AmountCHF: 350},
+	}
+	if !reflect.DeepEqual(anna.Form.Products, want) {
+		t.Errorf("expected products %+v, got %+v", want, anna.Form.Products)
+	}
+
+	beat := rows[1]
+	if beat.UserID != 9 || beat.ActivityID != 12 || !beat.Form.isEmpty() {
+		t.Errorf("unexpected row %+v with products %+v", beat, beat.Form.Products)
+	}
+
+	form.Set("9:activity_id", "")
+	r = httptest.NewRequest(http.MethodPost, "/activities/daily", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := r.ParseForm(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseDailyRows(r, date, catalog); err == nil {
+		t.Error("expected an error for a row without its activity")
+	}
+}
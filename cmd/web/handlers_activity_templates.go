@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davidkuda/bellevue/internal/models"
+	"github.com/davidkuda/bellevue/internal/viewmodels"
+)
+
+// activityPrefill returns the activity to prefill the new activity form
+// with: the template of ?template={id}, or the previous activity of the user
+// with ?copy=previous. nil if there is none. With ?date=2006-01-02, the
+// template proposed the activity as a draft on that day, which the form
+// confirms.
+func (app *application) activityPrefill(r *http.Request, userID int, form *productForm) (*viewmodels.Activity, error) {
+	query := r.URL.Query()
+
+	if query.Get("copy") == "previous" {
+		prefill, err := app.viewmodels.Activities.GetLatestActivityForUser(userID, today())
+		if err != nil {
+			return nil, fmt.Errorf("could not get previous activity: %v", err)
+		}
+		return prefill, nil
+	}
+
+	s := query.Get("template")
+	if s == "" {
+		return nil, nil
+	}
+	templateID, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, models.ErrNoRecord
+	}
+
+	tmpl, err := app.models.ActivityTemplates.Get(userID, templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	if s := query.Get("date"); s != "" {
+		date, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return nil, models.ErrNoRecord
+		}
+		form.Date = date
+		form.DraftTemplateID = tmpl.ID
+		form.DraftDate = date
+	}
+
+	return tmpl.Activity(), nil
+}
+
+// POST /activities/templates saves the products of the activity form as a
+// template of the member.
+func (app *application) postActivitiesTemplates(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	form := parseProductForm(r, app.catalog.Snapshot())
+	form.UserID = user.ID
+
+	tmpl := models.ActivityTemplate{
+		UserID:     user.ID,
+		Name:       strings.TrimSpace(r.PostForm.Get("template_name")),
+		Recurrence: r.PostForm.Get("recurrence"),
+	}
+	for _, p := range form.Products {
+		if p.Quantity == 0 {
+			continue
+		}
+		tmpl.Items = append(tmpl.Items, models.ActivityTemplateItem{
+			ProductCode:   p.Code,
+			PriceCategory: p.PriceCategory,
+			Quantity:      p.Quantity,
+			Amount:        p.AmountCHF,
+		})
+	}
+
+	// the form is rendered again with its errors. That is a 200, htmx does
+	// not swap 4xx responses.
+	switch {
+	case tmpl.Name == "":
+		form.FieldErrors["template"] = "name the template"
+	case tmpl.Recurrence != "" && tmpl.Recurrence != models.RecurDaily && tmpl.Recurrence != models.RecurWeekdays:
+		form.FieldErrors["template"] = "invalid repetition"
+	case len(tmpl.Items) == 0:
+		form.FieldErrors["template"] = "add products to the template"
+	}
+
+	// an invalid date does not matter to a template.
+	delete(form.FieldErrors, "date")
+
+	if len(form.FieldErrors) == 0 {
+		if _, err := app.models.ActivityTemplates.Insert(tmpl); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		form.TemplateSaved = true
+	}
+
+	app.renderActivitiesNew(w, r, form, form.toViewModel())
+}
+
+// DELETE /activities/templates/{id}
+func (app *application) deleteActivitiesTemplatesID(w http.ResponseWriter, r *http.Request) {
+	templateID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	if err := app.models.ActivityTemplates.Delete(user.ID, templateID); err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.renderClientError(w, r, http.StatusNotFound)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+}
+
+// POST /activities/templates/{id}/dismiss dismisses the draft of the
+// recurring template on the day of the form.
+func (app *application) postActivitiesTemplatesIDDismiss(w http.ResponseWriter, r *http.Request) {
+	templateID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", r.PostForm.Get("date"))
+	if err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	if err := app.models.ActivityTemplates.DismissDraft(user.ID, templateID, date); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
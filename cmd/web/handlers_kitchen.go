@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/davidkuda/bellevue/internal/viewmodels"
+)
+
+type kitchenForm struct {
+	Date        time.Time
+	Convertible bool // the day is over, see postKitchenConvert
+	Converted   int  // members whose registrations became activities
+	Error       string
+}
+
+// GET /kitchen?date=2006-01-02 shows the meal registrations of the day,
+// today by default, with headcounts per meal and price category.
+func (app *application) getKitchen(w http.ResponseWriter, r *http.Request) {
+	date, err := dateParam(r)
+	if err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	app.renderKitchen(w, r, "kitchen.tmpl.html", kitchenForm{Date: date})
+}
+
+// GET /kitchen/report?date=2006-01-02 is the page of the kitchen to print.
+func (app *application) getKitchenReport(w http.ResponseWriter, r *http.Request) {
+	date, err := dateParam(r)
+	if err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	app.renderKitchen(w, r, "kitchen.report.tmpl.html", kitchenForm{Date: date})
+}
+
+// POST /kitchen/convert turns the registrations of a past day into
+// activities, one per member, entered by the staff. A member who already has
+// an open activity of the day, see GetDailyActivities, gets the meals added
+// to it, except the ones it has already. Members correct them like any other
+// activity.
+func (app *application) postKitchenConvert(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", r.PostForm.Get("date"))
+	if err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// the form is rendered again with its errors. That is a 200, htmx does
+	// not swap 4xx responses.
+	form := kitchenForm{Date: date}
+	if !date.Before(today()) {
+		form.Error = "registrations become activities after the day, not in advance"
+		app.renderKitchen(w, r, "kitchen.tmpl.html", form)
+		return
+	}
+
+	regs, err := app.models.MealRegistrations.GetByDate(date)
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get meal registrations: %v", err))
+		return
+	}
+
+	// one activity per member, with the products of their meals.
+	var userIDs []int
+	forms := map[int]*productForm{}
+	for _, reg := range regs {
+		if reg.Converted {
+			continue
+		}
+		form, ok := forms[reg.UserID]
+		if !ok {
+			form = &productForm{
+				UserID:      reg.UserID,
+				Date:        date,
+				FieldErrors: map[string]string{},
+			}
+			forms[reg.UserID] = form
+			userIDs = append(userIDs, reg.UserID)
+		}
+		form.Products = append(form.Products, parsedProduct{
+			Code:          reg.Meal,
+			PriceCategory: reg.PriceCategory,
+			Quantity:      1,
+		})
+	}
+
+	daily, err := app.viewmodels.Activities.GetDailyActivities(date)
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get activities of the day: %v", err))
+		return
+	}
+
+	// the meals join the activity of the day, so that none is billed twice.
+	for userID, a := range daily {
+		if f, ok := forms[userID]; ok {
+			f.Products = mergeMeals(a, f.Products)
+		}
+	}
+
+	for _, userID := range userIDs {
+		if err := app.setPrices(forms[userID]); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		for code, msg := range forms[userID].FieldErrors {
+			form.Error = fmt.Sprintf("%s: %s", code, msg)
+			app.renderKitchen(w, r, "kitchen.tmpl.html", form)
+			return
+		}
+	}
+
+	user := app.contextGetUser(r)
+
+	ctx := context.TODO()
+	tx, err := app.db.BeginTx(ctx, nil)
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("failed starting transaction: %e", err))
+		return
+	}
+	defer tx.Rollback()
+
+	for _, userID := range userIDs {
+		var activityID int
+		if a := daily[userID]; a != nil {
+			activityID = a.ID
+		} else {
+			activity := forms[userID].toActivity(userID)
+			activity.EnteredBy = user.ID
+			activityID, err = app.models.Activities.InsertWithTransaction(activity, tx)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+		}
+
+		consumptions := forms[userID].toConsumptions(activityID)
+		err = app.models.Consumptions.InsertManyWithTransaction(activityID, consumptions, tx)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		err = app.models.MealRegistrations.ConvertTx(userID, date, activityID, tx)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		app.serverError(w, r, fmt.Errorf("failed committing transaction: %s", err))
+		return
+	}
+
+	form.Converted = len(userIDs)
+	app.renderKitchen(w, r, "kitchen.tmpl.html", form)
+}
+
+// mergeMeals returns the products of the activity of the day with the meals
+// it does not have yet, whatever the price category.
+func mergeMeals(a *viewmodels.Activity, meals []parsedProduct) []parsedProduct {
+	var products []parsedProduct
+	has := map[string]bool{}
+	for _, c := range a.Consumptions {
+		p := parsedProduct{
+			Code:          c.ProductCode,
+			PriceCategory: c.PriceCategory,
+			Quantity:      c.Quantity,
+		}
+		// a custom amount keeps its amount, setPrices sets fixed prices.
+		if c.PriceCategory == "" {
+			p.Price = c.UnitPrice
+			p.AmountCHF = c.UnitPrice
+		}
+		products = append(products, p)
+		has[c.ProductCode] = true
+	}
+
+	for _, m := range meals {
+		if !has[m.Code] {
+			products = append(products, m)
+		}
+	}
+
+	return products
+}
+
+func (app *application) renderKitchen(w http.ResponseWriter, r *http.Request, page string, form kitchenForm) {
+	var err error
+
+	t := app.newTemplateData(r)
+	t.Title = "Kitchen"
+	form.Convertible = form.Date.Before(today())
+	t.Form = form
+
+	t.ViewModels.MealRegistrations, err = app.models.MealRegistrations.GetByDate(form.Date)
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get meal registrations: %v", err))
+		return
+	}
+	t.ViewModels.MealHeadcounts = t.ViewModels.MealRegistrations.Headcounts()
+
+	app.render(w, r, http.StatusOK, page, &t)
+}
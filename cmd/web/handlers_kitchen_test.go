@@ -0,0 +1,31 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/davidkuda/bellevue/internal/viewmodels"
+)
+
+func TestMergeMeals(t *testing.T) {
+	a := &viewmodels.Activity{
+		ID: 5,
+		Consumptions: []viewmodels.Consumption{
+			{ProductCode: "lunch", PriceCategory: "reduced", Quantity: 1, UnitPrice: 1000},
+			{ProductCode: "snacks", Quantity: 1, UnitPrice: 350},
+		},
+	}
+	meals := []parsedProduct{
+		{Code: "breakfast", PriceCategory: "regular", Quantity: 1},
+		{Code: "lunch", PriceCategory: "regular", Quantity: 1},
+	}
+
+	want := []parsedProduct{
+		{Code: "lunch", PriceCategory: "reduced", Quantity: 1},
+		{Code: "snacks", Price: 350, Quantity: 1, AmountCHF: 350},
+		{Code: "breakfast", PriceCategory: "regular", Quantity: 1},
+	}
+	if got := mergeMeals(a, meals); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected products %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davidkuda/bellevue/internal/models"
+)
+
+type mealsForm struct {
+	Date            time.Time
+	Meals           []string // to pick from, models.Meals
+	PriceCategories models.UserPriceCategories
+	Saved           bool
+	Error           string
+}
+
+// GET /meals shows the upcoming meal registrations of the member.
+func (app *application) getMeals(w http.ResponseWriter, r *http.Request) {
+	app.renderMeals(w, r, mealsForm{})
+}
+
+// POST /meals registers the member for meals of a day.
+func (app *application) postMeals(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	upc, err := app.models.Users.GetPriceCategories(user.ID)
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get price categories of user: %v", err))
+		return
+	}
+
+	date, dateErr := time.Parse("2006-01-02", r.PostForm.Get("date"))
+	meals := r.PostForm["meal"]
+	priceCategory := r.PostForm.Get("price_category")
+	notes := strings.TrimSpace(r.PostForm.Get("notes"))
+
+	// the form is rendered again with its errors. That is a 200, htmx does
+	// not swap 4xx responses.
+	form := mealsForm{Date: date}
+	switch {
+	case dateErr != nil:
+		form.Error = "invalid date"
+	case date.Before(today()):
+		form.Error = "meals can be registered from today on"
+	case len(meals) == 0:
+		form.Error = "pick at least one meal"
+	case app.catalog.Snapshot().PriceCategoryIDMap[priceCategory] == 0 || !upc.Allows(priceCategory):
+		form.Error = "you may not pick this price category"
+	}
+	if form.Error != "" {
+		app.renderMeals(w, r, form)
+		return
+	}
+
+	err = app.models.MealRegistrations.Register(user.ID, date, meals, priceCategory, notes)
+	if err != nil {
+		if !errors.Is(err, models.ErrInvalidMeal) {
+			app.serverError(w, r, err)
+			return
+		}
+		form.Error = "pick breakfast, lunch or dinner"
+		app.renderMeals(w, r, form)
+		return
+	}
+
+	app.renderMeals(w, r, mealsForm{Date: date, Saved: true})
+}
+
+// POST /meals/{id}/cancel cancels a registration that did not become an
+// activity yet.
+func (app *application) postMealsIDCancel(w http.ResponseWriter, r *http.Request) {
+	registrationID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	if err := app.models.MealRegistrations.Cancel(user.ID, registrationID); err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.renderClientError(w, r, http.StatusNotFound)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.renderMeals(w, r, mealsForm{})
+}
+
+func (app *application) renderMeals(w http.ResponseWriter, r *http.Request, form mealsForm) {
+	var err error
+
+	t := app.newTemplateData(r)
+	t.Title = "Meals"
+
+	if form.Date.IsZero() {
+		form.Date = today().AddDate(0, 0, 1)
+	}
+	form.Meals = models.Meals
+
+	form.PriceCategories, err = app.models.Users.GetPriceCategories(t.User.ID)
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get price categories of user: %v", err))
+		return
+	}
+	t.Form = form
+
+	t.ViewModels.PriceCategories, err = app.models.PriceCategories.GetAll()
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get price categories: %v", err))
+		return
+	}
+
+	t.ViewModels.MealRegistrations, err = app.models.MealRegistrations.GetUpcomingForUser(t.User.ID, today())
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get meal registrations: %v", err))
+		return
+	}
+
+	app.render(w, r, http.StatusOK, "meals.tmpl.html", &t)
+}
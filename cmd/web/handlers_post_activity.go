@@ -9,6 +9,7 @@ import (
 	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/davidkuda/bellevue/internal/models"
@@ -24,10 +25,17 @@ type productForm struct {
 	Date        time.Time
 	Products    []parsedProduct
 	Comment     string
+	GuestName   string // of a visitor billed to UserID
 	FieldErrors map[string]string
+
+	// the recurring template that proposed the activity on DraftDate.
+	DraftTemplateID int
+	DraftDate       time.Time
+	TemplateSaved   bool
 }
 
 type parsedProduct struct {
+	ProductID     int // of the price valid on the date of the activity, see setPrices
 	Code          string
 	PriceCategory string
 	Price         int
@@ -45,11 +53,38 @@ func (app *application) bellevueActivityPost(w http.ResponseWriter, r *http.Requ
 	}
 
 	user := app.contextGetUser(r)
-	userID := user.ID // TODO: Deal with case where user is nil
+	userID, ok := billedUserID(r, user)
+	if !ok {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+	if userID != user.ID {
+		if _, err := app.models.Users.GetUserByID(userID); err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.renderClientError(w, r, http.StatusBadRequest)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+	}
 
-	formNew := app.parseProductForm(r)
+	formNew := parseProductForm(r, app.catalog.Snapshot())
 	formNew.UserID = userID
 
+	priceCategories, err := app.models.Users.GetPriceCategories(userID)
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get price categories of user: %v", err))
+		return
+	}
+	formNew.checkPriceCategories(priceCategories)
+
+	// the price of the day of the activity, also when editing an older one.
+	if err := app.setPrices(&formNew); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	// TODO: if ValidationErrors, return form with errors
 	if len(formNew.FieldErrors) > 0 {
 		t := app.newTemplateData(r)
@@ -71,19 +106,35 @@ func (app *application) bellevueActivityPost(w http.ResponseWriter, r *http.Requ
 	defer tx.Rollback()
 
 	activity := formNew.toActivity(userID)
+	activity.EnteredBy = user.ID
 	activityID, err := app.models.Activities.InsertWithTransaction(activity, tx)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
-	consumptions := formNew.toConsumptions(activityID, app.productIDMap)
+	consumptions := formNew.toConsumptions(activityID)
 	err = app.models.Consumptions.InsertManyWithTransaction(activityID, consumptions, tx)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
+	if formNew.DraftTemplateID != 0 {
+		err = app.models.ActivityTemplates.ConfirmDraftTx(userID, formNew.DraftTemplateID, formNew.DraftDate, activityID, tx)
+		if err != nil {
+			// e.g. confirmed from a second tab. The rollback drops the
+			// activity, the page shows the one of the first confirmation.
+			if errors.Is(err, models.ErrDraftAlreadyConfirmed) {
+				tx.Rollback()
+				app.getActivities(w, r)
+				return
+			}
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		app.serverError(w, r, fmt.Errorf("failed committing transaction: %s", err))
 		return
@@ -110,13 +161,29 @@ func (app *application) putActivitiesID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// TODO: define one way to get this and remove the TODO comments ...
-	user := app.contextGetUser(r)
-	userID := user.ID
+	// the activity stays with the member it was entered for.
+	stored, ok := app.getEditableActivity(w, r, activityID)
+	if !ok {
+		return
+	}
+	userID := stored.UserID
 
-	productForm := app.parseProductForm(r)
+	productForm := parseProductForm(r, app.catalog.Snapshot())
 	productForm.UserID = userID
 
+	priceCategories, err := app.models.Users.GetPriceCategories(userID)
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get price categories of user: %v", err))
+		return
+	}
+	productForm.checkPriceCategories(priceCategories)
+
+	// the price of the day of the activity, also when editing an older one.
+	if err := app.setPrices(&productForm); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	// TODO: if ValidationErrors, return form with errors
 	if len(productForm.FieldErrors) > 0 {
 		t := app.newTemplateData(r)
@@ -140,7 +207,7 @@ func (app *application) putActivitiesID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	consumptions := productForm.toConsumptions(activityID, app.productIDMap)
+	consumptions := productForm.toConsumptions(activityID)
 	err = app.models.Consumptions.InsertManyWithTransaction(activityID, consumptions, tx)
 	if err != nil {
 		app.serverError(w, r, err)
@@ -159,7 +226,9 @@ func (app *application) putActivitiesID(w http.ResponseWriter, r *http.Request)
 	app.getActivities(w, r)
 }
 
-func (app *application) parseProductForm(r *http.Request) productForm {
+// parseProductForm reads the form of the products of catalog. The caller
+// takes the snapshot once per request, see catalogStore.
+func parseProductForm(r *http.Request, catalog *catalogSnapshot) productForm {
 	form := productForm{}
 	form.FieldErrors = map[string]string{}
 
@@ -170,6 +239,26 @@ func (app *application) parseProductForm(r *http.Request) productForm {
 	}
 	form.Date = date
 
+	parseProducts(&form, r.FormValue, catalog)
+
+	form.Comment = r.PostForm.Get("comment")
+	form.GuestName = strings.TrimSpace(r.PostForm.Get("guest_name"))
+
+	// the draft of a recurring template is confirmed, see
+	// activityPrefill.
+	if id, err := strconv.Atoi(r.PostForm.Get("draft_template_id")); err == nil {
+		if draftDate, err := time.Parse("2006-01-02", r.PostForm.Get("draft_date")); err == nil {
+			form.DraftTemplateID = id
+			form.DraftDate = draftDate
+		}
+	}
+
+	return form
+}
+
+// parseProducts reads the products of catalog into form, with value
+// returning the form field of a name, e.g. activities[lunch][quantity].
+func parseProducts(form *productForm, value func(name string) string, catalog *catalogSnapshot) {
 	// NOTE: an alternative could be iterating over the key-value-pairs of the r.Form
 	// for key, values := range r.Form {
 	// 	for _, v := range values {
@@ -177,11 +266,11 @@ func (app *application) parseProductForm(r *http.Request) productForm {
 	// 	}
 	// }
 
-	for _, productFormSpec := range app.productFormConfig.Specs {
+	for _, productFormSpec := range catalog.ProductFormConfig.Specs {
 		var pp parsedProduct
 		pp.Code = productFormSpec.Code
 		if productFormSpec.HasCategories {
-			quantityStr := r.FormValue("activities[" + productFormSpec.Code + "][quantity]")
+			quantityStr := value("activities[" + productFormSpec.Code + "][quantity]")
 			quantityInt, err := strconv.Atoi(quantityStr)
 			if err != nil {
 				form.FieldErrors[productFormSpec.Code+"-Atoi"] = "input is not a number"
@@ -197,17 +286,16 @@ func (app *application) parseProductForm(r *http.Request) productForm {
 			pp.Quantity = quantityInt
 
 			pricecatFormField := fmt.Sprintf("activities[%s][price_category]", productFormSpec.Code)
-			pricecat := r.FormValue(pricecatFormField)
-			pcid := app.priceCategoryIDMap[pricecat]
+			pricecat := value(pricecatFormField)
+			pcid := catalog.PriceCategoryIDMap[pricecat]
 			if pcid == 0 {
 				form.FieldErrors[productFormSpec.Code+"-price-category"] = "invalid price category"
 			}
 			pp.PriceCategory = pricecat
-			pp.Price = app.productFormConfig.Prices[pp.Code+"/"+pricecat]
 		}
 
 		if productFormSpec.IsCustomAmount {
-			priceStr := r.FormValue("activities[" + productFormSpec.Code + "][amount_chf]")
+			priceStr := value("activities[" + productFormSpec.Code + "][amount_chf]")
 			// default input is 0, ignore if 0
 			if priceStr == "0" {
 				continue
@@ -232,10 +320,43 @@ func (app *application) parseProductForm(r *http.Request) productForm {
 		}
 		form.Products = append(form.Products, pp)
 	}
+}
 
-	form.Comment = r.PostForm.Get("comment")
+// setPrices looks up the products of the form with the prices valid on its
+// date. A product that did not exist yet on that date is a field error.
+func (app *application) setPrices(form *productForm) error {
+	if _, ok := form.FieldErrors["date"]; ok {
+		return nil
+	}
 
-	return form
+	products, err := app.models.Products.GetAllAt(form.Date)
+	if err != nil {
+		return fmt.Errorf("could not get products valid on %s: %v", form.Date.Format("2006-01-02"), err)
+	}
+
+	for i, pp := range form.Products {
+		p, ok := products.Get(pp.Code, pp.PriceCategory)
+		if !ok {
+			form.FieldErrors[pp.Code] = "not available on this date"
+			continue
+		}
+		form.Products[i].ProductID = p.ID
+		if p.Price.Valid {
+			form.Products[i].Price = int(p.Price.Int64)
+		}
+	}
+
+	return nil
+}
+
+// checkPriceCategories makes a price category that the user may not pick a
+// field error.
+func (pf *productForm) checkPriceCategories(upc models.UserPriceCategories) {
+	for _, p := range pf.Products {
+		if p.PriceCategory != "" && !upc.Allows(p.PriceCategory) {
+			pf.FieldErrors[p.Code+"-price-category"] = "price category not allowed"
+		}
+	}
 }
 
 func (p *productForm) toActivity(userID int) *models.Activity {
@@ -246,20 +367,63 @@ func (p *productForm) toActivity(userID int) *models.Activity {
 		comm = sql.NullString{String: p.Comment, Valid: true}
 	}
 	return &models.Activity{
-		UserID:  userID,
-		Date:    p.Date,
-		Comment: comm,
+		UserID:    userID,
+		GuestName: sql.NullString{String: p.GuestName, Valid: p.GuestName != ""},
+		Date:      p.Date,
+		Comment:   comm,
 	}
 }
 
-func (pf *productForm) toConsumptions(activityID int, productIDMap map[string]int) []models.Consumption {
-	var consumptions models.Consumptions
-	for _, p := range pf.Products {
-		var pricecat string
-		if p.PriceCategory != "" {
-			pricecat = "/" + p.PriceCategory
+// billedUserID returns the member that the activity of the form is for: the
+// member of the user_id field, which members and staff may set to anyone who
+// ate with them, or else the user. ok is false if the field is no ID. The
+// caller checks that the member exists.
+func billedUserID(r *http.Request, user *models.User) (userID int, ok bool) {
+	s := r.PostForm.Get("user_id")
+	if s == "" {
+		return user.ID, true
+	}
+	userID, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, false
+	}
+	return userID, true
+}
+
+// getEditableActivity returns the activity if the user may edit and delete
+// it: the member it is for, who entered it, and staff, as long as it is not
+// invoiced. Else it renders the error and ok is false.
+func (app *application) getEditableActivity(w http.ResponseWriter, r *http.Request, activityID int) (activity models.Activity, ok bool) {
+	user := app.contextGetUser(r)
+
+	activity, err := app.models.Activities.Get(activityID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.renderClientError(w, r, http.StatusNotFound)
+		} else {
+			app.serverError(w, r, fmt.Errorf("could not get activity: %v", err))
 		}
+		return activity, false
+	}
+
+	if activity.UserID != user.ID && activity.EnteredBy != user.ID && !isStaffUser(user) {
+		app.renderClientError(w, r, http.StatusForbidden)
+		return activity, false
+	}
+
+	// an invoice is corrected by cancelling it, see
+	// postSettingsInvoicesIDCancel.
+	if activity.InvoiceID.Valid {
+		app.renderClientError(w, r, http.StatusConflict)
+		return activity, false
+	}
 
+	return activity, true
+}
+
+func (pf *productForm) toConsumptions(activityID int) []models.Consumption {
+	var consumptions models.Consumptions
+	for _, p := range pf.Products {
 		price := p.Price
 		if price == 0 {
 			price = p.AmountCHF
@@ -267,7 +431,7 @@ func (pf *productForm) toConsumptions(activityID int, productIDMap map[string]in
 
 		consumption := models.Consumption{
 			ActivityID: activityID,
-			ProductID:  productIDMap[p.Code+pricecat],
+			ProductID:  p.ProductID,
 			UnitPrice:  price,
 			Quantity:   p.Quantity,
 		}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/davidkuda/bellevue/internal/models"
+	"github.com/davidkuda/bellevue/internal/reconcile"
+)
+
+type bankImportForm struct {
+	Result *reconcile.Result
+	Error  string
+}
+
+// GET /settings/bank-transactions
+func (app *application) getSettingsBankTransactions(w http.ResponseWriter, r *http.Request) {
+	app.renderSettingsBankTransactions(w, r, http.StatusOK, bankImportForm{})
+}
+
+// POST /settings/bank-transactions: upload of one or more camt files.
+func (app *application) postSettingsBankTransactions(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form := bankImportForm{Result: &reconcile.Result{}}
+	for _, fh := range r.MultipartForm.File["camt"] {
+		f, err := fh.Open()
+		if err != nil {
+			app.serverError(w, r, fmt.Errorf("could not open uploaded file %s: %v", fh.Filename, err))
+			return
+		}
+
+		res, err := app.reconciler().Import(f)
+		f.Close()
+		if err != nil {
+			log.Printf("could not import %s: %v", fh.Filename, err)
+			form.Error = fmt.Sprintf("%s: %v", fh.Filename, err)
+			app.renderSettingsBankTransactions(w, r, http.StatusUnprocessableEntity, form)
+			return
+		}
+
+		form.Result.Imported += res.Imported
+		form.Result.Duplicates += res.Duplicates
+		form.Result.Skipped += res.Skipped
+		form.Result.Matched += res.Matched
+		form.Result.Unmatched += res.Unmatched
+	}
+
+	app.renderSettingsBankTransactions(w, r, http.StatusOK, form)
+}
+
+// POST /settings/bank-transactions/{id}/match
+func (app *application) postSettingsBankTransactionsIDMatch(w http.ResponseWriter, r *http.Request) {
+	transactionID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	invoiceID, err := strconv.Atoi(r.PostForm.Get("invoice_id"))
+	if err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	admin := app.contextGetUser(r)
+	err = app.reconciler().MatchManually(transactionID, invoiceID, admin.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.renderClientError(w, r, http.StatusNotFound)
+		case errors.Is(err, models.ErrInvalidTransition):
+			app.renderClientError(w, r, http.StatusUnprocessableEntity)
+		default:
+			app.serverError(w, r, fmt.Errorf("could not match bank transaction %d to invoice %d: %v", transactionID, invoiceID, err))
+		}
+		return
+	}
+
+	app.renderSettingsBankTransactions(w, r, http.StatusOK, bankImportForm{})
+}
+
+// POST /settings/bank-transactions/{id}/ignore
+func (app *application) postSettingsBankTransactionsIDIgnore(w http.ResponseWriter, r *http.Request) {
+	transactionID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	err = app.models.BankTransactions.SetStatus(transactionID, models.BankTransactionIgnored)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.renderClientError(w, r, http.StatusNotFound)
+			return
+		}
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.renderSettingsBankTransactions(w, r, http.StatusOK, bankImportForm{})
+}
+
+func (app *application) renderSettingsBankTransactions(w http.ResponseWriter, r *http.Request, status int, form bankImportForm) {
+	var err error
+
+	t := app.newTemplateData(r)
+	t.Title = "Bank Transactions"
+	t.Form = form
+
+	t.ViewModels.UnmatchedBankTransactions, err = app.models.BankTransactions.GetByStatus(models.BankTransactionUnmatched, 200)
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get unmatched bank transactions: %v", err))
+		return
+	}
+
+	t.ViewModels.MatchedBankTransactions, err = app.models.BankTransactions.GetByStatus(models.BankTransactionMatched, 50)
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get matched bank transactions: %v", err))
+		return
+	}
+
+	t.ViewModels.OpenInvoices, err = app.models.InvoicesV2.GetOpen()
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get open invoices: %v", err))
+		return
+	}
+
+	app.render(w, r, status, "settings.bank-transactions.tmpl.html", &t)
+}
+
+func (app *application) reconciler() reconcile.Importer {
+	return reconcile.Importer{
+		DB:     app.db,
+		Models: app.models,
+		IBAN:   app.EmailConfig.Recipient.IBAN,
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/davidkuda/bellevue/internal/models"
+)
+
+type householdsForm struct {
+	HouseholdID int // of the household the error belongs to, 0 for a new one
+	Error       string
+}
+
+// GET /settings/households
+func (app *application) getSettingsHouseholds(w http.ResponseWriter, r *http.Request) {
+	app.renderSettingsHouseholds(w, r, householdsForm{})
+}
+
+// POST /settings/households creates a household with its billing contact.
+func (app *application) postSettingsHouseholds(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(r.PostForm.Get("name"))
+	userID, _ := strconv.Atoi(r.PostForm.Get("user_id"))
+
+	// the form is rendered again with its errors. That is a 200, htmx does
+	// not swap 4xx responses.
+	var form householdsForm
+	switch {
+	case name == "":
+		form.Error = "name the household"
+	case userID == 0:
+		form.Error = "pick the billing contact"
+	}
+	if form.Error != "" {
+		app.renderSettingsHouseholds(w, r, form)
+		return
+	}
+
+	_, err := app.models.Households.Insert(name, userID)
+	app.householdChanged(w, r, 0, err)
+}
+
+// POST /settings/households/{id}/members adds a member.
+func (app *application) postSettingsHouseholdsIDMembers(w http.ResponseWriter, r *http.Request) {
+	app.updateHousehold(w, r, app.models.Households.AddMember)
+}
+
+// POST /settings/households/{id}/members/remove removes a member.
+func (app *application) postSettingsHouseholdsIDMembersRemove(w http.ResponseWriter, r *http.Request) {
+	app.updateHousehold(w, r, app.models.Households.RemoveMember)
+}
+
+// POST /settings/households/{id}/billing-contact
+func (app *application) postSettingsHouseholdsIDBillingContact(w http.ResponseWriter, r *http.Request) {
+	app.updateHousehold(w, r, app.models.Households.SetBillingContact)
+}
+
+// POST /settings/households/{id}/delete
+func (app *application) postSettingsHouseholdsIDDelete(w http.ResponseWriter, r *http.Request) {
+	householdID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	err = app.models.Households.Delete(householdID)
+	app.householdChanged(w, r, householdID, err)
+}
+
+// updateHousehold calls update with the household of the path and the
+// user_id of the form.
+func (app *application) updateHousehold(w http.ResponseWriter, r *http.Request, update func(householdID, userID int) error) {
+	householdID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(r.PostForm.Get("user_id"))
+	if err != nil {
+		app.renderHouseholdError(w, r, householdID, "pick a member")
+		return
+	}
+
+	err = update(householdID, userID)
+	app.householdChanged(w, r, householdID, err)
+}
+
+// householdChanged renders the households after a change, with the error
+// of the change if it was not allowed.
+func (app *application) householdChanged(w http.ResponseWriter, r *http.Request, householdID int, err error) {
+	switch {
+	case err == nil:
+		app.renderSettingsHouseholds(w, r, householdsForm{})
+	case errors.Is(err, models.ErrAlreadyInHousehold):
+		app.renderHouseholdError(w, r, householdID, "this member is already in a household")
+	case errors.Is(err, models.ErrBillingContact):
+		app.renderHouseholdError(w, r, householdID, "the billing contact must be a member of the household and cannot be removed")
+	case errors.Is(err, models.ErrNoRecord):
+		app.renderClientError(w, r, http.StatusNotFound)
+	default:
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) renderHouseholdError(w http.ResponseWriter, r *http.Request, householdID int, msg string) {
+	app.renderSettingsHouseholds(w, r, householdsForm{HouseholdID: householdID, Error: msg})
+}
+
+func (app *application) renderSettingsHouseholds(w http.ResponseWriter, r *http.Request, form householdsForm) {
+	var err error
+
+	t := app.newTemplateData(r)
+	t.Title = "Households"
+	t.Form = form
+
+	t.ViewModels.Households, err = app.models.Households.GetAll()
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get households: %v", err))
+		return
+	}
+
+	users, err := app.models.Users.GetAll()
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get users: %v", err))
+		return
+	}
+
+	// only members without a household can be added to one.
+	inHousehold := map[int]bool{}
+	for _, h := range t.ViewModels.Households {
+		for _, u := range h.Members {
+			inHousehold[u.ID] = true
+		}
+	}
+	for _, u := range users {
+		if !inHousehold[u.ID] {
+			t.ViewModels.WithoutHousehold = append(t.ViewModels.WithoutHousehold, u)
+		}
+	}
+
+	app.render(w, r, http.StatusOK, "settings.households.tmpl.html", &t)
+}
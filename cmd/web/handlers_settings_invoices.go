@@ -0,0 +1,208 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/davidkuda/bellevue/internal/email"
+	"github.com/davidkuda/bellevue/internal/models"
+	"github.com/davidkuda/bellevue/internal/viewmodels"
+)
+
+// GET /settings/invoices
+func (app *application) getSettingsInvoices(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+
+	invoices, err := app.viewmodels.Invoices.GetAll(status)
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get invoices: %v", err))
+		return
+	}
+
+	// cancelling needs a reason, see postSettingsInvoicesIDCancel.
+	for i := range invoices {
+		for _, next := range models.NextInvoiceStatuses(invoices[i].Status) {
+			if next == models.InvoiceStatusCancelled {
+				invoices[i].Cancellable = true
+				continue
+			}
+			invoices[i].NextStatuses = append(invoices[i].NextStatuses, next)
+		}
+	}
+
+	t := app.newTemplateData(r)
+	t.Title = "Invoices"
+	t.ViewModels.AdminInvoices = invoices
+
+	app.render(w, r, http.StatusOK, "settings.invoices.tmpl.html", &t)
+}
+
+// POST /settings/invoices/{id}/status
+func (app *application) postSettingsInvoicesIDStatus(w http.ResponseWriter, r *http.Request) {
+	invoiceID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	admin := app.contextGetUser(r)
+	status := r.PostForm.Get("status")
+	if status == models.InvoiceStatusCancelled {
+		app.renderClientError(w, r, http.StatusUnprocessableEntity)
+		return
+	}
+
+	// an admin marking an invoice as paid received the money, e.g. in cash.
+	if status == models.InvoiceStatusPaid {
+		err = app.payInvoice(invoiceID, admin.ID)
+	} else {
+		err = app.models.InvoicesV2.SetStatus(invoiceID, status, admin.ID)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.renderClientError(w, r, http.StatusNotFound)
+		case errors.Is(err, models.ErrInvalidTransition):
+			app.renderClientError(w, r, http.StatusUnprocessableEntity)
+		default:
+			app.serverError(w, r, fmt.Errorf("could not set status of invoice %d to %s: %v", invoiceID, status, err))
+		}
+		return
+	}
+
+	app.getSettingsInvoices(w, r)
+}
+
+// payInvoice books a manual payment over the open amount of the invoice.
+func (app *application) payInvoice(invoiceID, adminID int) error {
+	tx, err := app.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := app.models.Payments.PayInvoiceTx(invoiceID, adminID, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+const (
+	cancelModeRelease    = "release"
+	cancelModeCreditNote = "credit-note"
+)
+
+// POST /settings/invoices/{id}/cancel
+//
+// Cancels the invoice with a reason. Its activities are either released to
+// be invoiced again or reversed with a credit note. If the member got the
+// invoice, an email about the correction goes to the outbox.
+func (app *application) postSettingsInvoicesIDCancel(w http.ResponseWriter, r *http.Request) {
+	invoiceID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	admin := app.contextGetUser(r)
+	reason := strings.TrimSpace(r.PostForm.Get("reason"))
+	mode := r.PostForm.Get("mode")
+	if reason == "" || (mode != cancelModeRelease && mode != cancelModeCreditNote) {
+		app.renderClientError(w, r, http.StatusUnprocessableEntity)
+		return
+	}
+
+	invoice, err := app.models.InvoicesV2.Get(invoiceID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.renderClientError(w, r, http.StatusNotFound)
+			return
+		}
+		app.serverError(w, r, fmt.Errorf("could not get invoice %d: %v", invoiceID, err))
+		return
+	}
+	notify := invoice.Status == models.InvoiceStatusSent
+
+	// the activities are gone from the invoice after releasing them, so the
+	// email is prepared before.
+	var user models.User
+	var viewInvoice *viewmodels.Invoice
+	if notify {
+		user, err = app.models.Users.GetUserByID(invoice.UserID)
+		if err != nil {
+			app.serverError(w, r, fmt.Errorf("could not get user %d: %v", invoice.UserID, err))
+			return
+		}
+		viewInvoice, err = app.viewmodels.Activities.GetInvoiceForUser(invoice.ID, invoice.UserID)
+		if err != nil {
+			app.serverError(w, r, fmt.Errorf("could not get invoice invoiceID=%d: %v", invoice.ID, err))
+			return
+		}
+	}
+
+	tx, err := app.db.Begin()
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("failed starting transaction: %v", err))
+		return
+	}
+	defer tx.Rollback()
+
+	err = app.models.InvoicesV2.CancelTx(invoiceID, reason, admin.ID, tx)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidTransition) {
+			app.renderClientError(w, r, http.StatusUnprocessableEntity)
+			return
+		}
+		app.serverError(w, r, fmt.Errorf("could not cancel invoice %d: %v", invoiceID, err))
+		return
+	}
+
+	var creditNote *models.CreditNote
+	switch mode {
+	case cancelModeRelease:
+		_, err = app.models.InvoicesV2.ReleaseActivitiesTx(invoiceID, tx)
+	case cancelModeCreditNote:
+		var n models.CreditNote
+		n, err = app.models.CreditNotes.InsertTx(invoiceID, reason, admin.ID, tx)
+		creditNote = &n
+	}
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not %s invoice %d: %v", mode, invoiceID, err))
+		return
+	}
+
+	if notify {
+		invoice.CancellationReason = reason
+		msg, err := email.RenderCancellation(app.EmailConfig, &user, &invoice, viewInvoice, creditNote)
+		if err != nil {
+			app.serverError(w, r, fmt.Errorf("could not render cancellation of invoice %d: %v", invoiceID, err))
+			return
+		}
+		if err := app.enqueueTx(models.OutboxKindCancellation, msg, invoiceID, tx); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		app.serverError(w, r, fmt.Errorf("failed committing transaction: %v", err))
+		return
+	}
+	app.wakeOutbox()
+
+	app.getSettingsInvoices(w, r)
+}
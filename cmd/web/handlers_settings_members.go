@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/davidkuda/bellevue/internal/models"
+)
+
+// member is a row in the overview of /settings/members.
+type member struct {
+	models.User
+	PriceCategories models.UserPriceCategories
+}
+
+type membersForm struct {
+	UserID int // of the row the error belongs to
+	Error  string
+}
+
+// GET /settings/members
+func (app *application) getSettingsMembers(w http.ResponseWriter, r *http.Request) {
+	app.renderSettingsMembers(w, r, membersForm{})
+}
+
+// POST /settings/members/{id}/price-categories sets the price categories the
+// member may pick and the default one.
+func (app *application) postSettingsMembersIDPriceCategories(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	all, err := app.models.PriceCategories.GetAll()
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get price categories: %v", err))
+		return
+	}
+
+	upc := models.UserPriceCategories{
+		UserID:  userID,
+		Default: r.PostForm.Get("default"),
+	}
+	for _, pc := range all {
+		if slices.Contains(r.PostForm["allowed"], pc.Name) {
+			upc.Allowed = append(upc.Allowed, pc.Name)
+		}
+	}
+
+	// the form is rendered again with its errors. That is a 200, htmx does
+	// not swap 4xx responses.
+	form := membersForm{UserID: userID}
+	if len(upc.Allowed) == 0 {
+		form.Error = "allow at least one price category"
+		app.renderSettingsMembers(w, r, form)
+		return
+	}
+	// all of them, also the ones added later.
+	if len(upc.Allowed) == len(all) {
+		upc.Allowed = nil
+	}
+
+	if err := app.models.Users.SetPriceCategories(upc); err != nil {
+		switch {
+		case errors.Is(err, models.ErrPriceCategoryNotAllowed):
+			form.Error = "the default price category must be allowed"
+			app.renderSettingsMembers(w, r, form)
+		case errors.Is(err, models.ErrNoRecord):
+			app.renderClientError(w, r, http.StatusNotFound)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.renderSettingsMembers(w, r, membersForm{})
+}
+
+// POST /settings/members/{id}/role makes the member staff, who may edit the
+// activities of any member, or a member again.
+func (app *application) postSettingsMembersIDRole(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	role := r.PostForm.Get("role")
+	if role != models.RoleMember && role != models.RoleStaff {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	if err := app.models.Users.SetRole(userID, role); err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.renderClientError(w, r, http.StatusNotFound)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.renderSettingsMembers(w, r, membersForm{})
+}
+
+func (app *application) renderSettingsMembers(w http.ResponseWriter, r *http.Request, form membersForm) {
+	users, err := app.models.Users.GetAll()
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get users: %v", err))
+		return
+	}
+
+	priceCategories, err := app.models.Users.GetAllPriceCategories()
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get price categories of users: %v", err))
+		return
+	}
+
+	t := app.newTemplateData(r)
+	t.Title = "Members"
+	t.Form = form
+
+	t.ViewModels.PriceCategories, err = app.models.PriceCategories.GetAll()
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get price categories: %v", err))
+		return
+	}
+
+	for _, u := range users {
+		t.ViewModels.Members = append(t.ViewModels.Members, member{
+			User:            u,
+			PriceCategories: priceCategories[u.ID],
+		})
+	}
+
+	app.render(w, r, http.StatusOK, "settings.members.tmpl.html", &t)
+}
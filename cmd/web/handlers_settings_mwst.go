@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/davidkuda/bellevue/internal/viewmodels"
+)
+
+// GET /settings/mwst?period=2026-Q1
+func (app *application) getSettingsMWST(w http.ResponseWriter, r *http.Request) {
+	report, ok := app.mwstReport(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+
+	t := app.newTemplateData(r)
+	t.Title = "MWST"
+	t.ViewModels.MWSTReport = report
+	t.ViewModels.TaxPeriods = viewmodels.TaxPeriods(now.Year(), now.Year()-1)
+
+	app.render(w, r, http.StatusOK, "settings.mwst.tmpl.html", &t)
+}
+
+// GET /settings/mwst.csv?period=2026-Q1
+//
+// Semicolon separated, as Excel with a Swiss locale expects it.
+func (app *application) getSettingsMWSTCSV(w http.ResponseWriter, r *http.Request) {
+	report, ok := app.mwstReport(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="mwst-%s.csv"`, report.Period.Name))
+
+	cw := csv.NewWriter(w)
+	cw.Comma = ';'
+
+	cw.Write([]string{"period", "tax_code", "tax_rate", "account_code", "account_name", "net", "vat", "gross"})
+	for _, row := range report.Rows {
+		cw.Write([]string{
+			report.Period.Name,
+			row.TaxCode,
+			row.Percent(),
+			strconv.Itoa(row.AccountCode),
+			row.AccountName,
+			formatCurrency(row.Net),
+			formatCurrency(row.VAT),
+			formatCurrency(row.Gross),
+		})
+	}
+	for _, total := range report.Totals {
+		cw.Write([]string{
+			report.Period.Name,
+			total.TaxCode,
+			total.Percent(),
+			"",
+			"Total",
+			formatCurrency(total.Net),
+			formatCurrency(total.VAT),
+			formatCurrency(total.Gross),
+		})
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		app.serverError(w, r, fmt.Errorf("could not write mwst csv: %v", err))
+	}
+}
+
+// mwstReport reads the period from the query, defaulting to the last
+// completed quarter. It writes the error response if ok is false.
+func (app *application) mwstReport(w http.ResponseWriter, r *http.Request) (report *viewmodels.MWSTReport, ok bool) {
+	period := viewmodels.LastTaxPeriod(time.Now())
+	if s := r.URL.Query().Get("period"); s != "" {
+		var err error
+		period, err = viewmodels.ParseTaxPeriod(s)
+		if err != nil {
+			app.renderClientError(w, r, http.StatusBadRequest)
+			return nil, false
+		}
+	}
+
+	report, err := app.viewmodels.Reports.MWST(period)
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get mwst report for %s: %v", period.Name, err))
+		return nil, false
+	}
+
+	return report, true
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/davidkuda/bellevue/internal/models"
+)
+
+// GET /settings/outbox
+func (app *application) getSettingsOutbox(w http.ResponseWriter, r *http.Request) {
+	var err error
+
+	t := app.newTemplateData(r)
+	t.Title = "Outbox"
+
+	t.ViewModels.FailedMessages, err = app.models.Outbox.GetByStatus(models.OutboxFailed, 100)
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get failed messages: %v", err))
+		return
+	}
+
+	t.ViewModels.PendingMessages, err = app.models.Outbox.GetByStatus(models.OutboxPending, 100)
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get pending messages: %v", err))
+		return
+	}
+
+	app.render(w, r, http.StatusOK, "settings.outbox.tmpl.html", &t)
+}
+
+// POST /settings/outbox/{id}/resend
+func (app *application) postSettingsOutboxIDResend(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	if err := app.models.Outbox.Resend(id); err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.renderClientError(w, r, http.StatusNotFound)
+			return
+		}
+		app.serverError(w, r, err)
+		return
+	}
+	app.wakeOutbox()
+
+	app.getSettingsOutbox(w, r)
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davidkuda/bellevue/internal/models"
+)
+
+// productCatalogForm is the form of /settings/products/new and
+// /settings/products/edit/{code...}.
+type productCatalogForm struct {
+	OldCode     string // "" for a new product
+	Product     models.CatalogProduct
+	ValidFrom   time.Time // of changed prices
+	FieldErrors map[string]string
+}
+
+// Price returns the price of category in CHF, "" if the product has none.
+func (f productCatalogForm) Price(category string) string {
+	price, ok := f.Product.Prices[category]
+	if !ok {
+		return ""
+	}
+	return formatCurrency(price)
+}
+
+// GET /settings/products
+func (app *application) getSettingsProducts(w http.ResponseWriter, r *http.Request) {
+	var err error
+
+	t := app.newTemplateData(r)
+	t.Title = "Products"
+
+	t.ViewModels.CatalogProducts, err = app.models.Products.GetCatalog()
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get products: %v", err))
+		return
+	}
+
+	t.ViewModels.CatalogOptions, err = app.models.Products.GetCatalogOptions()
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get catalog options: %v", err))
+		return
+	}
+
+	app.render(w, r, http.StatusOK, "settings.products.tmpl.html", &t)
+}
+
+// GET /settings/products/new
+func (app *application) getSettingsProductsNew(w http.ResponseWriter, r *http.Request) {
+	form := productCatalogForm{
+		Product: models.CatalogProduct{
+			PricingMode: models.PricingModeFixed,
+			Prices:      map[string]int{},
+		},
+		ValidFrom:   time.Now(),
+		FieldErrors: map[string]string{},
+	}
+	app.renderSettingsProduct(w, r, http.StatusOK, form)
+}
+
+// GET /settings/products/edit/{code...}
+func (app *application) getSettingsProductsEdit(w http.ResponseWriter, r *http.Request) {
+	code := r.PathValue("code")
+
+	p, err := app.models.Products.GetCatalogProduct(code)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.renderClientError(w, r, http.StatusNotFound)
+			return
+		}
+		app.serverError(w, r, err)
+		return
+	}
+
+	form := productCatalogForm{
+		OldCode:     code,
+		Product:     p,
+		ValidFrom:   time.Now(),
+		FieldErrors: map[string]string{},
+	}
+	app.renderSettingsProduct(w, r, http.StatusOK, form)
+}
+
+// POST /settings/products
+func (app *application) postSettingsProducts(w http.ResponseWriter, r *http.Request) {
+	app.saveSettingsProduct(w, r, "")
+}
+
+// POST /settings/products/edit/{code...}
+func (app *application) postSettingsProductsEdit(w http.ResponseWriter, r *http.Request) {
+	app.saveSettingsProduct(w, r, r.PathValue("code"))
+}
+
+// saveSettingsProduct creates the product of the form, or updates the one
+// with oldCode. The activity form shows the change right away.
+func (app *application) saveSettingsProduct(w http.ResponseWriter, r *http.Request, oldCode string) {
+	if err := r.ParseForm(); err != nil {
+		app.renderClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	opts, err := app.models.Products.GetCatalogOptions()
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get catalog options: %v", err))
+		return
+	}
+
+	form := parseProductCatalogForm(r, opts)
+	form.OldCode = oldCode
+
+	// the form is rendered again with its errors. That is a 200, htmx does
+	// not swap 4xx responses.
+	for field, msg := range form.Product.Validate(opts) {
+		form.FieldErrors[field] = msg
+	}
+	if len(form.FieldErrors) > 0 {
+		app.renderSettingsProduct(w, r, http.StatusOK, form)
+		return
+	}
+
+	err = app.models.Products.SaveCatalogProduct(oldCode, form.Product, form.ValidFrom)
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateProductCode) {
+			form.FieldErrors["code"] = "another product has this code"
+			app.renderSettingsProduct(w, r, http.StatusOK, form)
+			return
+		}
+		app.serverError(w, r, fmt.Errorf("could not save product %s: %v", form.Product.Code, err))
+		return
+	}
+
+	// the change is saved; if this fails, the catalogWatcher picks it up.
+	if err := app.catalog.refresh(); err != nil {
+		log.Printf("could not refresh catalog: %v", err)
+	}
+
+	app.getSettingsProducts(w, r)
+}
+
+// POST /settings/products/delete/{code...}
+func (app *application) postSettingsProductsDelete(w http.ResponseWriter, r *http.Request) {
+	app.setProductDeleted(w, r, app.models.Products.DeleteCatalogProduct)
+}
+
+// POST /settings/products/restore/{code...}
+func (app *application) postSettingsProductsRestore(w http.ResponseWriter, r *http.Request) {
+	app.setProductDeleted(w, r, app.models.Products.RestoreCatalogProduct)
+}
+
+func (app *application) setProductDeleted(w http.ResponseWriter, r *http.Request, update func(code string) error) {
+	if err := update(r.PathValue("code")); err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.renderClientError(w, r, http.StatusNotFound)
+			return
+		}
+		app.serverError(w, r, err)
+		return
+	}
+
+	// the change is saved; if this fails, the catalogWatcher picks it up.
+	if err := app.catalog.refresh(); err != nil {
+		log.Printf("could not refresh catalog: %v", err)
+	}
+
+	app.getSettingsProducts(w, r)
+}
+
+func (app *application) renderSettingsProduct(w http.ResponseWriter, r *http.Request, status int, form productCatalogForm) {
+	var err error
+
+	t := app.newTemplateData(r)
+	t.Title = "Product"
+	t.Form = form
+
+	t.ViewModels.CatalogOptions, err = app.models.Products.GetCatalogOptions()
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get catalog options: %v", err))
+		return
+	}
+
+	app.render(w, r, status, "settings.product.tmpl.html", &t)
+}
+
+// parseProductCatalogForm reads the fields of the product form. Prices are in
+// CHF, in fields named price[<price category>]; an empty price removes the
+// category from the product.
+func parseProductCatalogForm(r *http.Request, opts models.CatalogOptions) productCatalogForm {
+	form := productCatalogForm{
+		FieldErrors: map[string]string{},
+	}
+
+	p := &form.Product
+	p.Name = strings.TrimSpace(r.PostForm.Get("name"))
+	p.Code = strings.TrimSpace(r.PostForm.Get("code"))
+	p.PricingMode = r.PostForm.Get("pricing_mode")
+	p.FinancialAccountID, _ = strconv.Atoi(r.PostForm.Get("financial_account_id"))
+	p.TaxID, _ = strconv.Atoi(r.PostForm.Get("tax_id"))
+
+	if s := r.PostForm.Get("sort_order"); s != "" {
+		order, err := strconv.Atoi(s)
+		if err != nil {
+			form.FieldErrors["sort_order"] = "sort order is a number"
+		}
+		p.SortOrder = sql.NullInt32{Int32: int32(order), Valid: err == nil}
+	}
+
+	p.Prices = map[string]int{}
+	if p.PricingMode == models.PricingModeFixed {
+		for _, pc := range opts.PriceCategories {
+			s := strings.TrimSpace(r.PostForm.Get("price[" + pc.Name + "]"))
+			if s == "" {
+				continue
+			}
+			chf, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				form.FieldErrors["prices"] = fmt.Sprintf("invalid price for %s", pc.Name)
+				continue
+			}
+			p.Prices[pc.Name] = int(math.Round(chf * 100))
+		}
+	}
+
+	form.ValidFrom = time.Now()
+	if s := r.PostForm.Get("valid_from"); s != "" {
+		date, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			form.FieldErrors["valid_from"] = "invalid date"
+		}
+		form.ValidFrom = date
+	}
+
+	return form
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/davidkuda/bellevue/internal/email"
+	"github.com/davidkuda/bellevue/internal/models"
+)
+
+// overdueInvoice is a row in the overview of /settings/reminders.
+type overdueInvoice struct {
+	models.OverdueInvoice
+	Days      int
+	NextLevel int // 0 if no reminder is due
+}
+
+type remindersForm struct {
+	Result *reminderResult
+	Error  string
+}
+
+type reminderResult struct {
+	Sent   int
+	Failed int
+}
+
+// GET /settings/reminders
+func (app *application) getSettingsReminders(w http.ResponseWriter, r *http.Request) {
+	app.renderSettingsReminders(w, r, http.StatusOK, remindersForm{})
+}
+
+// POST /settings/reminders: sends all due reminders now, without waiting
+// for the scheduler.
+func (app *application) postSettingsReminders(w http.ResponseWriter, r *http.Request) {
+	res, err := app.sendReminders()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	form := remindersForm{Result: &res}
+	if res.Failed > 0 {
+		form.Error = fmt.Sprintf("%d reminders could not be sent, see the logs", res.Failed)
+	}
+
+	app.renderSettingsReminders(w, r, http.StatusOK, form)
+}
+
+func (app *application) renderSettingsReminders(w http.ResponseWriter, r *http.Request, status int, form remindersForm) {
+	levels := app.EmailConfig.DunningLevels
+
+	overdue, err := app.models.Reminders.GetOverdue(levels[0].After)
+	if err != nil {
+		app.serverError(w, r, fmt.Errorf("could not get overdue invoices: %v", err))
+		return
+	}
+
+	now := time.Now()
+	rows := make([]overdueInvoice, 0, len(overdue))
+	for _, o := range overdue {
+		row := overdueInvoice{OverdueInvoice: o, Days: o.Days(now)}
+		if next, ok := models.NextReminder(levels, o, now); ok {
+			row.NextLevel = next.Level
+		}
+		rows = append(rows, row)
+	}
+
+	t := app.newTemplateData(r)
+	t.Title = "Reminders"
+	t.Form = form
+	t.ViewModels.OverdueInvoices = rows
+	t.ViewModels.DunningLevels = levels
+
+	app.render(w, r, status, "settings.reminders.tmpl.html", &t)
+}
+
+// sendReminders sends the next due reminder for every overdue invoice.
+// Reminders that could not be queued are logged and counted, they are
+// retried on the next run.
+func (app *application) sendReminders() (reminderResult, error) {
+	var res reminderResult
+	levels := app.EmailConfig.DunningLevels
+
+	overdue, err := app.models.Reminders.GetOverdue(levels[0].After)
+	if err != nil {
+		return res, fmt.Errorf("could not get overdue invoices: %v", err)
+	}
+
+	now := time.Now()
+	for _, o := range overdue {
+		level, ok := models.NextReminder(levels, o, now)
+		if !ok {
+			continue
+		}
+
+		sent, err := app.sendReminder(o, level)
+		if err != nil {
+			log.Printf("could not send reminder level=%d for invoice %d to userID=%d: %v", level.Level, o.InvoiceID, o.UserID, err)
+			res.Failed++
+			continue
+		}
+		if sent {
+			res.Sent++
+		}
+	}
+
+	return res, nil
+}
+
+// sendReminder records one reminder and puts its email into the outbox, in
+// one transaction. sent is false if the reminder was already recorded by a
+// concurrent run.
+func (app *application) sendReminder(o models.OverdueInvoice, level models.DunningLevel) (sent bool, err error) {
+	user, err := app.models.Users.GetUserByID(o.UserID)
+	if err != nil {
+		return false, fmt.Errorf("could not get user: %v", err)
+	}
+
+	invoice, err := app.models.InvoicesV2.Get(o.InvoiceID)
+	if err != nil {
+		return false, fmt.Errorf("could not get invoice: %v", err)
+	}
+
+	viewInvoice, err := app.viewmodels.Activities.GetInvoiceForUser(o.InvoiceID, o.UserID)
+	if err != nil {
+		return false, fmt.Errorf("could not get view invoice: %v", err)
+	}
+
+	tx, err := app.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	ok, err := app.models.Reminders.InsertTx(o.InvoiceID, level.Level, o.Open(), tx)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	msg, err := email.RenderReminder(app.EmailConfig, &user, &invoice, viewInvoice, &o, level)
+	if err != nil {
+		return false, err
+	}
+
+	if err := app.enqueueTx(models.OutboxKindReminder, msg, o.InvoiceID, tx); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed committing transaction: %v", err)
+	}
+	app.wakeOutbox()
+
+	log.Printf("queued reminder level=%d for invoice %d to userID=%d", level.Level, o.InvoiceID, o.UserID)
+	return true, nil
+}
+
+// remindersScheduler sends the due reminders every interval, see the
+// -reminder-interval flag.
+func (app *application) remindersScheduler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		res, err := app.sendReminders()
+		if err != nil {
+			log.Printf("could not send reminders: %v", err)
+			continue
+		}
+		if res.Sent > 0 || res.Failed > 0 {
+			log.Printf("reminders: sent %d, failed %d", res.Sent, res.Failed)
+		}
+	}
+}
@@ -68,3 +68,20 @@ func isSameDay(a, b time.Time) bool {
 		a.Month() == b.Month() &&
 		a.Day() == b.Day()
 }
+
+// today returns the date of today, at midnight UTC like the dates parsed
+// from forms.
+func today() time.Time {
+	t, _ := time.Parse("2006-01-02", time.Now().Format("2006-01-02"))
+	return t
+}
+
+// dateParam returns the date of the query parameter date, today if there is
+// none.
+func dateParam(r *http.Request) (time.Time, error) {
+	s := r.URL.Query().Get("date")
+	if s == "" {
+		return today(), nil
+	}
+	return time.Parse("2006-01-02", s)
+}
@@ -35,9 +35,9 @@ type application struct {
 	models     models.Models
 	viewmodels viewmodels.Models
 
-	productFormConfig  models.ProductFormConfig
-	priceCategoryIDMap models.PriceCategoryIDMap
-	productIDMap       models.ProductIDMap
+	// the products and price categories of the activity form, reloaded
+	// when the catalog changes.
+	catalog *catalogStore
 
 	templateCache map[string]*template.Template
 	OIDC          openIDConnect
@@ -51,6 +51,10 @@ type application struct {
 	}
 
 	EmailConfig email.EmailConfig
+	mailer      email.Mailer
+
+	// outboxWake wakes the outboxWorker after a message was enqueued.
+	outboxWake chan struct{}
 }
 
 var (
@@ -64,6 +68,8 @@ func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
 	addr := flag.String("addr", ":8875", "HTTP network address")
+	reminderInterval := flag.Duration("reminder-interval", 0, "send due payment reminders in this interval, e.g. 24h; 0 disables the scheduler")
+	catalogInterval := flag.Duration("catalog-interval", 30*time.Second, "check for changes of products and price categories in this interval")
 	flag.Parse()
 
 	cookieDomain := flag.String("cookie-domain", os.Getenv("COOKIE_DOMAIN"), "localhost or kuda.ai")
@@ -116,27 +122,29 @@ func main() {
 	app.sessionManager.Store = postgresstore.New(db)
 	app.sessionManager.Lifetime = 7 * 24 * time.Hour
 
-	app.productFormConfig, err = app.models.Products.GetProductFormConfig()
+	app.catalog, err = newCatalogStore(&app.models)
 	if err != nil {
-		log.Fatalf("could not load productFormConfig: %v\n", err)
+		log.Fatalf("could not load catalog: %v\n", err)
 	}
+	go app.catalogWatcher(*catalogInterval)
 
-	app.priceCategoryIDMap, err = app.models.PriceCategories.GetPriceCatMap()
+	app.templateCache, err = newTemplateCache()
 	if err != nil {
-		log.Fatalf("could not load app.priceCategoryMap: %v\n", err)
+		log.Fatalf("could not initialise templateCache: %v\n", err)
 	}
 
-	app.productIDMap, err = app.models.Products.GetProductIDMap()
+	app.EmailConfig = email.LoadConfigFromEnv()
+	app.mailer, err = email.NewMailer(app.EmailConfig)
 	if err != nil {
-		log.Fatalf("could not load app.productIDMap: %v\n", err)
+		log.Fatalf("could not initialise mailer: %v\n", err)
 	}
 
-	app.templateCache, err = newTemplateCache()
-	if err != nil {
-		log.Fatalf("could not initialise templateCache: %v\n", err)
-	}
+	app.outboxWake = make(chan struct{}, 1)
+	go app.outboxWorker(time.Minute)
 
-	app.EmailConfig = email.LoadConfigFromEnv()
+	if *reminderInterval > 0 {
+		go app.remindersScheduler(*reminderInterval)
+	}
 
 	log.Print(fmt.Sprintf("Starting web server, listening on %s", *addr))
 	mux := app.routes()
@@ -88,12 +88,19 @@ func (app *application) requireAdmin(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// TODO: Implement permissions management
 		user := app.contextGetUser(r)
-		if user == nil {
+		if !isAdminUser(user) {
 			app.renderClientError(w, r, http.StatusUnauthorized)
 			return
 		}
-		// TODO: right now, user 1 is the admin x)
-		if user.ID != 1 {
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *application) requireStaff(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+		if !isStaffUser(user) {
 			app.renderClientError(w, r, http.StatusUnauthorized)
 			return
 		}
@@ -102,6 +109,17 @@ func (app *application) requireAdmin(next http.Handler) http.Handler {
 	})
 }
 
+// TODO: right now, user 1 is the admin x)
+func isAdminUser(user *models.User) bool {
+	return user != nil && user.ID == 1
+}
+
+// isStaffUser reports whether the user may edit the activities of any member
+// and enter them for all members in the daily grid.
+func isStaffUser(user *models.User) bool {
+	return isAdminUser(user) || (user != nil && user.Role == models.RoleStaff)
+}
+
 func (app *application) contextGetUser(r *http.Request) *models.User {
 	user, ok := r.Context().Value(userContextKey).(*models.User)
 	if !ok {
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/davidkuda/bellevue/internal/email"
+	"github.com/davidkuda/bellevue/internal/models"
+)
+
+// enqueueTx writes msg to the outbox in tx. invoiceID is 0 if the email is
+// not about an invoice. Call app.wakeOutbox after the commit.
+func (app *application) enqueueTx(kind string, msg email.Message, invoiceID int, tx *sql.Tx) error {
+	_, err := app.models.Outbox.EnqueueTx(models.OutboxMessage{
+		Kind:      kind,
+		Recipient: msg.To,
+		Subject:   msg.Subject,
+		Body:      msg.Body,
+		InvoiceID: sql.NullInt32{Int32: int32(invoiceID), Valid: invoiceID != 0},
+	}, tx)
+	return err
+}
+
+// wakeOutbox makes the worker deliver right away instead of at its next tick.
+func (app *application) wakeOutbox() {
+	select {
+	case app.outboxWake <- struct{}{}:
+	default:
+	}
+}
+
+// outboxWorker delivers the due messages of the outbox every interval, or
+// when it is woken up. Failed deliveries are retried with a backoff, see
+// models.OutboxBackoff. Delivery is at least once: if the database fails
+// right after the mailer succeeded, the message is sent again.
+func (app *application) outboxWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-app.outboxWake:
+		}
+
+		messages, err := app.models.Outbox.GetDue(50)
+		if err != nil {
+			log.Printf("could not get due outbox messages: %v", err)
+			continue
+		}
+
+		for _, msg := range messages {
+			if err := app.deliver(msg); err != nil {
+				log.Printf("could not deliver outbox message %d to %s (attempt %d): %v", msg.ID, msg.Recipient, msg.Attempts+1, err)
+				mark := app.models.Outbox.MarkAttemptFailed
+				// e.g. the invoice was cancelled, retrying does not help.
+				if errors.Is(err, models.ErrInvalidTransition) {
+					mark = app.models.Outbox.MarkFailed
+				}
+				if err := mark(msg.ID, err); err != nil {
+					log.Printf("could not record failed attempt of outbox message %d: %v", msg.ID, err)
+				}
+			}
+		}
+	}
+}
+
+// deliver sends msg and marks it as sent. An invoice email is rendered only
+// now: the invoice leaves draft and gets its number in the transaction of the
+// delivery, so that the email and the PDF show it. If the mailer fails, the
+// rollback leaves the invoice a draft without a number.
+func (app *application) deliver(msg models.OutboxMessage) error {
+	tx, err := app.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var userID int
+	if msg.Kind == models.OutboxKindInvoice && msg.InvoiceID.Valid {
+		rendered, invoiceUserID, err := app.issueInvoiceTx(int(msg.InvoiceID.Int32), tx)
+		if err != nil {
+			return err
+		}
+		msg.Subject, msg.Body = rendered.Subject, rendered.Body
+		userID = invoiceUserID
+
+		if err := app.models.Outbox.SetContentTx(msg.ID, msg.Subject, msg.Body, tx); err != nil {
+			return err
+		}
+	}
+
+	err = app.mailer.Send(email.Message{
+		To:      msg.Recipient,
+		Subject: msg.Subject,
+		Body:    msg.Body,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := app.models.Outbox.MarkSentTx(msg.ID, tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed committing transaction: %v", err)
+	}
+
+	// the credit that was deducted in the email pays the invoice now.
+	if userID != 0 {
+		if _, err := app.models.Payments.Allocate(userID); err != nil {
+			log.Printf("could not allocate payments of userID=%v: %v", userID, err)
+		}
+	}
+
+	return nil
+}
+
+// issueInvoiceTx marks the invoice as sent, which assigns its number, and
+// renders its email. It returns the email and the user of the invoice.
+func (app *application) issueInvoiceTx(invoiceID int, tx *sql.Tx) (email.Message, int, error) {
+	err := app.models.InvoicesV2.SetStatusTx(invoiceID, models.InvoiceStatusSent, 0, tx)
+	if err != nil {
+		return email.Message{}, 0, fmt.Errorf("could not mark invoice %d as sent: %w", invoiceID, err)
+	}
+
+	invoice, err := app.models.InvoicesV2.GetTx(invoiceID, tx)
+	if err != nil {
+		return email.Message{}, 0, fmt.Errorf("could not get invoice %d: %v", invoiceID, err)
+	}
+
+	user, err := app.models.Users.GetUserByID(invoice.UserID)
+	if err != nil {
+		return email.Message{}, 0, fmt.Errorf("could not get user %d: %v", invoice.UserID, err)
+	}
+
+	viewInvoice, err := app.viewmodels.Activities.GetInvoiceForUserTx(invoiceID, user.ID, tx)
+	if err != nil {
+		return email.Message{}, 0, fmt.Errorf("could not get invoice invoiceID=%v userID=%v: %v", invoiceID, user.ID, err)
+	}
+
+	credit, err := app.models.Payments.CreditForUser(user.ID)
+	if err != nil {
+		return email.Message{}, 0, fmt.Errorf("could not get credit of userID=%v: %v", user.ID, err)
+	}
+
+	msg, err := email.RenderInvoice(app.EmailConfig, &user, &invoice, viewInvoice, credit)
+	if err != nil {
+		return email.Message{}, 0, fmt.Errorf("could not render invoice invoiceID=%v: %v", invoiceID, err)
+	}
+
+	return msg, user.ID, nil
+}
@@ -16,6 +16,7 @@ func (app *application) routes() http.Handler {
 	standard := alice.New(commonHeaders, app.authenticate)
 	usersOnly := alice.New(app.requireAuthentication)
 	adminsOnly := alice.New(app.requireAuthentication, app.requireAdmin)
+	staffOnly := alice.New(app.requireAuthentication, app.requireStaff)
 
 	mux.HandleFunc("GET /{$}", app.getHome)
 
@@ -23,11 +24,26 @@ func (app *application) routes() http.Handler {
 	mux.Handle("GET /activities", usersOnly.ThenFunc(app.getActivities))
 	mux.Handle("GET /activities/new", usersOnly.ThenFunc(app.getActivitiesNew))
 	mux.Handle("POST /activities", usersOnly.ThenFunc(app.bellevueActivityPost))
+	mux.Handle("GET /activities/daily", staffOnly.ThenFunc(app.getActivitiesDaily))
+	mux.Handle("POST /activities/daily", staffOnly.ThenFunc(app.postActivitiesDaily))
 	mux.Handle("GET /activities/{id}/edit", usersOnly.ThenFunc(app.getActivitiesIDEdit))
 	mux.Handle("PUT /activities/{id}", usersOnly.ThenFunc(app.putActivitiesID))
 	mux.Handle("DELETE /activities/{id}", usersOnly.ThenFunc(app.bellevueActivityDelete))
+	mux.Handle("POST /activities/templates", usersOnly.ThenFunc(app.postActivitiesTemplates))
+	mux.Handle("DELETE /activities/templates/{id}", usersOnly.ThenFunc(app.deleteActivitiesTemplatesID))
+	mux.Handle("POST /activities/templates/{id}/dismiss", usersOnly.ThenFunc(app.postActivitiesTemplatesIDDismiss))
 	mux.Handle("POST /invoices", usersOnly.ThenFunc(app.invoicePost))
 
+	mux.Handle("GET /meals", usersOnly.ThenFunc(app.getMeals))
+	mux.Handle("POST /meals", usersOnly.ThenFunc(app.postMeals))
+	mux.Handle("POST /meals/{id}/cancel", usersOnly.ThenFunc(app.postMealsIDCancel))
+	mux.Handle("GET /kitchen", staffOnly.ThenFunc(app.getKitchen))
+	mux.Handle("GET /kitchen/report", staffOnly.ThenFunc(app.getKitchenReport))
+	mux.Handle("POST /kitchen/convert", staffOnly.ThenFunc(app.postKitchenConvert))
+
+	mux.Handle("GET /account", usersOnly.ThenFunc(app.getAccount))
+	mux.Handle("POST /account", usersOnly.ThenFunc(app.postAccount))
+
 	mux.HandleFunc("GET /login", app.getLogin)
 	mux.HandleFunc("GET /login/email", app.getLoginEmail)
 	mux.HandleFunc("GET /signup", app.getLoginSignup)
@@ -41,6 +57,35 @@ func (app *application) routes() http.Handler {
 
 	mux.Handle("GET /settings", adminsOnly.ThenFunc(app.getSettings))
 	mux.Handle("GET /settings/products", adminsOnly.ThenFunc(app.getSettingsProducts))
+	mux.Handle("POST /settings/products", adminsOnly.ThenFunc(app.postSettingsProducts))
+	mux.Handle("GET /settings/products/new", adminsOnly.ThenFunc(app.getSettingsProductsNew))
+	// product codes may contain slashes, e.g. course/weekend.
+	mux.Handle("GET /settings/products/edit/{code...}", adminsOnly.ThenFunc(app.getSettingsProductsEdit))
+	mux.Handle("POST /settings/products/edit/{code...}", adminsOnly.ThenFunc(app.postSettingsProductsEdit))
+	mux.Handle("POST /settings/products/delete/{code...}", adminsOnly.ThenFunc(app.postSettingsProductsDelete))
+	mux.Handle("POST /settings/products/restore/{code...}", adminsOnly.ThenFunc(app.postSettingsProductsRestore))
+	mux.Handle("GET /settings/members", adminsOnly.ThenFunc(app.getSettingsMembers))
+	mux.Handle("POST /settings/members/{id}/price-categories", adminsOnly.ThenFunc(app.postSettingsMembersIDPriceCategories))
+	mux.Handle("POST /settings/members/{id}/role", adminsOnly.ThenFunc(app.postSettingsMembersIDRole))
+	mux.Handle("GET /settings/households", adminsOnly.ThenFunc(app.getSettingsHouseholds))
+	mux.Handle("POST /settings/households", adminsOnly.ThenFunc(app.postSettingsHouseholds))
+	mux.Handle("POST /settings/households/{id}/members", adminsOnly.ThenFunc(app.postSettingsHouseholdsIDMembers))
+	mux.Handle("POST /settings/households/{id}/members/remove", adminsOnly.ThenFunc(app.postSettingsHouseholdsIDMembersRemove))
+	mux.Handle("POST /settings/households/{id}/billing-contact", adminsOnly.ThenFunc(app.postSettingsHouseholdsIDBillingContact))
+	mux.Handle("POST /settings/households/{id}/delete", adminsOnly.ThenFunc(app.postSettingsHouseholdsIDDelete))
+	mux.Handle("GET /settings/invoices", adminsOnly.ThenFunc(app.getSettingsInvoices))
+	mux.Handle("POST /settings/invoices/{id}/status", adminsOnly.ThenFunc(app.postSettingsInvoicesIDStatus))
+	mux.Handle("POST /settings/invoices/{id}/cancel", adminsOnly.ThenFunc(app.postSettingsInvoicesIDCancel))
+	mux.Handle("GET /settings/reminders", adminsOnly.ThenFunc(app.getSettingsReminders))
+	mux.Handle("POST /settings/reminders", adminsOnly.ThenFunc(app.postSettingsReminders))
+	mux.Handle("GET /settings/outbox", adminsOnly.ThenFunc(app.getSettingsOutbox))
+	mux.Handle("POST /settings/outbox/{id}/resend", adminsOnly.ThenFunc(app.postSettingsOutboxIDResend))
+	mux.Handle("GET /settings/mwst", adminsOnly.ThenFunc(app.getSettingsMWST))
+	mux.Handle("GET /settings/mwst.csv", adminsOnly.ThenFunc(app.getSettingsMWSTCSV))
+	mux.Handle("GET /settings/bank-transactions", adminsOnly.ThenFunc(app.getSettingsBankTransactions))
+	mux.Handle("POST /settings/bank-transactions", adminsOnly.ThenFunc(app.postSettingsBankTransactions))
+	mux.Handle("POST /settings/bank-transactions/{id}/match", adminsOnly.ThenFunc(app.postSettingsBankTransactionsIDMatch))
+	mux.Handle("POST /settings/bank-transactions/{id}/ignore", adminsOnly.ThenFunc(app.postSettingsBankTransactionsIDIgnore))
 
 	return standard.Then(mux)
 }
@@ -17,6 +17,7 @@ type templateData struct {
 	LoggedIn          bool
 	User              models.User
 	IsAdmin           bool
+	IsStaff           bool
 	Title             string
 	Path              string
 	RootPath          string
@@ -35,7 +36,39 @@ type templateData struct {
 	ViewModels struct {
 		Activity             *viewmodels.Activity
 		UninvoicedActivities *viewmodels.Invoice
+		EnteredForOthers     *viewmodels.Invoice
 		SentInvoices         []*viewmodels.Invoice
+		Balance              models.Balance
+		AdminInvoices        []viewmodels.AdminInvoice
+
+		UnmatchedBankTransactions []models.BankTransaction
+		MatchedBankTransactions   []models.BankTransaction
+		OpenInvoices              []models.OpenInvoice
+
+		MWSTReport *viewmodels.MWSTReport
+		TaxPeriods []string
+
+		OverdueInvoices []overdueInvoice
+		DunningLevels   []models.DunningLevel
+
+		FailedMessages  []models.OutboxMessage
+		PendingMessages []models.OutboxMessage
+
+		CatalogProducts []models.CatalogProduct
+		CatalogOptions  models.CatalogOptions
+
+		Members         []member
+		PriceCategories models.PriceCategories
+		Users           []models.User // to pick in the activity form
+
+		Households       []models.Household
+		WithoutHousehold []models.User
+
+		MealRegistrations models.MealRegistrations
+		MealHeadcounts    []models.MealHeadcount
+
+		ActivityTemplates []models.ActivityTemplate
+		ActivityDrafts    []models.ActivityDraft
 	}
 
 	// Feature Flags
@@ -74,12 +107,10 @@ func (app *application) newTemplateData(r *http.Request) templateData {
 		user = models.User{}
 	}
 
-	var isAdmin bool
+	var isAdmin, isStaff bool
 	if isAuthenticated {
-		isAdmin, ok = r.Context().Value("isAdmin").(bool)
-		if !ok {
-			isAdmin = false
-		}
+		isAdmin = isAdminUser(userPointer)
+		isStaff = isStaffUser(userPointer)
 	}
 
 	var rootPath string
@@ -101,13 +132,14 @@ func (app *application) newTemplateData(r *http.Request) templateData {
 		LoggedIn:          isAuthenticated,
 		User:              user,
 		IsAdmin:           isAdmin,
+		IsStaff:           isStaff,
 		Title:             "Amden Bellevue Team Activities",
 		RootPath:          rootPath,
 		Path:              r.URL.Path,
 		Sidebars:          true,
 		RenderTotalsTable: renderTotalsTable,
 		Today:             time.Now(),
-		ProductFormConfig: app.productFormConfig,
+		ProductFormConfig: app.catalog.Snapshot().ProductFormConfig,
 	}
 }
 
@@ -0,0 +1,211 @@
+// Package camt parses ISO 20022 bank to customer statements (camt.053) and
+// debit/credit notifications (camt.054) as delivered by Swiss banks.
+//
+// Only the parts that we need to reconcile incoming payments are parsed.
+// Both the old (.001.04) and the current (.001.08) versions are supported, the
+// namespace is ignored.
+package camt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUnknownDocument = errors.New("camt: neither camt.053 nor camt.054")
+
+// Statement is one account statement (camt.053) or notification (camt.054).
+type Statement struct {
+	IBAN    string
+	Entries []Entry
+}
+
+// Entry is one booked transaction. Batch bookings are split into one Entry
+// per transaction.
+type Entry struct {
+	// Reference is unique per transaction, it is used to detect entries that
+	// were already imported.
+	Reference   string
+	Credit      bool
+	Amount      int // in Rappen
+	Currency    string
+	BookingDate time.Time
+	DebtorName  string
+	// CreditorReference is the structured reference, e.g. a QR or RF reference.
+	CreditorReference string
+	Message           string
+}
+
+type document struct {
+	Statements    []account `xml:"BkToCstmrStmt>Stmt"`
+	Notifications []account `xml:"BkToCstmrDbtCdtNtfctn>Ntfctn"`
+}
+
+type account struct {
+	IBAN    string  `xml:"Acct>Id>IBAN"`
+	Entries []entry `xml:"Ntry"`
+}
+
+type entry struct {
+	NtryRef     string   `xml:"NtryRef"`
+	AcctSvcrRef string   `xml:"AcctSvcrRef"`
+	Amt         amount   `xml:"Amt"`
+	CdtDbtInd   string   `xml:"CdtDbtInd"`
+	BookgDt     date     `xml:"BookgDt"`
+	TxDtls      []txDtls `xml:"NtryDtls>TxDtls"`
+}
+
+type txDtls struct {
+	AcctSvcrRef string  `xml:"Refs>AcctSvcrRef"`
+	EndToEndID  string  `xml:"Refs>EndToEndId"`
+	Amt         *amount `xml:"Amt"`
+	CdtDbtInd   string  `xml:"CdtDbtInd"`
+	// .001.04: RltdPties>Dbtr>Nm, .001.08: RltdPties>Dbtr>Pty>Nm
+	DbtrNm    string   `xml:"RltdPties>Dbtr>Nm"`
+	DbtrPtyNm string   `xml:"RltdPties>Dbtr>Pty>Nm"`
+	Ustrd     []string `xml:"RmtInf>Ustrd"`
+	Ref       string   `xml:"RmtInf>Strd>CdtrRefInf>Ref"`
+}
+
+type amount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type date struct {
+	Dt   string `xml:"Dt"`
+	DtTm string `xml:"DtTm"`
+}
+
+// Parse reads a camt.053 or camt.054 document.
+func Parse(r io.Reader) ([]Statement, error) {
+	var doc document
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("camt: could not decode xml: %w", err)
+	}
+
+	accounts := append(doc.Statements, doc.Notifications...)
+	if len(accounts) == 0 {
+		return nil, ErrUnknownDocument
+	}
+
+	var statements []Statement
+	for _, acc := range accounts {
+		s := Statement{IBAN: strings.ReplaceAll(acc.IBAN, " ", "")}
+		for i, e := range acc.Entries {
+			entries, err := e.split(s.IBAN, i)
+			if err != nil {
+				return nil, err
+			}
+			s.Entries = append(s.Entries, entries...)
+		}
+		statements = append(statements, s)
+	}
+
+	return statements, nil
+}
+
+// split turns one <Ntry> into one Entry per <TxDtls>.
+func (e entry) split(iban string, index int) ([]Entry, error) {
+	bookingDate, err := e.BookgDt.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(e.TxDtls) == 0 {
+		e.TxDtls = []txDtls{{}}
+	}
+
+	var entries []Entry
+	for i, tx := range e.TxDtls {
+		amt := e.Amt
+		if tx.Amt != nil {
+			amt = *tx.Amt
+		}
+		rappen, err := parseAmount(amt.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		indicator := e.CdtDbtInd
+		if tx.CdtDbtInd != "" {
+			indicator = tx.CdtDbtInd
+		}
+
+		entry := Entry{
+			Credit:            indicator == "CRDT",
+			Amount:            rappen,
+			Currency:          amt.Currency,
+			BookingDate:       bookingDate,
+			DebtorName:        strings.TrimSpace(tx.DbtrNm + tx.DbtrPtyNm),
+			CreditorReference: strings.ReplaceAll(tx.Ref, " ", ""),
+			Message:           strings.TrimSpace(strings.Join(tx.Ustrd, " ")),
+		}
+
+		switch {
+		case tx.AcctSvcrRef != "":
+			entry.Reference = tx.AcctSvcrRef
+		case len(e.TxDtls) == 1 && e.AcctSvcrRef != "":
+			entry.Reference = e.AcctSvcrRef
+		default:
+			entry.Reference = fallbackReference(iban, index, i, e, entry)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// fallbackReference builds a stable reference for banks that don't deliver
+// an AcctSvcrRef, so that importing the same file twice is still detected.
+func fallbackReference(iban string, entryIndex, txIndex int, e entry, en Entry) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%s|%s|%d|%d|%d|%s|%s|%s",
+		iban, e.NtryRef, en.BookingDate.Format(time.DateOnly), entryIndex, txIndex,
+		en.Amount, en.DebtorName, en.CreditorReference, en.Message)
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+func (d date) parse() (time.Time, error) {
+	switch {
+	case d.Dt != "":
+		return time.Parse(time.DateOnly, d.Dt)
+	case d.DtTm != "":
+		// e.g. 2026-01-05T10:15:00 or with offset
+		if t, err := time.Parse(time.RFC3339, d.DtTm); err == nil {
+			return t, nil
+		}
+		return time.Parse("2006-01-02T15:04:05", d.DtTm)
+	default:
+		return time.Time{}, errors.New("camt: entry without booking date")
+	}
+}
+
+// parseAmount converts "48.5" or "48.50" into Rappen without going through
+// floats.
+func parseAmount(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	francs, cents, _ := strings.Cut(s, ".")
+	if len(cents) > 2 {
+		return 0, fmt.Errorf("camt: invalid amount %q", s)
+	}
+	cents = (cents + "00")[:2]
+
+	f, err := strconv.Atoi(francs)
+	if err != nil {
+		return 0, fmt.Errorf("camt: invalid amount %q", s)
+	}
+	c, err := strconv.Atoi(cents)
+	if err != nil {
+		return 0, fmt.Errorf("camt: invalid amount %q", s)
+	}
+
+	return f*100 + c, nil
+}
@@ -0,0 +1,85 @@
+package camt
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func parseFile(t *testing.T, name string) []Statement {
+	t.Helper()
+
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	statements, err := Parse(f)
+	if err != nil {
+		t.Fatalf("Parse(%s): %v", name, err)
+	}
+	if len(statements) != 1 {
+		t.Fatalf("expected 1 statement, got %d", len(statements))
+	}
+	return statements
+}
+
+func TestParseCamt053(t *testing.T) {
+	s := parseFile(t, "testdata/camt053.xml")[0]
+
+	if s.IBAN != "CH4431999123000889012" {
+		t.Errorf("unexpected IBAN %s", s.IBAN)
+	}
+
+	// the batch booking of 100.00 is split into two entries
+	if len(s.Entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(s.Entries))
+	}
+
+	e := s.Entries[0]
+	if !e.Credit || e.Amount != 4850 || e.Reference != "ZKB-0001" || e.DebtorName != "Anna Mueller" {
+		t.Errorf("unexpected first entry: %+v", e)
+	}
+
+	e = s.Entries[1]
+	if e.Amount != 6000 || e.CreditorReference != "RF18539007547034" || e.Reference != "ZKB-0002-1" {
+		t.Errorf("unexpected second entry: %+v", e)
+	}
+
+	if s.Entries[2].Amount != 4000 || s.Entries[2].DebtorName != "Clara Frei" {
+		t.Errorf("unexpected third entry: %+v", s.Entries[2])
+	}
+
+	if s.Entries[3].Credit {
+		t.Errorf("expected a debit entry: %+v", s.Entries[3])
+	}
+}
+
+func TestParseCamt054(t *testing.T) {
+	s := parseFile(t, "testdata/camt054.xml")[0]
+
+	if len(s.Entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(s.Entries))
+	}
+
+	e := s.Entries[0]
+	if e.Amount != 2250 || e.DebtorName != "Daniel Weber" || e.BookingDate.Day() != 2 {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	if !strings.HasPrefix(e.Reference, "sha256:") {
+		t.Errorf("expected a fallback reference, got %s", e.Reference)
+	}
+
+	again := parseFile(t, "testdata/camt054.xml")[0].Entries[0]
+	if again.Reference != e.Reference {
+		t.Error("fallback reference is not stable")
+	}
+}
+
+func TestParseUnknown(t *testing.T) {
+	_, err := Parse(strings.NewReader(`<Document><Foo/></Document>`))
+	if err != ErrUnknownDocument {
+		t.Errorf("expected ErrUnknownDocument, got %v", err)
+	}
+}
@@ -3,22 +3,28 @@ package email
 import (
 	"log"
 	"os"
+
+	"github.com/davidkuda/bellevue/internal/models"
 )
 
 type EmailConfig struct {
 	Domain string
 
-	SMTP SMTPConfig
+	// Transport is one of the Transport constants, see NewMailer. SMTP is only
+	// needed for smtps and starttls, MailDir only for file.
+	Transport string
+	SMTP      SMTPConfig
+	MailDir   string
 
 	SenderName  string
 	SenderEmail string
 
 	Recipient BankAccount
 
-	TestEmail string
-
 	EmailSubject string
 
+	// DunningLevels of the payment reminders, see REMINDER_LEVELS.
+	DunningLevels []models.DunningLevel
 }
 
 type SMTPConfig struct {
@@ -40,6 +46,8 @@ func LoadConfigFromEnv() EmailConfig {
 		Domain: os.Getenv("BELLEVUE__EMAIL__DOMAIN"),
 		SenderName:  os.Getenv("SENDER_NAME"),
 		SenderEmail: os.Getenv("SENDER_EMAIL_ADDRESS"),
+		Transport:   os.Getenv("MAIL_TRANSPORT"),
+		MailDir:     os.Getenv("MAIL_DIR"),
 		SMTP: SMTPConfig{
 			Host: os.Getenv("SMTP_HOST"),
 			Port: os.Getenv("SMTP_PORT"),
@@ -53,7 +61,6 @@ func LoadConfigFromEnv() EmailConfig {
 			PLZOrt: os.Getenv("RECIPIENT_PLZ_ORT"),
 		},
 
-		TestEmail: os.Getenv("TEST_EMAIL"),
 		EmailSubject: os.Getenv("EMAIL_SUBJECT"),
 	}
 
@@ -74,24 +81,40 @@ func LoadConfigFromEnv() EmailConfig {
 		log.Print("Could not read env var SENDER_EMAIL_ADDRESS")
 	}
 
-	if c.SMTP.Host == "" {
-		fail = true
-		log.Print("Could not read env var SMTP_HOST")
-	}
-
-	if c.SMTP.Port == "" {
-		fail = true
-		log.Print("Could not read env var SMTP_PORT")
-	}
-
-	if c.SMTP.User == "" {
-		fail = true
-		log.Print("Could not read env var SMTP_USER")
+	if c.Transport == "" {
+		c.Transport = TransportSMTPS
 	}
 
-	if c.SMTP.Pass == "" {
+	switch c.Transport {
+	case TransportSMTPS, TransportSTARTTLS:
+		if c.SMTP.Host == "" {
+			fail = true
+			log.Print("Could not read env var SMTP_HOST")
+		}
+
+		if c.SMTP.Port == "" {
+			fail = true
+			log.Print("Could not read env var SMTP_PORT")
+		}
+
+		if c.SMTP.User == "" {
+			fail = true
+			log.Print("Could not read env var SMTP_USER")
+		}
+
+		if c.SMTP.Pass == "" {
+			fail = true
+			log.Print("Could not read env var SMTP_PASS")
+		}
+	case TransportFile:
+		if c.MailDir == "" {
+			fail = true
+			log.Print("Could not read env var MAIL_DIR, needed for MAIL_TRANSPORT=file")
+		}
+	case TransportMemory:
+	default:
 		fail = true
-		log.Print("Could not read env var SMTP_PASS")
+		log.Printf("Invalid env var MAIL_TRANSPORT=%s, expected smtps, starttls, file or memory", c.Transport)
 	}
 
 	if c.Recipient.IBAN == "" {
@@ -99,8 +122,14 @@ func LoadConfigFromEnv() EmailConfig {
 		log.Print("Could not read env var RECIPIENT_IBAN")
 	}
 
-	if c.EmailSubject == "" {
-		c.EmailSubject = "Deine Rechnung vom Bellevue"
+	c.DunningLevels = models.DefaultDunningLevels
+	if levels := os.Getenv("REMINDER_LEVELS"); levels != "" {
+		var err error
+		c.DunningLevels, err = models.ParseDunningLevels(levels)
+		if err != nil {
+			fail = true
+			log.Printf("Could not parse env var REMINDER_LEVELS: %v", err)
+		}
 	}
 
 	if fail {
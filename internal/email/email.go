@@ -2,91 +2,188 @@ package email
 
 import (
 	"bytes"
-	"crypto/tls"
+	"embed"
+	"encoding/csv"
 	"fmt"
-	"log"
-	"net"
-	"net/smtp"
+	"net/mail"
+	"strconv"
+	"strings"
 	"text/template"
 	"time"
 
+	"github.com/davidkuda/bellevue/internal/invoicepdf"
 	"github.com/davidkuda/bellevue/internal/models"
+	"github.com/davidkuda/bellevue/internal/qrbill"
 	"github.com/davidkuda/bellevue/internal/viewmodels"
 )
 
-func Send(
+// templates: every email has a .txt.tmpl and a .html.tmpl, which define
+// "email-txt" and "email-html". The MIME message around them is built by
+// mimeMessage.
+//
+//go:embed *.tmpl
+var templates embed.FS
+
+// Message is a rendered email, ready to be delivered.
+type Message struct {
+	To      string
+	Subject string
+	Body    []byte // including the headers
+
+	// Attachments are contained in Body, too. They are kept to preview them.
+	Attachments []Attachment
+}
+
+// RenderInvoice renders the invoice email with the PDF attached. credit is
+// deducted from the total.
+func RenderInvoice(
 	cfg EmailConfig,
 	user *models.User,
 	invoice *models.InvoiceV2,
 	viewInvoice *viewmodels.Invoice,
-) error {
-	files := []string{
-		"internal/email/email.tmpl",
-		"internal/email/email.txt.tmpl",
-		"internal/email/email.html.tmpl",
-	}
+	credit int,
+) (Message, error) {
+	data := newTemplateData(
+		cfg, user, invoice, viewInvoice,
+	)
+	data.Credit = credit
 
-	funcs := template.FuncMap{
-		"fmtCHF":  formatCurrency,
-		"fmtDate": formatDate,
+	attachment, err := newInvoiceAttachment(cfg, data)
+	if err != nil {
+		return Message{}, err
 	}
+	data.Attachments = append(data.Attachments, attachment)
 
-	tmpl := template.New("email").Funcs(funcs)
-	t, err := tmpl.ParseFiles(files...)
+	attachment, err = newLineItemsAttachment(data)
 	if err != nil {
-		log.Fatal(err)
+		return Message{}, err
 	}
+	data.Attachments = append(data.Attachments, attachment)
 
+	return render(data,
+		"email.txt.tmpl",
+		"email.html.tmpl",
+	)
+}
+
+// RenderCancellation tells the member that invoice was cancelled and why.
+// creditNote is nil if the activities were released to be invoiced again.
+func RenderCancellation(
+	cfg EmailConfig,
+	user *models.User,
+	invoice *models.InvoiceV2,
+	viewInvoice *viewmodels.Invoice,
+	creditNote *models.CreditNote,
+) (Message, error) {
 	data := newTemplateData(
 		cfg, user, invoice, viewInvoice,
 	)
+	data.Subject = fmt.Sprintf("Korrektur Deiner Rechnung %s", invoice.Number)
+	data.CreditNote = creditNote
 
-	var buf bytes.Buffer
-	if err := t.ExecuteTemplate(&buf, "email", data); err != nil {
-		err = fmt.Errorf("could not execute template: %v", err)
-		return err
+	return render(data,
+		"cancellation.txt.tmpl",
+		"cancellation.html.tmpl",
+	)
+}
+
+// RenderReminder reminds the member to pay invoice. overdue holds the open
+// amount, level the dunning level of this reminder.
+func RenderReminder(
+	cfg EmailConfig,
+	user *models.User,
+	invoice *models.InvoiceV2,
+	viewInvoice *viewmodels.Invoice,
+	overdue *models.OverdueInvoice,
+	level models.DunningLevel,
+) (Message, error) {
+	data := newTemplateData(
+		cfg, user, invoice, viewInvoice,
+	)
+	data.Subject = reminderSubject(invoice, level)
+	data.Overdue = overdue
+	data.ReminderLevel = level.Level
+
+	return render(data,
+		"reminder.txt.tmpl",
+		"reminder.html.tmpl",
+	)
+}
+
+func reminderSubject(invoice *models.InvoiceV2, level models.DunningLevel) string {
+	if level.Level == 1 {
+		return fmt.Sprintf("Zahlungserinnerung für Deine Rechnung %s", invoice.Number)
 	}
+	return fmt.Sprintf("%d. Zahlungserinnerung für Deine Rechnung %s", level.Level, invoice.Number)
+}
 
-	em := email{
-		from:    cfg.SMTP.User,
-		to:      []string{user.Email},
-		subject: data.Subject,
-		body:    buf.Bytes(),
+// render renders the text and html parts, which define "email-txt" and
+// "email-html", and builds the MIME message of data around them.
+func render(data *TemplateData, parts ...string) (Message, error) {
+	funcs := template.FuncMap{
+		"fmtCHF":  formatCurrency,
+		"fmtDate": formatDate,
+		"fmtRef":  qrbill.FormatReference,
 	}
 
-	if err := sendViaImplicitTLS(cfg, em); err != nil {
-		err = fmt.Errorf("could not send email: %v", err)
-		return err
+	t, err := template.New("email").Funcs(funcs).ParseFS(templates, parts...)
+	if err != nil {
+		return Message{}, fmt.Errorf("could not parse templates: %v", err)
 	}
 
-	return nil
-}
+	var text, html bytes.Buffer
+	if err := t.ExecuteTemplate(&text, "email-txt", data); err != nil {
+		return Message{}, fmt.Errorf("could not execute template: %v", err)
+	}
+	if err := t.ExecuteTemplate(&html, "email-html", data); err != nil {
+		return Message{}, fmt.Errorf("could not execute template: %v", err)
+	}
 
-type email struct {
-	from    string
-	to      []string
-	subject string
-	body    []byte
-}
+	m := mimeMessage{
+		From:        mail.Address{Name: data.SenderName, Address: data.SenderEmail},
+		To:          mail.Address{Name: strings.TrimSpace(data.User.FirstName + " " + data.User.LastName), Address: data.User.Email},
+		Subject:     data.Subject,
+		Date:        time.Now(),
+		MessageID:   newMessageID(data.Domain),
+		Text:        text.String(),
+		HTML:        html.String(),
+		Attachments: data.Attachments,
+	}
+
+	body, err := m.Bytes()
+	if err != nil {
+		return Message{}, fmt.Errorf("could not build MIME message: %v", err)
+	}
 
-func newEmail() {}
+	return Message{
+		To:          data.User.Email,
+		Subject:     data.Subject,
+		Body:        body,
+		Attachments: data.Attachments,
+	}, nil
+}
 
 type TemplateData struct {
 	Subject     string
-	To          string
-	From        string
-	Name        string
-	Date        string
 	SenderName  string
 	SenderEmail string
 	Domain      string
 
 	Recipient     BankAccount
 	Zahlungszweck string
+	// Credit of the member from earlier payments, deducted from the total.
+	Credit int
+
+	Attachments []Attachment
 
 	User        *models.User
 	Invoice     *models.InvoiceV2
 	ViewInvoice *viewmodels.Invoice
+	CreditNote  *models.CreditNote
+
+	// Overdue and ReminderLevel are only set for payment reminders.
+	Overdue       *models.OverdueInvoice
+	ReminderLevel int
 }
 
 func newTemplateData(
@@ -96,10 +193,7 @@ func newTemplateData(
 	viewInvoice *viewmodels.Invoice,
 ) *TemplateData {
 	data := TemplateData{
-		Subject:       cfg.EmailSubject,
-		To:            user.Email,
-		From:          cfg.SMTP.User,
-		Date:          time.Now().Format(time.RFC1123Z),
+		Subject:       subject(cfg.EmailSubject, invoice),
 		SenderName:    cfg.SenderName,
 		SenderEmail:   cfg.SenderEmail,
 		Domain:        cfg.Domain,
@@ -112,69 +206,133 @@ func newTemplateData(
 	return &data
 }
 
-func zahlungszweck(invoice *viewmodels.Invoice, user *models.User) string {
-	var positions string
-
-	for _, cat := range invoice.Categories {
-		positions += fmt.Sprintf("%s %s, ", cat.Name, formatCurrency(cat.TotalPrice))
-	}
+// Reference is the reference of the invoice as the QR-bill has it, see
+// qrbill.ReferenceFor.
+func (d *TemplateData) Reference() string {
+	return qrbill.ReferenceFor(d.Recipient.IBAN, d.Invoice.Reference)
+}
 
-	// remove trailing comma and space char
-	positions = positions[:len(positions)-2]
+// Due is what is left to pay after deducting the credit.
+func (d *TemplateData) Due() int {
+	return max(d.ViewInvoice.TotalPrice-d.Credit, 0)
+}
 
-	positions = fmt.Sprintf("%s: %s", user.FirstName, positions)
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Content     []byte
+}
 
-	// TODO: it would be nice to add something like 2026-03 or 2026-Q1 to the string
+// newInvoiceAttachment renders the invoice PDF with the QR-bill payment part.
+func newInvoiceAttachment(cfg EmailConfig, data *TemplateData) (Attachment, error) {
+	pdf, err := invoicepdf.Render(invoicepdf.Data{
+		IBAN: cfg.Recipient.IBAN,
+		Creditor: qrbill.ParseAddress(
+			cfg.Recipient.Name,
+			cfg.Recipient.Street,
+			cfg.Recipient.PLZOrt,
+		),
+		Message:     data.Zahlungszweck,
+		Credit:      data.Credit,
+		Date:        time.Now(),
+		User:        data.User,
+		Invoice:     data.Invoice,
+		ViewInvoice: data.ViewInvoice,
+	})
+	if err != nil {
+		return Attachment{}, fmt.Errorf("could not render invoice pdf: %v", err)
+	}
 
-	return positions
+	return Attachment{
+		Filename:    invoicepdf.Filename(data.Invoice),
+		ContentType: "application/pdf",
+		Content:     pdf,
+	}, nil
 }
 
-func sendViaImplicitTLS(cfg EmailConfig, em email) error {
-	tlsCfg := &tls.Config{
-		ServerName: cfg.SMTP.Host,
-		MinVersion: tls.VersionTLS12,
+// newLineItemsAttachment lists the consumptions of the invoice as CSV, one
+// line per consumption, with the member who consumed it. It is separated by
+// semicolons, which spreadsheets in Switzerland expect.
+func newLineItemsAttachment(data *TemplateData) (Attachment, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = ';'
+	w.UseCRLF = true
+
+	w.Write([]string{"Datum", "Person", "Produkt", "Preiskategorie", "Menge", "Einzelpreis", "Total", "MWST", "Kommentar"})
+	for _, a := range data.ViewInvoice.Activities {
+		for _, c := range a.Consumptions {
+			w.Write([]string{
+				a.Date.Format("2006-01-02"),
+				a.Member,
+				c.ProductName,
+				c.PriceCategory,
+				strconv.Itoa(c.Quantity),
+				formatCurrency(c.UnitPrice),
+				formatCurrency(c.TotalPrice),
+				fmt.Sprintf("%.1f%%", float64(c.TaxRate)/100),
+				a.Comment,
+			})
+		}
 	}
 
-	conn, err := tls.Dial("tcp", net.JoinHostPort(cfg.SMTP.Host, cfg.SMTP.Port), tlsCfg)
-	if err != nil {
-		return fmt.Errorf("tls dial: %w", err)
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return Attachment{}, fmt.Errorf("could not write line items: %v", err)
 	}
-	defer conn.Close()
 
-	c, err := smtp.NewClient(conn, cfg.SMTP.Host)
-	if err != nil {
-		return fmt.Errorf("smtp newclient: %w", err)
-	}
-	defer c.Quit()
+	return Attachment{
+		Filename:    strings.TrimSuffix(invoicepdf.Filename(data.Invoice), ".pdf") + ".csv",
+		ContentType: "text/csv",
+		Content:     buf.Bytes(),
+	}, nil
+}
 
-	auth := smtp.PlainAuth("", cfg.SMTP.User, cfg.SMTP.Pass, cfg.SMTP.Host)
-	if err := c.Auth(auth); err != nil {
-		return fmt.Errorf("auth: %w", err)
-	}
+// DefaultSubject is the subject of invoice emails if EMAIL_SUBJECT is not set.
+const DefaultSubject = "Deine Rechnung vom Bellevue"
 
-	if err := c.Mail(em.from); err != nil {
-		return fmt.Errorf("MAIL FROM: %w", err)
+// subject appends the invoice number, e.g.
+// "Deine Rechnung im Bellevue (BV-2026-0042)".
+func subject(s string, invoice *models.InvoiceV2) string {
+	if s == "" {
+		s = DefaultSubject
 	}
-	for _, rcpt := range em.to {
-		if err := c.Rcpt(rcpt); err != nil {
-			return fmt.Errorf("RCPT TO %s: %w", rcpt, err)
-		}
+	if invoice.Number == "" {
+		return s
 	}
+	return fmt.Sprintf("%s (%s)", s, invoice.Number)
+}
 
-	writer, err := c.Data()
-	if err != nil {
-		return fmt.Errorf("DATA: %w", err)
-	}
+// zahlungszweck is the unstructured message on the QR-bill, e.g.
+// "Anna 2026-03: Essen 45.00, Kiosk 3.50". Banks may cut it, the invoice is
+// identified by its creditor reference.
+func zahlungszweck(invoice *viewmodels.Invoice, user *models.User) string {
+	return fmt.Sprintf(
+		"%s %s: %s",
+		user.FirstName,
+		billingPeriod(invoice),
+		CategorySummary(invoice),
+	)
+}
 
-	if _, err := writer.Write(em.body); err != nil {
-		return fmt.Errorf("write body: %w", err)
+// CategorySummary lists the totals per category, e.g. "Essen 45.00, Kiosk 3.50".
+func CategorySummary(invoice *viewmodels.Invoice) string {
+	var positions []string
+	for _, cat := range invoice.Categories {
+		positions = append(positions, fmt.Sprintf("%s %s", cat.Name, formatCurrency(cat.TotalPrice)))
 	}
+	return strings.Join(positions, ", ")
+}
 
-	if err := writer.Close(); err != nil {
-		return fmt.Errorf("close data: %w", err)
+// billingPeriod returns the months of the invoice, e.g. 2026-03 or
+// 2026-01..2026-03 if it spans more than one month.
+func billingPeriod(invoice *viewmodels.Invoice) string {
+	from := invoice.MinDate.Format("2006-01")
+	until := invoice.MaxDate.Format("2006-01")
+	if from == until {
+		return from
 	}
-
-	return nil
+	return from + ".." + until
 }
 
 // formatCurrency converts an integer (in Rappen) to a currency string like "22.50 CHF".
@@ -185,3 +343,41 @@ func formatCurrency(value int) string {
 func formatDate(t time.Time) string {
 	return t.Format("2.01.2006")
 }
+
+// emails according to RFC5322 require \r\n line endings, not just \n.
+// therefore, we need to normalize the line endings, which means to
+// make sure that they end in \r\n. nice little leetcodish challenge :)
+//
+// DEC HEX
+//
+//	10   A  LF => NL line feed, new line
+//	13   D  CR => Carriage Return
+//	32  20  space
+//	92  5C  \
+//
+// 110  6E  n
+// 114  72  r
+func normalizeCRLF(in []byte) []byte {
+	var newLinesCount int
+	for i := range in {
+		if in[i] == '\n' {
+			newLinesCount++
+		}
+	}
+
+	out := make([]byte, len(in)+newLinesCount, len(in)+newLinesCount)
+
+	var k int // outPointer
+	for i := range in {
+		if in[i] == '\n' {
+			if i == 0 || in[i-1] != '\r' {
+				out[k] = '\r'
+				k++
+			}
+		}
+		out[k] = in[i]
+		k++
+	}
+	// lines that already ended in \r\n did not need the extra byte.
+	return out[:k]
+}
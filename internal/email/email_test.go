@@ -0,0 +1,190 @@
+package email
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkuda/bellevue/internal/models"
+	"github.com/davidkuda/bellevue/internal/viewmodels"
+)
+
+func TestNewMailer(t *testing.T) {
+	tests := []struct {
+		transport string
+		want      Mailer
+	}{
+		{"", &SMTPMailer{}},
+		{TransportSMTPS, &SMTPMailer{}},
+		{TransportSTARTTLS, &SMTPMailer{StartTLS: true}},
+		{TransportFile, &FileMailer{Dir: "mail"}},
+		{TransportMemory, &MemoryMailer{}},
+	}
+
+	for _, tt := range tests {
+		got, err := NewMailer(EmailConfig{Transport: tt.transport, MailDir: "mail"})
+		if err != nil {
+			t.Fatalf("NewMailer(%q): %v", tt.transport, err)
+		}
+		switch want := tt.want.(type) {
+		case *SMTPMailer:
+			m, ok := got.(*SMTPMailer)
+			if !ok || m.StartTLS != want.StartTLS {
+				t.Errorf("NewMailer(%q) = %#v, want %#v", tt.transport, got, want)
+			}
+		case *FileMailer:
+			m, ok := got.(*FileMailer)
+			if !ok || m.Dir != want.Dir {
+				t.Errorf("NewMailer(%q) = %#v, want %#v", tt.transport, got, want)
+			}
+		case *MemoryMailer:
+			if _, ok := got.(*MemoryMailer); !ok {
+				t.Errorf("NewMailer(%q) = %#v, want a MemoryMailer", tt.transport, got)
+			}
+		}
+	}
+
+	if _, err := NewMailer(EmailConfig{Transport: "pigeon"}); err == nil {
+		t.Error("NewMailer(pigeon): expected an error")
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	m := &FileMailer{Dir: dir}
+
+	msg := Message{To: "anna@example.com", Subject: "Test", Body: []byte("Subject: Test\r\n\r\nHallo\r\n")}
+	if err := m.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "new", "*.anna@example.com.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("got %d files, want 1", len(files))
+	}
+
+	got, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, msg.Body) {
+		t.Errorf("got %q, want %q", got, msg.Body)
+	}
+}
+
+func TestRenderReminder(t *testing.T) {
+	cfg := EmailConfig{
+		Domain:      "bellevue.example.com",
+		SenderName:  "Bellevue",
+		SenderEmail: "kasse@example.com",
+		Recipient: BankAccount{
+			IBAN: "CH93 0076 2011 6238 5295 7",
+			Name: "Verein Bellevue",
+		},
+	}
+	user := &models.User{ID: 7, FirstName: "Anna", Email: "anna@example.com"}
+	invoice := &models.InvoiceV2{ID: 42, UserID: 7, Number: "BV-2026-0042"}
+	viewInvoice := &viewmodels.Invoice{ID: 42, Number: "BV-2026-0042"}
+	overdue := &models.OverdueInvoice{
+		InvoiceID:  42,
+		TotalPrice: 4550,
+		Paid:       1000,
+		SentAt:     time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	msg, err := RenderReminder(cfg, user, invoice, viewInvoice, overdue, models.DunningLevel{Level: 2, After: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.To != "anna@example.com" {
+		t.Errorf("To = %q", msg.To)
+	}
+	if want := "2. Zahlungserinnerung für Deine Rechnung BV-2026-0042"; msg.Subject != want {
+		t.Errorf("Subject = %q, want %q", msg.Subject, want)
+	}
+	text := textParts(t, msg.Body)["text/plain"]
+	for _, want := range []string{"Noch offen: 35.50 CHF", "1.03.2026", "trotz unserer Erinnerung"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text does not contain %q", want)
+		}
+	}
+
+	// the memory mailer keeps what was sent.
+	var m MemoryMailer
+	if err := m.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Messages(); len(got) != 1 || got[0].Subject != msg.Subject {
+		t.Errorf("Messages() = %v", got)
+	}
+}
+
+func TestRenderInvoiceHousehold(t *testing.T) {
+	cfg := EmailConfig{
+		Domain:      "bellevue.example.com",
+		SenderName:  "Bellevue",
+		SenderEmail: "kasse@example.com",
+		Recipient: BankAccount{
+			IBAN:   "CH93 0076 2011 6238 5295 7",
+			Name:   "Verein Bellevue",
+			Street: "Bellevue 1",
+			PLZOrt: "8873 Amden",
+		},
+	}
+	user := &models.User{ID: 7, FirstName: "Anna", LastName: "Muster", Email: "anna@example.com"}
+	invoice := &models.InvoiceV2{ID: 42, UserID: 7, Number: "BV-2026-0042", Reference: "RF18539007547034"}
+
+	anna := viewmodels.Activity{
+		UserID: 7, Member: "Anna Muster", TotalPrice: 1500,
+		Date:         time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC),
+		Consumptions: []viewmodels.Consumption{{ProductName: "Mittagessen", PriceCategory: "regular", Quantity: 1, UnitPrice: 1500, TotalPrice: 1500}},
+	}
+	ben := viewmodels.Activity{
+		UserID: 8, Member: "Ben Muster", TotalPrice: 1000,
+		Date:         time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		Consumptions: []viewmodels.Consumption{{ProductName: "Mittagessen", PriceCategory: "reduced", Quantity: 1, UnitPrice: 1000, TotalPrice: 1000}},
+	}
+	viewInvoice := &viewmodels.Invoice{
+		ID:         42,
+		TotalPrice: 2500,
+		Activities: []viewmodels.Activity{anna, ben},
+		Members: []viewmodels.InvoiceMember{
+			{UserID: 7, Name: "Anna Muster", TotalPrice: 1500, Activities: []viewmodels.Activity{anna}},
+			{UserID: 8, Name: "Ben Muster", TotalPrice: 1000, Activities: []viewmodels.Activity{ben}},
+		},
+	}
+
+	msg, err := RenderInvoice(cfg, user, invoice, viewInvoice, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := textParts(t, msg.Body)["text/plain"]
+	want := []string{
+		"nach Person:",
+		"Anna Muster: 15.00 CHF",
+		"2.03.2026: 15.00 CHF:",
+		"Ben Muster: 10.00 CHF",
+		"1.03.2026: 10.00 CHF:",
+	}
+	i := 0
+	for _, w := range want {
+		j := strings.Index(text[i:], w)
+		if j < 0 {
+			t.Fatalf("text does not contain %q after position %d:\n%s", w, i, text)
+		}
+		i += j + len(w)
+	}
+
+	csv := string(msg.Attachments[1].Content)
+	if !strings.Contains(csv, "2026-03-01;Ben Muster;Mittagessen;reduced") {
+		t.Errorf("line items do not list Ben:\n%s", csv)
+	}
+}
@@ -0,0 +1,157 @@
+package email
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Mailer delivers rendered messages.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// transports, see MAIL_TRANSPORT:
+const (
+	TransportSMTPS    = "smtps"    // implicit TLS, usually port 465
+	TransportSTARTTLS = "starttls" // plain connection upgraded with STARTTLS, usually port 587
+	TransportFile     = "file"     // .eml files in MAIL_DIR, for development
+	TransportMemory   = "memory"   // kept in memory, for tests
+)
+
+// NewMailer returns the Mailer of cfg.Transport.
+func NewMailer(cfg EmailConfig) (Mailer, error) {
+	switch cfg.Transport {
+	case TransportSMTPS, "":
+		return &SMTPMailer{SMTP: cfg.SMTP}, nil
+	case TransportSTARTTLS:
+		return &SMTPMailer{SMTP: cfg.SMTP, StartTLS: true}, nil
+	case TransportFile:
+		return &FileMailer{Dir: cfg.MailDir}, nil
+	case TransportMemory:
+		return &MemoryMailer{}, nil
+	}
+	return nil, fmt.Errorf("unknown mail transport %q", cfg.Transport)
+}
+
+// SMTPMailer sends via SMTP with implicit TLS, or with STARTTLS if StartTLS
+// is set. The SMTP user is the envelope sender.
+type SMTPMailer struct {
+	SMTP     SMTPConfig
+	StartTLS bool
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	c, err := m.dial()
+	if err != nil {
+		return err
+	}
+	defer c.Quit()
+
+	auth := smtp.PlainAuth("", m.SMTP.User, m.SMTP.Pass, m.SMTP.Host)
+	if err := c.Auth(auth); err != nil {
+		return fmt.Errorf("auth: %w", err)
+	}
+
+	if err := c.Mail(m.SMTP.User); err != nil {
+		return fmt.Errorf("MAIL FROM: %w", err)
+	}
+	if err := c.Rcpt(msg.To); err != nil {
+		return fmt.Errorf("RCPT TO %s: %w", msg.To, err)
+	}
+
+	writer, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("DATA: %w", err)
+	}
+
+	if _, err := writer.Write(msg.Body); err != nil {
+		return fmt.Errorf("write body: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("close data: %w", err)
+	}
+
+	return nil
+}
+
+func (m *SMTPMailer) dial() (*smtp.Client, error) {
+	addr := net.JoinHostPort(m.SMTP.Host, m.SMTP.Port)
+	tlsCfg := &tls.Config{
+		ServerName: m.SMTP.Host,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if !m.StartTLS {
+		conn, err := tls.Dial("tcp", addr, tlsCfg)
+		if err != nil {
+			return nil, fmt.Errorf("tls dial: %w", err)
+		}
+		c, err := smtp.NewClient(conn, m.SMTP.Host)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("smtp newclient: %w", err)
+		}
+		return c, nil
+	}
+
+	c, err := smtp.Dial(addr)
+	if err != nil {
+		return nil, fmt.Errorf("smtp dial: %w", err)
+	}
+	// never send the password over an unencrypted connection.
+	if ok, _ := c.Extension("STARTTLS"); !ok {
+		c.Close()
+		return nil, fmt.Errorf("%s does not support STARTTLS", addr)
+	}
+	if err := c.StartTLS(tlsCfg); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("starttls: %w", err)
+	}
+	return c, nil
+}
+
+// FileMailer writes every message as .eml file into the new directory of a
+// maildir, so that mail clients can open it.
+type FileMailer struct {
+	Dir string
+}
+
+func (m *FileMailer) Send(msg Message) error {
+	dir := filepath.Join(m.Dir, "new")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("could not create maildir: %v", err)
+	}
+
+	name := fmt.Sprintf("%d.%s.eml", time.Now().UnixNano(), msg.To)
+	if err := os.WriteFile(filepath.Join(dir, name), msg.Body, 0o644); err != nil {
+		return fmt.Errorf("could not write %s: %v", name, err)
+	}
+	return nil
+}
+
+// MemoryMailer keeps the messages, for tests.
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (m *MemoryMailer) Send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns the messages sent so far.
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
@@ -0,0 +1,184 @@
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// mimeMessage is an email with a text and an html version of the same
+// content and optional attachments:
+//
+//	multipart/mixed
+//	├── multipart/alternative
+//	│   ├── text/plain
+//	│   └── text/html
+//	└── attachments
+//
+// Without attachments, multipart/alternative is the top level.
+type mimeMessage struct {
+	From      mail.Address
+	To        mail.Address
+	Subject   string
+	Date      time.Time
+	MessageID string // without the angle brackets
+
+	Text string
+	HTML string
+
+	Attachments []Attachment
+}
+
+// Bytes returns the message as sent over SMTP: headers with non-ASCII text
+// encoded according to RFC 2047, quoted-printable text parts, base64
+// attachments and CRLF line endings throughout.
+func (m *mimeMessage) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+
+	header := func(key, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+	header("From", m.From.String())
+	header("To", m.To.String())
+	header("Subject", encodeHeader(m.Subject))
+	header("Date", m.Date.Format(time.RFC1123Z))
+	header("Message-ID", "<"+m.MessageID+">")
+	header("MIME-Version", "1.0")
+
+	if len(m.Attachments) == 0 {
+		header("Content-Type", multipartType("alternative", m.boundary("alt")))
+		buf.WriteString("\r\n")
+		if err := m.writeAlternative(&buf); err != nil {
+			return nil, err
+		}
+		buf.WriteString("\r\n")
+		return normalizeCRLF(buf.Bytes()), nil
+	}
+
+	header("Content-Type", multipartType("mixed", m.boundary("mixed")))
+	buf.WriteString("\r\n")
+
+	mixed := multipart.NewWriter(&buf)
+	if err := mixed.SetBoundary(m.boundary("mixed")); err != nil {
+		return nil, err
+	}
+
+	alt, err := mixed.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {multipartType("alternative", m.boundary("alt"))},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := m.writeAlternative(alt); err != nil {
+		return nil, err
+	}
+
+	for _, a := range m.Attachments {
+		part, err := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(a.ContentType, map[string]string{"name": a.Filename})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(part, base64Lines(a.Content)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\r\n")
+
+	return normalizeCRLF(buf.Bytes()), nil
+}
+
+// writeAlternative writes the text and the html part.
+func (m *mimeMessage) writeAlternative(w io.Writer) error {
+	alt := multipart.NewWriter(w)
+	if err := alt.SetBoundary(m.boundary("alt")); err != nil {
+		return err
+	}
+
+	parts := []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	}
+
+	for _, p := range parts {
+		part, err := alt.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return err
+		}
+		qp := quotedprintable.NewWriter(part)
+		if _, err := io.WriteString(qp, p.content); err != nil {
+			return err
+		}
+		if err := qp.Close(); err != nil {
+			return err
+		}
+	}
+
+	return alt.Close()
+}
+
+// boundary derives the boundary of a multipart from the Message-ID, so that
+// the same message always has the same bytes. "=_" can neither occur in
+// quoted-printable nor in base64.
+func (m *mimeMessage) boundary(kind string) string {
+	sum := sha256.Sum256([]byte(m.MessageID))
+	return fmt.Sprintf("=_%s_%s", kind, hex.EncodeToString(sum[:12]))
+}
+
+func multipartType(subtype, boundary string) string {
+	return mime.FormatMediaType("multipart/"+subtype, map[string]string{"boundary": boundary})
+}
+
+// encodeHeader encodes s according to RFC 2047 if it is not plain ASCII.
+// Long values are folded between the encoded words.
+func encodeHeader(s string) string {
+	return strings.ReplaceAll(mime.QEncoding.Encode("utf-8", s), "?= =?", "?=\r\n =?")
+}
+
+// newMessageID returns a unique Message-ID in domain, e.g.
+// "1767225600000000000.3f9a1c2b7d4e8f60@bellevue.example.com".
+func newMessageID(domain string) string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return fmt.Sprintf("%d.%s@%s", time.Now().UnixNano(), hex.EncodeToString(b), domain)
+}
+
+// base64Lines returns content base64 encoded, in lines of 76 characters as
+// required by RFC 2045.
+func base64Lines(content []byte) string {
+	encoded := base64.StdEncoding.EncodeToString(content)
+
+	var b strings.Builder
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76])
+		b.WriteString("\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded)
+
+	return b.String()
+}
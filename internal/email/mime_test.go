@@ -0,0 +1,248 @@
+package email
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkuda/bellevue/internal/models"
+	"github.com/davidkuda/bellevue/internal/viewmodels"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// golden compares got with testdata/name, or writes it with -update.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not read golden file, run with -update: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs, got:\n%s", path, got)
+	}
+}
+
+func testMIMEMessage() mimeMessage {
+	return mimeMessage{
+		From:      mail.Address{Name: "Bellevue Küche", Address: "kasse@example.com"},
+		To:        mail.Address{Name: "Anna Müller", Address: "anna@example.com"},
+		Subject:   "Deine Rechnung für den letzten Monat im Bellevue (BV-2026-0042)",
+		Date:      time.Date(2026, 4, 1, 8, 30, 0, 0, time.FixedZone("CEST", 2*60*60)),
+		MessageID: "1775025000000000000.0123456789abcdef@bellevue.example.com",
+		Text:      "Liebe/r Anna\n\nBitte überweise 45.00 CHF.\n\nLieben Gruss\nDavid",
+		HTML:      "<p>\n  Liebe/r Anna\n</p>\n<p>\n  Bitte überweise <strong>45.00 CHF</strong>.\n</p>",
+	}
+}
+
+func TestMIMEMessageAlternative(t *testing.T) {
+	m := testMIMEMessage()
+
+	got, err := m.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCRLF(t, got)
+	golden(t, "alternative.eml", got)
+}
+
+func TestMIMEMessageAttachments(t *testing.T) {
+	m := testMIMEMessage()
+	m.Attachments = []Attachment{
+		{
+			Filename:    "Rechnung-BV-2026-0042.pdf",
+			ContentType: "application/pdf",
+			Content:     bytes.Repeat([]byte("%PDF-1.4 not really a pdf\n"), 8),
+		},
+		{
+			Filename:    "Rechnung-BV-2026-0042.csv",
+			ContentType: "text/csv",
+			Content:     []byte("Datum;Produkt\r\n2026-03-02;Kaffee\r\n"),
+		},
+	}
+
+	got, err := m.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCRLF(t, got)
+	golden(t, "attachments.eml", got)
+}
+
+// checkCRLF fails if msg has a bare LF or a line that is too long: 998
+// characters in the headers, 78 in the body.
+func checkCRLF(t *testing.T, msg []byte) {
+	t.Helper()
+
+	if !bytes.HasSuffix(msg, []byte("\r\n")) {
+		t.Error("message does not end in CRLF")
+	}
+
+	limit := 998
+	for i, line := range strings.Split(string(msg), "\r\n") {
+		if line == "" {
+			limit = 78
+		}
+		if strings.Contains(line, "\n") {
+			t.Errorf("line %d has a bare LF: %q", i+1, line)
+		}
+		if len(line) > limit {
+			t.Errorf("line %d is longer than %d characters: %q", i+1, limit, line)
+		}
+	}
+}
+
+// textParts parses msg like a mail client and returns the decoded content
+// of its text parts by content type.
+func textParts(t *testing.T, msg []byte) map[string]string {
+	t.Helper()
+
+	m, err := mail.ReadMessage(bytes.NewReader(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := make(map[string]string)
+	var walk func(contentType string, r io.Reader)
+	walk = func(contentType string, r io.Reader) {
+		mediaType, params, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(mediaType, "multipart/") {
+			b, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parts[mediaType] = string(b)
+			return
+		}
+		mr := multipart.NewReader(r, params["boundary"])
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.HasPrefix(p.Header.Get("Content-Disposition"), "attachment") {
+				continue
+			}
+			// the part reader decodes quoted-printable.
+			walk(p.Header.Get("Content-Type"), p)
+		}
+	}
+	walk(m.Header.Get("Content-Type"), m.Body)
+
+	return parts
+}
+
+func TestMIMEMessageParts(t *testing.T) {
+	m := testMIMEMessage()
+	m.Text = strings.Repeat("Bitte überweise den offenen Betrag bis Ende Monat. ", 4)
+
+	got, err := m.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := textParts(t, got)
+	if parts["text/plain"] != m.Text {
+		t.Errorf("text/plain = %q, want %q", parts["text/plain"], m.Text)
+	}
+	// the quoted-printable writer ends lines in CRLF.
+	if want := strings.ReplaceAll(m.HTML, "\n", "\r\n"); parts["text/html"] != want {
+		t.Errorf("text/html = %q, want %q", parts["text/html"], want)
+	}
+
+	header, err := mail.ReadMessage(bytes.NewReader(got))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(header.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != m.Subject {
+		t.Errorf("Subject = %q, want %q", subject, m.Subject)
+	}
+}
+
+func TestEncodeHeader(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Deine Rechnung vom Bellevue", "Deine Rechnung vom Bellevue"},
+		{"Zahlungserinnerung für Deine Rechnung", "=?utf-8?q?Zahlungserinnerung_f=C3=BCr_Deine_Rechnung?="},
+	}
+
+	for _, tt := range tests {
+		if got := encodeHeader(tt.in); got != tt.want {
+			t.Errorf("encodeHeader(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeCRLF(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"a\nb\n", "a\r\nb\r\n"},
+		{"a\r\nb\r\n", "a\r\nb\r\n"},
+		{"\na\r\n\n", "\r\na\r\n\r\n"},
+	}
+
+	for _, tt := range tests {
+		if got := string(normalizeCRLF([]byte(tt.in))); got != tt.want {
+			t.Errorf("normalizeCRLF(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLineItemsAttachment(t *testing.T) {
+	data := &TemplateData{
+		Invoice: &models.InvoiceV2{ID: 42, Number: "BV-2026-0042"},
+		ViewInvoice: &viewmodels.Invoice{
+			Activities: []viewmodels.Activity{
+				{
+					Date:    time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC),
+					Member:  "Anna Muster",
+					Comment: "mit Gästen; zwei Kaffee",
+					Consumptions: []viewmodels.Consumption{
+						{ProductName: "Mittagessen", PriceCategory: "regular", Quantity: 1, UnitPrice: 1500, TotalPrice: 1500, TaxRate: 810},
+						{ProductName: "Kaffee", PriceCategory: "regular", Quantity: 2, UnitPrice: 250, TotalPrice: 500, TaxRate: 260},
+					},
+				},
+			},
+		},
+	}
+
+	a, err := newLineItemsAttachment(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Filename != "Rechnung-BV-2026-0042.csv" {
+		t.Errorf("Filename = %q", a.Filename)
+	}
+	golden(t, "lineitems.csv", a.Content)
+}
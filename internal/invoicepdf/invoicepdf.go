@@ -0,0 +1,218 @@
+// Package invoicepdf renders an invoice as A4 PDF with a Swiss QR-bill
+// payment part at the bottom of the first page.
+package invoicepdf
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/davidkuda/bellevue/internal/models"
+	"github.com/davidkuda/bellevue/internal/qrbill"
+	"github.com/davidkuda/bellevue/internal/viewmodels"
+
+	"github.com/go-pdf/fpdf"
+)
+
+type Data struct {
+	IBAN        string
+	Creditor    qrbill.Address
+	Message     string
+	Credit      int // deducted from the total
+	Date        time.Time
+	User        *models.User
+	Invoice     *models.InvoiceV2
+	ViewInvoice *viewmodels.Invoice
+}
+
+const marginX = 20.0
+
+// Filename is the name of the attachment, e.g. Rechnung-BV-2026-0042.pdf
+func Filename(invoice *models.InvoiceV2) string {
+	if invoice.Number == "" {
+		return fmt.Sprintf("Rechnung-Bellevue-%d.pdf", invoice.ID)
+	}
+	return fmt.Sprintf("Rechnung-%s.pdf", invoice.Number)
+}
+
+func Render(d Data) ([]byte, error) {
+	bill := qrbill.Bill{
+		IBAN:      d.IBAN,
+		Creditor:  d.Creditor,
+		Amount:    d.due(),
+		Currency:  "CHF",
+		Reference: d.reference(),
+		Message:   d.Message,
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(marginX, 20, marginX)
+	pdf.SetAutoPageBreak(true, 20)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pdf.AddPage()
+	renderSummary(pdf, tr, d)
+	// an amount of 0 would let the member fill in any amount.
+	if d.due() > 0 {
+		if err := bill.Draw(pdf); err != nil {
+			return nil, fmt.Errorf("could not draw qr-bill: %w", err)
+		}
+	}
+
+	pdf.AddPage()
+	renderActivities(pdf, tr, d.ViewInvoice)
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, fmt.Errorf("pdf.Output: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// reference is the reference of the invoice as the QR-bill to d.IBAN has it.
+func (d Data) reference() string {
+	return qrbill.ReferenceFor(d.IBAN, d.Invoice.Reference)
+}
+
+func (d Data) due() int {
+	return max(d.ViewInvoice.TotalPrice-d.Credit, 0)
+}
+
+// renderSummary writes the addresses, the title and the totals per category.
+// It must stay above the payment part, which starts at 192mm.
+func renderSummary(pdf *fpdf.Fpdf, tr func(string) string, d Data) {
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.SetXY(marginX, 20)
+	for _, line := range []string{
+		d.Creditor.Name,
+		d.Creditor.Street + " " + d.Creditor.BuildingNumber,
+		d.Creditor.PostalCode + " " + d.Creditor.Town,
+	} {
+		pdf.CellFormat(0, 5, tr(line), "", 1, "L", false, 0, "")
+	}
+
+	pdf.SetXY(120, 50)
+	pdf.CellFormat(0, 5, tr(d.User.FirstName+" "+d.User.LastName), "", 2, "L", false, 0, "")
+	pdf.CellFormat(0, 5, tr(d.User.Email), "", 2, "L", false, 0, "")
+
+	pdf.SetXY(marginX, 80)
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 8, tr("Rechnung Nr. "+number(d.Invoice)), "", 1, "L", false, 0, "")
+
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(0, 5, tr("Datum: "+d.Date.Format("2.01.2006")), "", 1, "L", false, 0, "")
+	if ref := d.reference(); ref != "" {
+		pdf.CellFormat(0, 5, tr("Referenz: "+qrbill.FormatReference(ref)), "", 1, "L", false, 0, "")
+	}
+	pdf.CellFormat(0, 5, tr(fmt.Sprintf(
+		"Zeitraum: %s bis %s",
+		d.ViewInvoice.MinDate.Format("2.01.2006"),
+		d.ViewInvoice.MaxDate.Format("2.01.2006"),
+	)), "", 1, "L", false, 0, "")
+	pdf.Ln(8)
+
+	// page width minus margins minus the amount column
+	w := 210 - 2*marginX - 40
+	for _, cat := range d.ViewInvoice.Categories {
+		pdf.CellFormat(w, 6, tr(cat.Name), "B", 0, "L", false, 0, "")
+		pdf.CellFormat(40, 6, formatCHF(cat.TotalPrice), "B", 1, "R", false, 0, "")
+	}
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(w, 7, "Total", "", 0, "L", false, 0, "")
+	pdf.CellFormat(40, 7, formatCHF(d.ViewInvoice.TotalPrice), "", 1, "R", false, 0, "")
+	if d.Credit > 0 {
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.CellFormat(w, 6, tr("Abzüglich Guthaben"), "", 0, "L", false, 0, "")
+		pdf.CellFormat(40, 6, "-"+formatCHF(min(d.Credit, d.ViewInvoice.TotalPrice)), "", 1, "R", false, 0, "")
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(w, 7, tr("Zu bezahlen"), "T", 0, "L", false, 0, "")
+		pdf.CellFormat(40, 7, formatCHF(d.due()), "T", 1, "R", false, 0, "")
+	}
+
+	// prices include MWST, it is listed per rate below the total.
+	if len(d.ViewInvoice.Taxes) > 0 {
+		pdf.SetFont("Helvetica", "", 8)
+		cw := w / 4
+		pdf.CellFormat(cw, 4, "MWST-Satz", "B", 0, "L", false, 0, "")
+		pdf.CellFormat(cw, 4, "Netto", "B", 0, "R", false, 0, "")
+		pdf.CellFormat(cw, 4, "MWST", "B", 0, "R", false, 0, "")
+		pdf.CellFormat(cw, 4, "Brutto", "B", 1, "R", false, 0, "")
+		for _, t := range d.ViewInvoice.Taxes {
+			pdf.CellFormat(cw, 4, t.Percent()+"%", "", 0, "L", false, 0, "")
+			pdf.CellFormat(cw, 4, formatCHF(t.Net), "", 0, "R", false, 0, "")
+			pdf.CellFormat(cw, 4, formatCHF(t.VAT), "", 0, "R", false, 0, "")
+			pdf.CellFormat(cw, 4, formatCHF(t.Gross), "", 1, "R", false, 0, "")
+		}
+	}
+
+	pdf.Ln(6)
+	pdf.SetFont("Helvetica", "", 10)
+	if d.due() == 0 {
+		pdf.MultiCell(0, 5, tr("Dein Guthaben deckt diese Rechnung, Du musst nichts bezahlen. "+
+			"Die Auflistung Deiner Konsumationen findest Du auf der nächsten Seite."), "", "L", false)
+		return
+	}
+	pdf.MultiCell(0, 5, tr("Bitte bezahle den Betrag mit dem untenstehenden QR-Einzahlungsschein. "+
+		"Die Auflistung Deiner Konsumationen findest Du auf der nächsten Seite."), "", "L", false)
+}
+
+func renderActivities(pdf *fpdf.Fpdf, tr func(string) string, invoice *viewmodels.Invoice) {
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.CellFormat(0, 8, tr("Konsumationen"), "", 1, "L", false, 0, "")
+	pdf.Ln(2)
+
+	if !invoice.HasMembers() {
+		renderActivityList(pdf, tr, invoice.Activities)
+		return
+	}
+
+	// household invoices list the activities by member.
+	for _, m := range invoice.Members {
+		pdf.SetFont("Helvetica", "B", 12)
+		pdf.CellFormat(130, 7, tr(m.Name), "", 0, "L", false, 0, "")
+		pdf.CellFormat(40, 7, formatCHF(m.TotalPrice), "", 1, "R", false, 0, "")
+		pdf.Ln(1)
+		renderActivityList(pdf, tr, m.Activities)
+		pdf.Ln(2)
+	}
+}
+
+func renderActivityList(pdf *fpdf.Fpdf, tr func(string) string, activities []viewmodels.Activity) {
+	for _, activity := range activities {
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(130, 6, activity.Date.Format("Mon 2.01.2006"), "B", 0, "L", false, 0, "")
+		pdf.CellFormat(40, 6, formatCHF(activity.TotalPrice), "B", 1, "R", false, 0, "")
+
+		pdf.SetFont("Helvetica", "", 9)
+		for _, c := range activity.Consumptions {
+			var line string
+			if c.PriceCategory != "free_amount" {
+				line = fmt.Sprintf("%d x %s zu %s (%s)", c.Quantity, c.ProductName, formatCHF(c.UnitPrice), c.PriceCategory)
+			} else {
+				line = c.ProductName
+			}
+			pdf.CellFormat(130, 5, tr(line), "", 0, "L", false, 0, "")
+			pdf.CellFormat(40, 5, formatCHF(c.TotalPrice), "", 1, "R", false, 0, "")
+		}
+		if activity.Comment != "" {
+			pdf.SetFont("Helvetica", "I", 9)
+			pdf.MultiCell(130, 5, tr(activity.Comment), "", "L", false)
+		}
+		pdf.Ln(2)
+	}
+}
+
+// number returns the invoice number, or the id for invoices that were issued
+// before invoices were numbered.
+func number(invoice *models.InvoiceV2) string {
+	if invoice.Number == "" {
+		return fmt.Sprintf("%d", invoice.ID)
+	}
+	return invoice.Number
+}
+
+// formatCHF converts an integer (in Rappen) to a string like "22.50".
+func formatCHF(value int) string {
+	return fmt.Sprintf("%.2f", float64(value)/100)
+}
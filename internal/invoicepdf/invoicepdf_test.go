@@ -0,0 +1,39 @@
+package invoicepdf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/davidkuda/bellevue/internal/models"
+	"github.com/davidkuda/bellevue/internal/qrbill"
+	"github.com/davidkuda/bellevue/internal/viewmodels"
+)
+
+func TestRenderQRIBAN(t *testing.T) {
+	for _, iban := range []string{
+		"CH44 3199 9123 0008 8901 2", // QR-IBAN
+		"CH93 0076 2011 6238 5295 7",
+	} {
+		d := Data{
+			IBAN:     iban,
+			Creditor: qrbill.ParseAddress("Verein Bellevue", "Bellevue 1", "8873 Amden"),
+			Message:  "Anna Muster: Essen 15.00",
+			Date:     time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC),
+			User:     &models.User{ID: 7, FirstName: "Anna", LastName: "Muster"},
+			Invoice:  &models.InvoiceV2{ID: 42, Number: "BV-2026-0042", Reference: "RF340000000042"},
+			ViewInvoice: &viewmodels.Invoice{
+				ID:         42,
+				TotalPrice: 1500,
+				Activities: []viewmodels.Activity{{
+					UserID:       7,
+					Date:         time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC),
+					TotalPrice:   1500,
+					Consumptions: []viewmodels.Consumption{{ProductName: "Mittagessen", Quantity: 1, UnitPrice: 1500, TotalPrice: 1500}},
+				}},
+			},
+		}
+		if _, err := Render(d); err != nil {
+			t.Errorf("Render() with IBAN %s: %v", iban, err)
+		}
+	}
+}
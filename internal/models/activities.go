@@ -2,6 +2,7 @@ package models
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 )
@@ -12,7 +13,9 @@ type ActivityModel struct {
 
 type Activity struct {
 	ID        int
-	UserID    int
+	UserID    int // who is billed
+	EnteredBy int // who entered it, the user or someone on their behalf
+	GuestName sql.NullString
 	InvoiceID sql.NullInt32
 	Date      time.Time
 	Comment   sql.NullString
@@ -25,14 +28,16 @@ func (m *ActivityModel) InsertWithTransaction(activity *Activity, tx *sql.Tx) (i
 
 	stmt := `
 	INSERT INTO activities (
-		user_id, date, comment
+		user_id, entered_by, guest_name, date, comment
 	) VALUES (
-		$1,      $2,   $3
+		$1,      $2,         $3,         $4,   $5
 	)
 	RETURNING id;`
 	row := tx.QueryRow(
 		stmt,
 		activity.UserID,
+		activity.EnteredBy,
+		activity.GuestName,
 		activity.Date,
 		activity.Comment,
 	)
@@ -45,6 +50,8 @@ func (m *ActivityModel) InsertWithTransaction(activity *Activity, tx *sql.Tx) (i
 	return activityID, nil
 }
 
+// UpdateDateAndCommentTx updates the date, the comment and the guest name of
+// the activity. Who is billed and who entered it stay the same.
 func (m *ActivityModel) UpdateDateAndCommentTx(activity *Activity, tx *sql.Tx) error {
 	var err error
 
@@ -52,6 +59,7 @@ func (m *ActivityModel) UpdateDateAndCommentTx(activity *Activity, tx *sql.Tx) e
 	UPDATE activities
 	   SET date = $2,
 	       comment = $3,
+	       guest_name = $4,
 	       updated_at = NOW()
 	 WHERE id = $1;`
 
@@ -60,6 +68,7 @@ func (m *ActivityModel) UpdateDateAndCommentTx(activity *Activity, tx *sql.Tx) e
 		activity.ID,
 		activity.Date,
 		activity.Comment,
+		activity.GuestName,
 	)
 	if err != nil {
 		return fmt.Errorf("failed inserting activity: %v", err)
@@ -68,16 +77,47 @@ func (m *ActivityModel) UpdateDateAndCommentTx(activity *Activity, tx *sql.Tx) e
 	return nil
 }
 
+// Get returns the activity, else ErrNoRecord.
+func (m *ActivityModel) Get(activityID int) (Activity, error) {
+	stmt := `
+	SELECT id, user_id, entered_by, guest_name, invoice_id, date, comment, created_at, updated_at
+	FROM activities
+	WHERE id = $1;`
+
+	var a Activity
+	err := m.DB.QueryRow(stmt, activityID).Scan(
+		&a.ID,
+		&a.UserID,
+		&a.EnteredBy,
+		&a.GuestName,
+		&a.InvoiceID,
+		&a.Date,
+		&a.Comment,
+		&a.CreatedAt,
+		&a.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return a, ErrNoRecord
+		}
+		return a, fmt.Errorf("DB.QueryRow(): %v", err)
+	}
+
+	return a, nil
+}
+
 func (m *ActivityModel) Delete(activityID int, tx *sql.Tx) error {
 	var err error
 
+	// like the consumptions, never an invoiced activity.
 	stmt := `
 	DELETE FROM activities
-	WHERE id = $1;`
+	WHERE id = $1
+	  AND invoice_id IS NULL;`
 
 	_, err = tx.Exec(stmt, activityID)
 	if err != nil {
-		return fmt.Errorf("failed inserting activity: %v", err)
+		return fmt.Errorf("failed deleting activity: %v", err)
 	}
 
 	return nil
@@ -0,0 +1,328 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/davidkuda/bellevue/internal/viewmodels"
+)
+
+// Recurrences of activity templates. A template without one is only used to
+// prefill the activity form.
+const (
+	RecurDaily    = "daily"
+	RecurWeekdays = "weekdays"
+)
+
+// ErrDraftAlreadyConfirmed is returned when a draft is confirmed that was
+// confirmed or dismissed before, e.g. from a second tab, or that is not of
+// a template of the user.
+var ErrDraftAlreadyConfirmed = errors.New("models: draft of activity template already confirmed or dismissed")
+
+// draftDays is how many days, today included, the drafts of recurring
+// templates are proposed. Older ones are dropped.
+const draftDays = 7
+
+// ActivityTemplate is what a member logs nearly every day, saved to prefill
+// the activity form.
+type ActivityTemplate struct {
+	ID         int
+	UserID     int
+	Name       string
+	Recurrence string // "", RecurDaily or RecurWeekdays
+	Items      []ActivityTemplateItem
+	CreatedAt  time.Time
+}
+
+type ActivityTemplateItem struct {
+	ProductCode   string `json:"product_code"`
+	PriceCategory string `json:"price_category"` // "" for custom amounts
+	Quantity      int    `json:"quantity"`
+	Amount        int    `json:"amount"` // for custom amounts
+}
+
+// Activity returns the template as an activity, to prefill the activity form
+// with ProductFormConfig.WithValues.
+func (t ActivityTemplate) Activity() *viewmodels.Activity {
+	activity := viewmodels.Activity{UserID: t.UserID}
+	for _, item := range t.Items {
+		activity.Consumptions = append(activity.Consumptions, viewmodels.Consumption{
+			ProductCode:   item.ProductCode,
+			PriceCategory: item.PriceCategory,
+			Quantity:      item.Quantity,
+			UnitPrice:     item.Amount,
+		})
+	}
+	return &activity
+}
+
+// RecursOn reports whether the template proposes a draft on date.
+func (t ActivityTemplate) RecursOn(date time.Time) bool {
+	switch t.Recurrence {
+	case RecurDaily:
+		return true
+	case RecurWeekdays:
+		return date.Weekday() != time.Saturday && date.Weekday() != time.Sunday
+	}
+	return false
+}
+
+// ActivityDraft is an activity that a recurring template proposes on Date,
+// for the member to confirm or dismiss.
+type ActivityDraft struct {
+	Template ActivityTemplate
+	Date     time.Time
+}
+
+// drafts returns the drafts of the templates from the day of from to today,
+// by date, without the days in runs of the template, by "2006-01-02". A
+// template proposes none before the day it was created.
+func drafts(templates []ActivityTemplate, runs map[int]map[string]bool, from, today time.Time) []ActivityDraft {
+	var drafts []ActivityDraft
+	for date := from; !date.After(today); date = date.AddDate(0, 0, 1) {
+		day := date.Format("2006-01-02")
+		for _, t := range templates {
+			if !t.RecursOn(date) || runs[t.ID][day] || t.CreatedAt.Format("2006-01-02") > day {
+				continue
+			}
+			drafts = append(drafts, ActivityDraft{Template: t, Date: date})
+		}
+	}
+	return drafts
+}
+
+type ActivityTemplateModel struct {
+	DB *sql.DB
+}
+
+// GetAllForUser returns the templates of the user, by name.
+func (m *ActivityTemplateModel) GetAllForUser(userID int) ([]ActivityTemplate, error) {
+	return m.getMultiple(userID, 0)
+}
+
+// Get returns the template of the user, else ErrNoRecord.
+func (m *ActivityTemplateModel) Get(userID, templateID int) (ActivityTemplate, error) {
+	templates, err := m.getMultiple(userID, templateID)
+	if err != nil {
+		return ActivityTemplate{}, err
+	}
+	if len(templates) == 0 {
+		return ActivityTemplate{}, ErrNoRecord
+	}
+	return templates[0], nil
+}
+
+// getMultiple returns the templates of the user, only templateID unless it
+// is 0.
+func (m *ActivityTemplateModel) getMultiple(userID, templateID int) ([]ActivityTemplate, error) {
+	stmt := `
+	   select t.id,
+	          t.user_id,
+	          t.name,
+	          coalesce(t.recurrence, ''),
+	          t.created_at,
+	          coalesce(
+	            json_agg(
+	              json_build_object(
+	                'product_code', i.product_code,
+	                'price_category', coalesce(pc.name, ''),
+	                'quantity', i.quantity,
+	                'amount', i.amount
+	              )
+	            ) filter (where i.template_id is not null),
+	            '[]'::json
+	          )
+	     from activity_templates t
+	left join activity_template_items i
+	       on i.template_id = t.id
+	left join price_categories pc
+	       on pc.id = i.price_category_id
+	    where t.user_id = $1
+	      and ($2 = 0 or t.id = $2)
+	 group by t.id
+	 order by t.name, t.id
+	`
+
+	rows, err := m.DB.Query(stmt, userID, templateID)
+	if err != nil {
+		return nil, fmt.Errorf("DB.Query(stmt): %v", err)
+	}
+	defer rows.Close()
+
+	var templates []ActivityTemplate
+	for rows.Next() {
+		var t ActivityTemplate
+		var itemsJSON []byte
+		err = rows.Scan(
+			&t.ID,
+			&t.UserID,
+			&t.Name,
+			&t.Recurrence,
+			&t.CreatedAt,
+			&itemsJSON,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("for rows.Next(): %v", err)
+		}
+		if err := json.Unmarshal(itemsJSON, &t.Items); err != nil {
+			return nil, fmt.Errorf("unmarshal items of activity template %d: %w", t.ID, err)
+		}
+		templates = append(templates, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %v", err)
+	}
+
+	return templates, nil
+}
+
+// Insert saves the template with its items.
+func (m *ActivityTemplateModel) Insert(t ActivityTemplate) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var templateID int
+	stmt := `
+	insert into activity_templates (user_id, name, recurrence)
+	values ($1, $2, nullif($3, ''))
+	returning id
+	`
+	err = tx.QueryRow(stmt, t.UserID, t.Name, t.Recurrence).Scan(&templateID)
+	if err != nil {
+		return 0, fmt.Errorf("failed inserting activity template: %v", err)
+	}
+
+	for _, item := range t.Items {
+		stmt := `
+		insert into activity_template_items (template_id, product_code, price_category_id, quantity, amount)
+		values ($1, $2, (select id from price_categories where name = $3), $4, $5)
+		`
+		_, err = tx.Exec(stmt, templateID, item.ProductCode, item.PriceCategory, item.Quantity, item.Amount)
+		if err != nil {
+			return 0, fmt.Errorf("failed inserting item %s of activity template: %v", item.ProductCode, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed committing transaction: %v", err)
+	}
+
+	return templateID, nil
+}
+
+// Delete deletes the template of the user, else ErrNoRecord. The activities
+// confirmed from it stay.
+func (m *ActivityTemplateModel) Delete(userID, templateID int) error {
+	result, err := m.DB.Exec(`delete from activity_templates where id = $1 and user_id = $2`, templateID, userID)
+	if err != nil {
+		return fmt.Errorf("failed deleting activity template %d: %v", templateID, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
+
+// GetDrafts returns the drafts of the recurring templates of the user of the
+// last days up to today, by date.
+func (m *ActivityTemplateModel) GetDrafts(userID int, today time.Time) ([]ActivityDraft, error) {
+	templates, err := m.GetAllForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	from := today.AddDate(0, 0, -(draftDays - 1))
+
+	stmt := `
+	select r.template_id,
+	       r.date
+	  from activity_template_runs r
+	  join activity_templates t
+	    on t.id = r.template_id
+	 where t.user_id = $1
+	   and r.date >= $2
+	`
+	rows, err := m.DB.Query(stmt, userID, from)
+	if err != nil {
+		return nil, fmt.Errorf("DB.Query(stmt): %v", err)
+	}
+	defer rows.Close()
+
+	runs := map[int]map[string]bool{}
+	for rows.Next() {
+		var templateID int
+		var date time.Time
+		if err := rows.Scan(&templateID, &date); err != nil {
+			return nil, fmt.Errorf("for rows.Next(): %v", err)
+		}
+		if runs[templateID] == nil {
+			runs[templateID] = map[string]bool{}
+		}
+		runs[templateID][date.Format("2006-01-02")] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %v", err)
+	}
+
+	return drafts(templates, runs, from, today), nil
+}
+
+// ConfirmDraftTx records that the draft of the template of the user on date
+// became the activity. A draft is confirmed once, else
+// ErrDraftAlreadyConfirmed and the caller rolls the activity back.
+func (m *ActivityTemplateModel) ConfirmDraftTx(userID, templateID int, date time.Time, activityID int, tx *sql.Tx) error {
+	return confirmRun(tx, userID, templateID, date, activityID)
+}
+
+func confirmRun(db execer, userID, templateID int, date time.Time, activityID int) error {
+	n, err := recordRun(db, userID, templateID, date, sql.NullInt64{Int64: int64(activityID), Valid: true})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrDraftAlreadyConfirmed
+	}
+	return nil
+}
+
+// DismissDraft records that the member does not want the draft of the
+// template on date. Dismissing it again does nothing.
+func (m *ActivityTemplateModel) DismissDraft(userID, templateID int, date time.Time) error {
+	_, err := recordRun(m.DB, userID, templateID, date, sql.NullInt64{})
+	return err
+}
+
+// execer is either a *sql.DB or a *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// recordRun returns how many runs it recorded, 0 if one was recorded before.
+func recordRun(db execer, userID, templateID int, date time.Time, activityID sql.NullInt64) (int64, error) {
+	stmt := `
+	insert into activity_template_runs (template_id, date, activity_id)
+	select id, $3, $4
+	  from activity_templates
+	 where id = $1
+	   and user_id = $2
+	on conflict (template_id, date) do nothing
+	`
+	// a run recorded before stays, e.g. of a draft confirmed twice.
+	result, err := db.Exec(stmt, templateID, userID, date, activityID)
+	if err != nil {
+		return 0, fmt.Errorf("failed recording run of activity template %d: %v", templateID, err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestActivityTemplateDrafts(t *testing.T) {
+	day := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	templates := []ActivityTemplate{
+		{ID: 1, Name: "lunch", Recurrence: RecurWeekdays, CreatedAt: day("2026-01-01")},
+		{ID: 2, Name: "coffee", Recurrence: RecurDaily, CreatedAt: day("2026-03-07")},
+		{ID: 3, Name: "dinner", CreatedAt: day("2026-01-01")},
+	}
+	// lunch was confirmed or dismissed on Friday.
+	runs := map[int]map[string]bool{1: {"2026-03-06": true}}
+
+	// Thursday to Sunday
+	got := drafts(templates, runs, day("2026-03-05"), day("2026-03-08"))
+
+	want := []struct {
+		templateID int
+		date       string
+	}{
+		{1, "2026-03-05"},
+		{2, "2026-03-07"},
+		{2, "2026-03-08"},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("drafts() = %d drafts, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		if got[i].Template.ID != w.templateID || got[i].Date.Format("2006-01-02") != w.date {
+			t.Errorf("drafts()[%d] = %d on %s, want %d on %s", i, got[i].Template.ID, got[i].Date.Format("2006-01-02"), w.templateID, w.date)
+		}
+	}
+}
+
+func TestActivityTemplateActivity(t *testing.T) {
+	tmpl := ActivityTemplate{
+		UserID: 7,
+		Items: []ActivityTemplateItem{
+			{ProductCode: "coffee", PriceCategory: "regular", Quantity: 2},
+			{ProductCode: "snacks", Quantity: 1, Amount: 350},
+		},
+	}
+
+	config := ProductFormConfig{
+		Specs: []ProductFormSpec{
+			{Code: "coffee", HasCategories: true, PriceCategories: []PriceCategoryOption{
+				{Name: "reduced", Checked: true},
+				{Name: "regular"},
+			}},
+			{Code: "snacks", IsCustomAmount: true},
+		},
+	}.WithValues(tmpl.Activity())
+
+	if c := config.Specs[0]; c.Count != 2 || c.PriceCategories[0].Checked || !c.PriceCategories[1].Checked {
+		t.Errorf("coffee = %+v, want 2 regular", c)
+	}
+	if s := config.Specs[1]; s.Amount != 350 {
+		t.Errorf("snacks amount = %d, want 350", s.Amount)
+	}
+}
+
+// runsExecer records the runs like the unique key of activity_template_runs.
+type runsExecer map[string]bool
+
+func (e runsExecer) Exec(query string, args ...any) (sql.Result, error) {
+	key := fmt.Sprint(args[0], args[2])
+	if e[key] {
+		return driver.RowsAffected(0), nil
+	}
+	e[key] = true
+	return driver.RowsAffected(1), nil
+}
+
+func TestConfirmRunTwice(t *testing.T) {
+	runs := runsExecer{}
+	date := time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC)
+
+	if err := confirmRun(runs, 7, 1, date, 42); err != nil {
+		t.Fatalf("first confirmation: %v", err)
+	}
+	if err := confirmRun(runs, 7, 1, date, 43); !errors.Is(err, ErrDraftAlreadyConfirmed) {
+		t.Errorf("second confirmation: expected ErrDraftAlreadyConfirmed, got %v", err)
+	}
+	if err := confirmRun(runs, 7, 1, date.AddDate(0, 0, 1), 44); err != nil {
+		t.Errorf("draft of the next day: %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type BankTransactionModel struct {
+	DB *sql.DB
+}
+
+const (
+	BankTransactionUnmatched = "unmatched"
+	BankTransactionMatched   = "matched"
+	BankTransactionIgnored   = "ignored"
+)
+
+type BankTransaction struct {
+	ID          int
+	IBAN        string
+	Reference   string
+	BookingDate time.Time
+	Amount      int
+	Currency    string
+	DebtorName  string
+	CreditorRef string
+	Message     string
+	Status      string
+	InvoiceID   sql.NullInt32
+	MatchedBy   sql.NullInt32
+	CreatedAt   time.Time
+}
+
+// InsertTx inserts the transaction unless one with the same reference was
+// imported before. ok is false for such duplicates.
+func (m *BankTransactionModel) InsertTx(t BankTransaction, tx *sql.Tx) (id int, ok bool, err error) {
+	stmt := `
+	insert into bank_transactions (
+		iban, reference, booking_date, amount, currency, debtor_name, creditor_ref, message
+	) values (
+		$1,   $2,        $3,           $4,     $5,       $6,          $7,           $8
+	)
+	on conflict (reference) do nothing
+	returning id;
+	`
+
+	err = tx.QueryRow(
+		stmt,
+		t.IBAN,
+		t.Reference,
+		t.BookingDate,
+		t.Amount,
+		t.Currency,
+		t.DebtorName,
+		t.CreditorRef,
+		t.Message,
+	).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("failed inserting bank transaction: %v", err)
+	}
+
+	return id, true, nil
+}
+
+// MatchTx links the transaction to the invoice. matchedBy is the ID of the
+// admin who matched it, 0 means it was matched automatically.
+func (m *BankTransactionModel) MatchTx(id, invoiceID, matchedBy int, tx *sql.Tx) error {
+	stmt := `
+	update bank_transactions
+	   set status = 'matched',
+	       invoice_id = $2,
+	       matched_by = $3,
+	       updated_at = now()
+	 where id = $1
+	   and status <> 'matched';
+	`
+
+	by := sql.NullInt32{Int32: int32(matchedBy), Valid: matchedBy != 0}
+	result, err := tx.Exec(stmt, id, invoiceID, by)
+	if err != nil {
+		return fmt.Errorf("failed matching bank transaction: %v", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+func (m *BankTransactionModel) SetStatus(id int, status string) error {
+	stmt := `
+	update bank_transactions
+	   set status = $2,
+	       updated_at = now()
+	 where id = $1
+	   and status <> 'matched';
+	`
+
+	result, err := m.DB.Exec(stmt, id, status)
+	if err != nil {
+		return fmt.Errorf("failed updating bank transaction: %v", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+func (m *BankTransactionModel) Get(id int) (BankTransaction, error) {
+	stmt := `
+	select id, iban, reference, booking_date, amount, currency,
+	       coalesce(debtor_name, ''), coalesce(creditor_ref, ''), coalesce(message, ''),
+	       status, invoice_id, matched_by, created_at
+	  from bank_transactions
+	 where id = $1;
+	`
+
+	var t BankTransaction
+	err := m.DB.QueryRow(stmt, id).Scan(
+		&t.ID,
+		&t.IBAN,
+		&t.Reference,
+		&t.BookingDate,
+		&t.Amount,
+		&t.Currency,
+		&t.DebtorName,
+		&t.CreditorRef,
+		&t.Message,
+		&t.Status,
+		&t.InvoiceID,
+		&t.MatchedBy,
+		&t.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return BankTransaction{}, ErrNoRecord
+		}
+		return BankTransaction{}, fmt.Errorf("DB.QueryRow(): %v", err)
+	}
+
+	return t, nil
+}
+
+// GetByStatus returns the transactions with status, newest first.
+func (m *BankTransactionModel) GetByStatus(status string, limit int) ([]BankTransaction, error) {
+	stmt := `
+	  select id, iban, reference, booking_date, amount, currency,
+	         coalesce(debtor_name, ''), coalesce(creditor_ref, ''), coalesce(message, ''),
+	         status, invoice_id, matched_by, created_at
+	    from bank_transactions
+	   where status = $1
+	order by booking_date desc, id desc
+	   limit $2;
+	`
+
+	rows, err := m.DB.Query(stmt, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("DB.Query(stmt): %v", err)
+	}
+	defer rows.Close()
+
+	var transactions []BankTransaction
+	for rows.Next() {
+		var t BankTransaction
+		err = rows.Scan(
+			&t.ID,
+			&t.IBAN,
+			&t.Reference,
+			&t.BookingDate,
+			&t.Amount,
+			&t.Currency,
+			&t.DebtorName,
+			&t.CreditorRef,
+			&t.Message,
+			&t.Status,
+			&t.InvoiceID,
+			&t.MatchedBy,
+			&t.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("for rows.Next(): %v", err)
+		}
+		transactions = append(transactions, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %v", err)
+	}
+
+	return transactions, nil
+}
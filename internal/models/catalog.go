@@ -0,0 +1,604 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// pricing modes of products, see the CHECK constraints of products:
+const (
+	PricingModeFixed  = "fixed"  // a price per price category
+	PricingModeCustom = "custom" // the member enters the amount, e.g. food
+)
+
+var ErrDuplicateProductCode = errors.New("models: duplicate product code")
+
+// productCodeRX matches codes like "lunch" or "course/weekend". Codes are
+// used in the names of the fields of the activity form.
+var productCodeRX = regexp.MustCompile(`^[a-z0-9]+([/_-][a-z0-9]+)*$`)
+
+// CatalogProduct is a product as admins manage it under /settings/products:
+// all rows of one code in products, with the prices valid today.
+type CatalogProduct struct {
+	Code               string
+	Name               string
+	PricingMode        string
+	FinancialAccountID int
+	TaxID              int
+	SortOrder          sql.NullInt32 // in the activity form, see product_form_order
+
+	// Prices by price category, only for PricingModeFixed.
+	Prices map[string]int
+	// ScheduledPrices become valid after today.
+	ScheduledPrices []ScheduledPrice
+
+	Deleted bool
+}
+
+type ScheduledPrice struct {
+	PriceCategory string
+	Price         int
+	ValidFrom     time.Time
+}
+
+type Tax struct {
+	ID       int
+	Code     string
+	Name     string
+	MWSTSatz int // 8.1% => 810
+}
+
+type FinancialAccount struct {
+	ID   int
+	Code int
+	Name string
+}
+
+// CatalogOptions are what a product can refer to.
+type CatalogOptions struct {
+	FinancialAccounts []FinancialAccount
+	Taxes             []Tax
+	PriceCategories   PriceCategories
+}
+
+// Validate returns the field errors of p, checking what the CHECK and
+// foreign key constraints of products would reject.
+func (p CatalogProduct) Validate(opts CatalogOptions) map[string]string {
+	errs := map[string]string{}
+
+	if p.Name == "" {
+		errs["name"] = "name is required"
+	}
+	if !productCodeRX.MatchString(p.Code) {
+		errs["code"] = "lower case letters and digits, separated by /, _ or -"
+	}
+
+	switch p.PricingMode {
+	case PricingModeFixed:
+		if len(p.Prices) == 0 {
+			errs["prices"] = "a fixed price needs a price in at least one category"
+		}
+		for category, price := range p.Prices {
+			if !opts.hasPriceCategory(category) {
+				errs["prices"] = fmt.Sprintf("unknown price category %s", category)
+			}
+			if price < 0 {
+				errs["prices"] = "prices cannot be negative"
+			}
+		}
+	case PricingModeCustom:
+		if len(p.Prices) > 0 {
+			errs["prices"] = "a custom amount has no prices"
+		}
+	default:
+		errs["pricing_mode"] = "pricing mode is fixed or custom"
+	}
+
+	if !opts.hasFinancialAccount(p.FinancialAccountID) {
+		errs["financial_account_id"] = "choose a financial account"
+	}
+	if !opts.hasTax(p.TaxID) {
+		errs["tax_id"] = "choose a tax"
+	}
+
+	return errs
+}
+
+// FinancialAccount returns the account with id, e.g. to show it in a list.
+func (o CatalogOptions) FinancialAccount(id int) FinancialAccount {
+	for _, a := range o.FinancialAccounts {
+		if a.ID == id {
+			return a
+		}
+	}
+	return FinancialAccount{}
+}
+
+// Tax returns the tax with id.
+func (o CatalogOptions) Tax(id int) Tax {
+	for _, t := range o.Taxes {
+		if t.ID == id {
+			return t
+		}
+	}
+	return Tax{}
+}
+
+func (o CatalogOptions) hasPriceCategory(name string) bool {
+	for _, pc := range o.PriceCategories {
+		if pc.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (o CatalogOptions) hasFinancialAccount(id int) bool {
+	return o.FinancialAccount(id).ID != 0
+}
+
+func (o CatalogOptions) hasTax(id int) bool {
+	return o.Tax(id).ID != 0
+}
+
+// GetCatalogOptions returns the financial accounts, taxes and price
+// categories that are not deleted.
+func (m *ProductModel) GetCatalogOptions() (CatalogOptions, error) {
+	var opts CatalogOptions
+
+	rows, err := m.DB.Query(`
+		select id, code, name
+		  from financial_accounts
+		 where deleted_at is null
+	  order by code;
+	`)
+	if err != nil {
+		return opts, fmt.Errorf("DB.Query(financial_accounts): %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a FinancialAccount
+		if err := rows.Scan(&a.ID, &a.Code, &a.Name); err != nil {
+			return opts, fmt.Errorf("for rows.Next(): %v", err)
+		}
+		opts.FinancialAccounts = append(opts.FinancialAccounts, a)
+	}
+	if err = rows.Err(); err != nil {
+		return opts, fmt.Errorf("rows.Err(): %v", err)
+	}
+
+	rows, err = m.DB.Query(`
+		select id, coalesce(code, ''), coalesce(name, ''), mwst_satz
+		  from taxes
+		 where deleted_at is null
+	  order by code;
+	`)
+	if err != nil {
+		return opts, fmt.Errorf("DB.Query(taxes): %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t Tax
+		if err := rows.Scan(&t.ID, &t.Code, &t.Name, &t.MWSTSatz); err != nil {
+			return opts, fmt.Errorf("for rows.Next(): %v", err)
+		}
+		opts.Taxes = append(opts.Taxes, t)
+	}
+	if err = rows.Err(); err != nil {
+		return opts, fmt.Errorf("rows.Err(): %v", err)
+	}
+
+	rows, err = m.DB.Query(`
+		select id, name
+		  from price_categories
+		 where deleted_at is null
+	  order by name;
+	`)
+	if err != nil {
+		return opts, fmt.Errorf("DB.Query(price_categories): %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pc PriceCategory
+		if err := rows.Scan(&pc.ID, &pc.Name); err != nil {
+			return opts, fmt.Errorf("for rows.Next(): %v", err)
+		}
+		opts.PriceCategories = append(opts.PriceCategories, pc)
+	}
+	if err = rows.Err(); err != nil {
+		return opts, fmt.Errorf("rows.Err(): %v", err)
+	}
+
+	return opts, nil
+}
+
+// GetCatalog returns all products, including the deleted ones, in the order
+// of the activity form.
+func (m *ProductModel) GetCatalog() ([]CatalogProduct, error) {
+	return m.getCatalog("")
+}
+
+// GetCatalogProduct returns the product with code.
+func (m *ProductModel) GetCatalogProduct(code string) (CatalogProduct, error) {
+	products, err := m.getCatalog(code)
+	if err != nil {
+		return CatalogProduct{}, err
+	}
+	if len(products) == 0 {
+		return CatalogProduct{}, ErrNoRecord
+	}
+	return products[0], nil
+}
+
+// getCatalog groups the rows of products by code, all codes if code is "".
+// The rows of a product that ended by today, or end before they become
+// valid, while others did not belong to price categories that were removed
+// and are skipped.
+func (m *ProductModel) getCatalog(code string) ([]CatalogProduct, error) {
+	stmt := `
+	   select p.code,
+	          p.name,
+	          p.pricing_mode,
+	          p.financial_account_id,
+	          p.tax_id,
+	          pfo.sort_order,
+	          coalesce(pc.name, ''),
+	          coalesce(p.price, 0),
+	          p.valid_from,
+	          p.valid_from::date <= current_date as valid_today,
+	          p.deleted_at is not null
+	          and (p.deleted_at::date <= current_date or p.deleted_at <= p.valid_from) as deleted,
+	          bool_and(
+	            p.deleted_at is not null
+	            and (p.deleted_at::date <= current_date or p.deleted_at <= p.valid_from)
+	          ) over (partition by p.code) as product_deleted
+	     from products p
+	left join price_categories pc
+	       on pc.id = p.price_category_id
+	left join product_form_order pfo
+	       on pfo.code = p.code
+	    where $1 = '' or p.code = $1
+	 order by pfo.sort_order nulls last, p.code, p.valid_from;
+	`
+
+	rows, err := m.DB.Query(stmt, code)
+	if err != nil {
+		return nil, fmt.Errorf("DB.Query(stmt): %v", err)
+	}
+	defer rows.Close()
+
+	var products []CatalogProduct
+	for rows.Next() {
+		var p CatalogProduct
+		var category string
+		var price int
+		var validFrom time.Time
+		var validToday, deleted bool
+		err = rows.Scan(
+			&p.Code,
+			&p.Name,
+			&p.PricingMode,
+			&p.FinancialAccountID,
+			&p.TaxID,
+			&p.SortOrder,
+			&category,
+			&price,
+			&validFrom,
+			&validToday,
+			&deleted,
+			&p.Deleted,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("for rows.Next(): %v", err)
+		}
+		if deleted != p.Deleted {
+			continue
+		}
+
+		// rows are ordered by code and valid_from, the last one wins.
+		if n := len(products); n == 0 || products[n-1].Code != p.Code {
+			p.Prices = map[string]int{}
+			products = append(products, p)
+		}
+		cur := &products[len(products)-1]
+		cur.Name = p.Name
+		cur.PricingMode = p.PricingMode
+		cur.FinancialAccountID = p.FinancialAccountID
+		cur.TaxID = p.TaxID
+
+		if p.PricingMode != PricingModeFixed {
+			continue
+		}
+		if validToday {
+			cur.Prices[category] = price
+		} else {
+			cur.ScheduledPrices = append(cur.ScheduledPrices, ScheduledPrice{
+				PriceCategory: category,
+				Price:         price,
+				ValidFrom:     validFrom,
+			})
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %v", err)
+	}
+
+	return products, nil
+}
+
+// SaveCatalogProduct creates the product p, or updates the product with
+// oldCode if it is not "". Name, code, financial account and tax are changed
+// on all rows of the product. A changed price is a new row valid from
+// validFrom, so that earlier activities keep their price, see GetAllAt.
+// Removed price categories end the day before validFrom.
+func (m *ProductModel) SaveCatalogProduct(oldCode string, p CatalogProduct, validFrom time.Time) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if p.Code != oldCode {
+		var exists bool
+		err := tx.QueryRow(`select exists (select 1 from products where code = $1);`, p.Code).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("DB.QueryRow(): %v", err)
+		}
+		if exists {
+			return ErrDuplicateProductCode
+		}
+	}
+
+	if oldCode != "" {
+		stmt := `
+		update products
+		   set name = $2,
+		       code = $3,
+		       financial_account_id = $4,
+		       tax_id = $5,
+		       updated_at = now()
+		 where code = $1;
+		`
+		_, err := tx.Exec(stmt, oldCode, p.Name, p.Code, p.FinancialAccountID, p.TaxID)
+		if err != nil {
+			return fmt.Errorf("failed updating products %s: %v", oldCode, err)
+		}
+
+		_, err = tx.Exec(`delete from product_form_order where code = $1;`, oldCode)
+		if err != nil {
+			return fmt.Errorf("failed deleting form order of %s: %v", oldCode, err)
+		}
+	}
+
+	if err := savePricesTx(p, validFrom, tx); err != nil {
+		return err
+	}
+
+	if p.SortOrder.Valid {
+		stmt := `
+		insert into product_form_order (code, sort_order)
+		values ($1, $2)
+		on conflict (code) do update
+		   set sort_order = excluded.sort_order;
+		`
+		if _, err := tx.Exec(stmt, p.Code, p.SortOrder.Int32); err != nil {
+			return fmt.Errorf("failed saving form order of %s: %v", p.Code, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// currentPrice is the row of a price category of a product valid on the
+// day a change takes effect.
+type currentPrice struct {
+	id          int
+	price       int
+	sameDay     bool // the row became valid that day
+	samePricing bool // the row has the pricing mode of the change
+}
+
+// pricePlan is what savePricesTx does to the rows of a product.
+type pricePlan struct {
+	retire []string       // price categories that end the day before
+	update map[int]int    // prices by row ID, corrected on the same day
+	insert map[string]int // new rows by price category
+}
+
+// planPrices compares the prices that p wants with the rows valid on the day
+// the change takes effect. "" is the price category of a custom amount.
+func planPrices(p CatalogProduct, existing map[string]currentPrice) pricePlan {
+	wanted := map[string]int{}
+	if p.PricingMode == PricingModeCustom {
+		wanted[""] = 0
+	}
+	for category, price := range p.Prices {
+		wanted[category] = price
+	}
+
+	plan := pricePlan{update: map[int]int{}, insert: map[string]int{}}
+
+	for category, c := range existing {
+		if _, ok := wanted[category]; ok && c.samePricing {
+			continue
+		}
+		// removed category or other pricing mode.
+		plan.retire = append(plan.retire, category)
+	}
+	slices.Sort(plan.retire)
+
+	for category, price := range wanted {
+		c, ok := existing[category]
+		switch {
+		case ok && c.samePricing && c.price == price:
+			// unchanged.
+		case ok && c.samePricing && c.sameDay:
+			// a second change on the same day corrects the first one.
+			plan.update[c.id] = price
+		default:
+			plan.insert[category] = price
+		}
+	}
+
+	return plan
+}
+
+// savePricesTx brings the rows of p in line with its pricing mode and
+// prices on validFrom. Rows end instead of being deleted, so that the days
+// before validFrom keep their prices, see validProducts.
+func savePricesTx(p CatalogProduct, validFrom time.Time, tx *sql.Tx) error {
+	// the rows valid on validFrom, as in validProducts.
+	stmt := `
+	   select distinct on (p.price_category_id)
+	          p.id, p.pricing_mode, coalesce(pc.name, ''), coalesce(p.price, 0),
+	          p.valid_from::date = $2::date
+	     from products p
+	left join price_categories pc
+	       on pc.id = p.price_category_id
+	    where p.code = $1
+	      and (p.deleted_at is null or p.deleted_at::date > $2::date)
+	      and p.valid_from::date <= $2::date
+	 order by p.price_category_id, p.valid_from desc;
+	`
+	rows, err := tx.Query(stmt, p.Code, validFrom)
+	if err != nil {
+		return fmt.Errorf("DB.Query(stmt): %v", err)
+	}
+
+	existing := map[string]currentPrice{}
+	for rows.Next() {
+		var c currentPrice
+		var mode, category string
+		if err := rows.Scan(&c.id, &mode, &category, &c.price, &c.sameDay); err != nil {
+			rows.Close()
+			return fmt.Errorf("for rows.Next(): %v", err)
+		}
+		c.samePricing = mode == p.PricingMode
+		existing[category] = c
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows.Err(): %v", err)
+	}
+	rows.Close()
+
+	plan := planPrices(p, existing)
+
+	for _, category := range plan.retire {
+		// all rows of the category end the day before validFrom, including
+		// rows scheduled later. Rows that ended earlier keep their end.
+		stmt := `
+		update products
+		   set deleted_at = $3::date
+		 where code = $1
+		   and coalesce(price_category_id, 0) = coalesce((select id from price_categories where name = $2), 0)
+		   and (deleted_at is null or deleted_at::date > $3::date);
+		`
+		if _, err := tx.Exec(stmt, p.Code, category, validFrom); err != nil {
+			return fmt.Errorf("failed ending price %s of %s: %v", category, p.Code, err)
+		}
+	}
+
+	for id, price := range plan.update {
+		_, err := tx.Exec(`update products set price = $2, updated_at = now() where id = $1;`, id, price)
+		if err != nil {
+			return fmt.Errorf("failed updating price of %s: %v", p.Code, err)
+		}
+	}
+
+	for category, price := range plan.insert {
+		stmt := `
+		insert into products (
+			name,
+			code,
+			pricing_mode,
+			price,
+			price_category_id,
+			financial_account_id,
+			tax_id,
+			valid_from
+		) values (
+			$1, $2, $3,
+			$4,
+			(select id from price_categories where name = $5),
+			$6, $7,
+			$8::date
+		);
+		`
+		var priceArg sql.NullInt64
+		if p.PricingMode == PricingModeFixed {
+			priceArg = sql.NullInt64{Int64: int64(price), Valid: true}
+		}
+		_, err := tx.Exec(stmt,
+			p.Name, p.Code, p.PricingMode,
+			priceArg,
+			category,
+			p.FinancialAccountID, p.TaxID,
+			validFrom,
+		)
+		if err != nil {
+			return fmt.Errorf("failed inserting price %s of %s: %v", category, p.Code, err)
+		}
+	}
+
+	return nil
+}
+
+// DeleteCatalogProduct soft deletes the product with code from today on. It
+// disappears from the activity form, activities of earlier days keep it.
+func (m *ProductModel) DeleteCatalogProduct(code string) error {
+	stmt := `
+	update products
+	   set deleted_at = now()
+	 where code = $1
+	   and (deleted_at is null or deleted_at > now());
+	`
+	return m.execCatalog(stmt, code)
+}
+
+// RestoreCatalogProduct undoes DeleteCatalogProduct. Price categories that
+// were removed before stay removed, removals scheduled after the deletion
+// are undone.
+func (m *ProductModel) RestoreCatalogProduct(code string) error {
+	stmt := `
+	update products
+	   set deleted_at = null
+	 where code = $1
+	   and deleted_at = (select max(deleted_at) from products where code = $1);
+	`
+	return m.execCatalog(stmt, code)
+}
+
+func (m *ProductModel) execCatalog(stmt, code string) error {
+	result, err := m.DB.Exec(stmt, code)
+	if err != nil {
+		return fmt.Errorf("failed updating products %s: %v", code, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
+
+// GetCatalogVersion returns the version of the catalog. The triggers of
+// migration 000018 bump it with every change to products, price categories
+// or the order of the activity form.
+func (m *ProductModel) GetCatalogVersion() (int64, error) {
+	var version int64
+	err := m.DB.QueryRow(`SELECT version FROM catalog_version`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("DB.QueryRow(stmt): %v", err)
+	}
+	return version, nil
+}
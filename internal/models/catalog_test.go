@@ -0,0 +1,183 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCatalogProductValidate(t *testing.T) {
+	opts := CatalogOptions{
+		FinancialAccounts: []FinancialAccount{{ID: 1, Code: 3000, Name: "Lebensmittelertrag"}},
+		Taxes:             []Tax{{ID: 2, Code: "B81", MWSTSatz: 810}},
+		PriceCategories:   PriceCategories{{ID: 1, Name: "regular"}, {ID: 2, Name: "reduced"}},
+	}
+
+	valid := CatalogProduct{
+		Code:               "course/weekend",
+		Name:               "Kurs (Wochenende)",
+		PricingMode:        PricingModeFixed,
+		FinancialAccountID: 1,
+		TaxID:              2,
+		Prices:             map[string]int{"regular": 4000},
+	}
+
+	tests := []struct {
+		name   string
+		modify func(p *CatalogProduct)
+		fields []string
+	}{
+		{"valid", func(p *CatalogProduct) {}, nil},
+		{"custom", func(p *CatalogProduct) { p.PricingMode = PricingModeCustom; p.Prices = nil }, nil},
+		{"no name", func(p *CatalogProduct) { p.Name = "" }, []string{"name"}},
+		{"code with space", func(p *CatalogProduct) { p.Code = "course weekend" }, []string{"code"}},
+		{"code upper case", func(p *CatalogProduct) { p.Code = "Lunch" }, []string{"code"}},
+		{"fixed without prices", func(p *CatalogProduct) { p.Prices = nil }, []string{"prices"}},
+		{"custom with prices", func(p *CatalogProduct) { p.PricingMode = PricingModeCustom }, []string{"prices"}},
+		{"negative price", func(p *CatalogProduct) { p.Prices = map[string]int{"regular": -100} }, []string{"prices"}},
+		{"unknown category", func(p *CatalogProduct) { p.Prices = map[string]int{"vip": 100} }, []string{"prices"}},
+		{"unknown pricing mode", func(p *CatalogProduct) { p.PricingMode = "free" }, []string{"pricing_mode"}},
+		{"unknown account and tax", func(p *CatalogProduct) { p.FinancialAccountID = 9; p.TaxID = 0 }, []string{"financial_account_id", "tax_id"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := valid
+			tt.modify(&p)
+
+			var fields []string
+			errs := p.Validate(opts)
+			for _, f := range []string{"name", "code", "pricing_mode", "prices", "financial_account_id", "tax_id"} {
+				if _, ok := errs[f]; ok {
+					fields = append(fields, f)
+				}
+			}
+			if len(errs) != len(fields) {
+				t.Fatalf("unexpected fields in %v", errs)
+			}
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("got errors in %v, want %v (%v)", fields, tt.fields, errs)
+			}
+		})
+	}
+}
+
+// priceRow is a row of products in the tests of planPrices.
+type priceRow struct {
+	id        int
+	category  string
+	price     int
+	validFrom string
+	ends      string // deleted_at, "" for none
+}
+
+// priceTable applies a pricePlan the way savePricesTx does and selects
+// prices the way validProducts does. Dates are "2006-01-02".
+type priceTable []priceRow
+
+// latestOn returns the rows by category valid on date.
+func (t priceTable) latestOn(date string) map[string]priceRow {
+	latest := map[string]priceRow{}
+	for _, r := range t {
+		if r.validFrom > date || (r.ends != "" && r.ends <= date) {
+			continue
+		}
+		if l, ok := latest[r.category]; !ok || r.validFrom > l.validFrom {
+			latest[r.category] = r
+		}
+	}
+	return latest
+}
+
+// validOn returns the prices by category valid on date.
+func (t priceTable) validOn(date string) map[string]int {
+	prices := map[string]int{}
+	for category, r := range t.latestOn(date) {
+		prices[category] = r.price
+	}
+	return prices
+}
+
+func (t priceTable) save(p CatalogProduct, validFrom string) priceTable {
+	existing := map[string]currentPrice{}
+	for category, r := range t.latestOn(validFrom) {
+		existing[category] = currentPrice{id: r.id, price: r.price, sameDay: r.validFrom == validFrom, samePricing: true}
+	}
+
+	plan := planPrices(p, existing)
+	for _, category := range plan.retire {
+		for i := range t {
+			if t[i].category == category && (t[i].ends == "" || t[i].ends > validFrom) {
+				t[i].ends = validFrom
+			}
+		}
+	}
+	for i := range t {
+		if price, ok := plan.update[t[i].id]; ok {
+			t[i].price = price
+		}
+	}
+	for category, price := range plan.insert {
+		t = append(t, priceRow{id: len(t) + 1, category: category, price: price, validFrom: validFrom})
+	}
+	return t
+}
+
+func TestPlanPrices(t *testing.T) {
+	lunch := CatalogProduct{Code: "lunch", PricingMode: PricingModeFixed}
+	table := priceTable{
+		{id: 1, category: "regular", price: 1500, validFrom: "2026-01-01"},
+		{id: 2, category: "reduced", price: 1000, validFrom: "2026-01-01"},
+	}
+
+	// a price change from March.
+	lunch.Prices = map[string]int{"regular": 1700, "reduced": 1000}
+	table = table.save(lunch, "2026-03-01")
+
+	// reduced is removed from May.
+	lunch.Prices = map[string]int{"regular": 1700}
+	table = table.save(lunch, "2026-05-01")
+
+	// a correction of the price on the same day is no new row.
+	lunch.Prices = map[string]int{"regular": 1800}
+	table = table.save(lunch, "2026-05-01")
+
+	tests := []struct {
+		date   string
+		prices map[string]int
+	}{
+		// editing an activity of February keeps both old prices.
+		{"2026-02-15", map[string]int{"regular": 1500, "reduced": 1000}},
+		{"2026-03-01", map[string]int{"regular": 1700, "reduced": 1000}},
+		{"2026-04-30", map[string]int{"regular": 1700, "reduced": 1000}},
+		{"2026-05-01", map[string]int{"regular": 1800}},
+		{"2025-12-31", map[string]int{}},
+	}
+
+	for _, tt := range tests {
+		if got := table.validOn(tt.date); !reflect.DeepEqual(got, tt.prices) {
+			t.Errorf("%s: expected prices %v, got %v", tt.date, tt.prices, got)
+		}
+	}
+
+	if len(table) != 4 {
+		t.Errorf("expected 4 rows, got %d: %v", len(table), table)
+	}
+}
+
+func TestPlanPricesPricingMode(t *testing.T) {
+	existing := map[string]currentPrice{
+		"regular": {id: 1, price: 1500, samePricing: false},
+	}
+	p := CatalogProduct{Code: "food", PricingMode: PricingModeCustom}
+
+	plan := planPrices(p, existing)
+	if !reflect.DeepEqual(plan.retire, []string{"regular"}) {
+		t.Errorf("expected regular to end, got %v", plan.retire)
+	}
+	if !reflect.DeepEqual(plan.insert, map[string]int{"": 0}) {
+		t.Errorf("expected a custom amount row, got %v", plan.insert)
+	}
+	if len(plan.update) != 0 {
+		t.Errorf("expected no updates, got %v", plan.update)
+	}
+}
@@ -37,31 +37,47 @@ func (m *ConsumptionModel) InsertManyWithTransaction(
 		return fmt.Errorf("failed deleting consumptions: %s", err)
 	}
 
-	// TODO: Deal with TaxPrice
+	// the tax of the product is copied, so that the consumption keeps the
+	// rate it was sold with.
 	for _, c := range consumptions {
 		ins := `
         insert into consumptions (
 			activity_id,
 			product_id,
 			unit_price,
-			quantity
+			quantity,
+			tax_id,
+			tax_rate
 		)
-        values (
-			$1,
-			$2,
-			$3,
-			$4
-		);
+        select $1,
+               p.id,
+               $3,
+               $4,
+               t.id,
+               t.mwst_satz
+          from products p
+          join taxes t
+            on t.id = p.tax_id
+         where p.id = $2;
     `
-		if _, err = tx.Exec(
+		result, err := tx.Exec(
 			ins,
 			c.ActivityID,
 			c.ProductID,
 			c.UnitPrice,
 			c.Quantity,
-		); err != nil {
+		)
+		if err != nil {
 			return fmt.Errorf("failed inserting consumptions: %s", err)
 		}
+
+		n, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed inserting consumptions: %s", err)
+		}
+		if n == 0 {
+			return fmt.Errorf("failed inserting consumptions: no product with id %d", c.ProductID)
+		}
 	}
 
 	return nil
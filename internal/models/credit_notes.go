@@ -0,0 +1,92 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type CreditNoteModel struct {
+	DB *sql.DB
+}
+
+// CreditNote reverses a cancelled invoice in full.
+type CreditNote struct {
+	ID        int
+	InvoiceID int
+	Number    string // e.g. GS-2026-0001
+	Amount    int
+	Reason    string
+	CreatedBy sql.NullInt32
+	CreatedAt time.Time
+}
+
+// InsertTx issues a credit note over the total of the invoice. createdBy is
+// the ID of the admin, 0 means the system.
+func (m *CreditNoteModel) InsertTx(invoiceID int, reason string, createdBy int, tx *sql.Tx) (CreditNote, error) {
+	number, err := nextNumberTx(CreditNoteNumberPrefix, tx)
+	if err != nil {
+		return CreditNote{}, err
+	}
+
+	stmt := `
+	   insert into credit_notes (
+	          invoice_id, number, amount, reason, created_by
+	   )
+	   select $1,
+	          $2,
+	          coalesce(sum(c.total_price), 0),
+	          $3,
+	          $4
+	     from activities a
+	     join consumptions c
+	       on c.activity_id = a.id
+	    where a.invoice_id = $1
+	returning id, amount, created_at;
+	`
+
+	n := CreditNote{
+		InvoiceID: invoiceID,
+		Number:    number,
+		Reason:    reason,
+		CreatedBy: sql.NullInt32{Int32: int32(createdBy), Valid: createdBy != 0},
+	}
+	err = tx.QueryRow(stmt, invoiceID, number, reason, n.CreatedBy).Scan(
+		&n.ID,
+		&n.Amount,
+		&n.CreatedAt,
+	)
+	if err != nil {
+		return CreditNote{}, fmt.Errorf("failed inserting credit note: %v", err)
+	}
+
+	return n, nil
+}
+
+func (m *CreditNoteModel) GetByInvoiceID(invoiceID int) (CreditNote, error) {
+	stmt := `
+	select id, invoice_id, number, amount, reason, created_by, created_at
+	  from credit_notes
+	 where invoice_id = $1;
+	`
+
+	var n CreditNote
+	err := m.DB.QueryRow(stmt, invoiceID).Scan(
+		&n.ID,
+		&n.InvoiceID,
+		&n.Number,
+		&n.Amount,
+		&n.Reason,
+		&n.CreatedBy,
+		&n.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return CreditNote{}, ErrNoRecord
+		}
+		return CreditNote{}, fmt.Errorf("DB.QueryRow(): %v", err)
+	}
+
+	return n, nil
+}
@@ -8,4 +8,6 @@ var (
 	ErrNoRecord           = errors.New("models: no matching record found")
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 	ErrDuplicateEmail     = errors.New("models: duplicate email")
+	ErrInvalidTransition  = errors.New("models: invalid status transition")
+	ErrMissingReason      = errors.New("models: missing reason")
 )
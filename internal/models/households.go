@@ -0,0 +1,281 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrAlreadyInHousehold = errors.New("models: user is already a member of a household")
+	ErrBillingContact     = errors.New("models: the billing contact must be a member of the household")
+)
+
+// Household is a group of members, e.g. a couple or a family, that gets one
+// invoice, addressed to the billing contact.
+type Household struct {
+	ID            int
+	Name          string
+	BillingUserID int
+	Members       []User
+}
+
+// BillingContact returns the member who receives the invoices.
+func (h Household) BillingContact() User {
+	for _, u := range h.Members {
+		if u.ID == h.BillingUserID {
+			return u
+		}
+	}
+	return User{ID: h.BillingUserID}
+}
+
+type HouseholdModel struct {
+	DB *sql.DB
+}
+
+// billedUsers selects the IDs of the users whose activities are invoiced to
+// the user $2: the members of the household they are the billing contact
+// of, or only the user if they are not in a household.
+const billedUsers = `
+	select u.id
+	  from bellevue.users u
+ left join bellevue.households h
+	    on h.id = u.household_id
+	 where coalesce(h.billing_user_id, u.id) = $2
+`
+
+// GetAll returns the households with their members, by name.
+func (m *HouseholdModel) GetAll() ([]Household, error) {
+	stmt := `
+	   select h.id,
+	          h.name,
+	          h.billing_user_id,
+	          u.id,
+	          u.first_name,
+	          u.last_name,
+	          u.email
+	     from households h
+	     join users u
+	       on u.household_id = h.id
+	 order by h.name, h.id, u.first_name, u.last_name
+	`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, fmt.Errorf("DB.Query(stmt): %v", err)
+	}
+	defer rows.Close()
+
+	var households []Household
+	for rows.Next() {
+		var h Household
+		var u User
+		err = rows.Scan(
+			&h.ID,
+			&h.Name,
+			&h.BillingUserID,
+			&u.ID,
+			&u.FirstName,
+			&u.LastName,
+			&u.Email,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("for rows.Next(): %v", err)
+		}
+		if n := len(households); n == 0 || households[n-1].ID != h.ID {
+			households = append(households, h)
+		}
+		last := &households[len(households)-1]
+		last.Members = append(last.Members, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %v", err)
+	}
+
+	return households, nil
+}
+
+// Insert creates a household with the billing contact as its first member.
+func (m *HouseholdModel) Insert(name string, billingUserID int) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var householdID int
+	stmt := `
+	insert into households (name, billing_user_id)
+	values ($1, $2)
+	returning id
+	`
+	if err := tx.QueryRow(stmt, name, billingUserID).Scan(&householdID); err != nil {
+		return 0, fmt.Errorf("failed inserting household: %v", err)
+	}
+
+	if err := joinHouseholdTx(householdID, billingUserID, tx); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed committing transaction: %v", err)
+	}
+
+	return householdID, nil
+}
+
+// AddMember adds the user to the household. Their open activities are
+// invoiced to the billing contact from now on. A user is in one household
+// at most, else ErrAlreadyInHousehold.
+func (m *HouseholdModel) AddMember(householdID, userID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := joinHouseholdTx(householdID, userID, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func joinHouseholdTx(householdID, userID int, tx *sql.Tx) error {
+	stmt := `
+	update users
+	   set household_id = $1
+	 where id = $2
+	   and household_id is null
+	`
+	result, err := tx.Exec(stmt, householdID, userID)
+	if err != nil {
+		return fmt.Errorf("failed adding userID=%d to household %d: %v", userID, householdID, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrAlreadyInHousehold
+	}
+	return nil
+}
+
+// RemoveMember removes the user from the household. The billing contact
+// cannot be removed, else ErrBillingContact.
+func (m *HouseholdModel) RemoveMember(householdID, userID int) error {
+	stmt := `
+	update users u
+	   set household_id = null
+	  from households h
+	 where h.id = u.household_id
+	   and h.id = $1
+	   and u.id = $2
+	   and h.billing_user_id <> u.id
+	`
+	result, err := m.DB.Exec(stmt, householdID, userID)
+	if err != nil {
+		return fmt.Errorf("failed removing userID=%d from household %d: %v", userID, householdID, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrBillingContact
+	}
+	return nil
+}
+
+// SetBillingContact makes the member the billing contact of the household,
+// else ErrBillingContact if they are not a member.
+func (m *HouseholdModel) SetBillingContact(householdID, userID int) error {
+	stmt := `
+	update households h
+	   set billing_user_id = $2,
+	       updated_at = now()
+	 where h.id = $1
+	   and exists (
+	       select 1
+	         from users u
+	        where u.id = $2
+	          and u.household_id = h.id
+	   )
+	`
+	result, err := m.DB.Exec(stmt, householdID, userID)
+	if err != nil {
+		return fmt.Errorf("failed setting billing contact of household %d: %v", householdID, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrBillingContact
+	}
+	return nil
+}
+
+// GetBillingContact returns the member who receives the invoices for the
+// activities of the user: the billing contact of their household, or the
+// user themselves.
+func (m *HouseholdModel) GetBillingContact(userID int) (User, error) {
+	stmt := `
+	select b.id, b.first_name, b.last_name, b.email, b.role
+	  from users u
+ left join households h
+	    on h.id = u.household_id
+	  join users b
+	    on b.id = coalesce(h.billing_user_id, u.id)
+	 where u.id = $1
+	`
+
+	var b User
+	err := m.DB.QueryRow(stmt, userID).Scan(
+		&b.ID,
+		&b.FirstName,
+		&b.LastName,
+		&b.Email,
+		&b.Role,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return User{}, ErrNoRecord
+		}
+		return User{}, fmt.Errorf("DB.QueryRow(): %v", err)
+	}
+
+	return b, nil
+}
+
+// Delete removes the members from the household and deletes it. Its
+// invoices stay with the billing contact.
+func (m *HouseholdModel) Delete(householdID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`update users set household_id = null where household_id = $1`, householdID)
+	if err != nil {
+		return fmt.Errorf("failed removing members of household %d: %v", householdID, err)
+	}
+
+	result, err := tx.Exec(`delete from households where id = $1`, householdID)
+	if err != nil {
+		return fmt.Errorf("failed deleting household %d: %v", householdID, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return tx.Commit()
+}
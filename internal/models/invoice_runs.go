@@ -0,0 +1,173 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type InvoiceRunModel struct {
+	DB *sql.DB
+}
+
+// send status of an invoice in a run:
+const (
+	SendStatusPending = "pending"
+	SendStatusSent    = "sent"
+	SendStatusFailed  = "failed"
+)
+
+type InvoiceRun struct {
+	ID         int
+	Mode       string
+	Sent       int
+	Failed     int
+	StartedAt  time.Time
+	FinishedAt sql.NullTime
+}
+
+// InvoiceRunItem is an invoice that a run created and has to send.
+type InvoiceRunItem struct {
+	RunID      int
+	InvoiceID  int
+	UserID     int
+	Email      string
+	SendStatus string
+	Error      string
+	Attempts   int
+}
+
+// Insert starts a run and returns its ID.
+func (m *InvoiceRunModel) Insert(mode string) (int, error) {
+	stmt := `
+	insert into invoice_runs (mode)
+	values ($1)
+	returning id;
+	`
+
+	var id int
+	if err := m.DB.QueryRow(stmt, mode).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed inserting invoice run: %v", err)
+	}
+	return id, nil
+}
+
+// Finish records the result of the run.
+func (m *InvoiceRunModel) Finish(runID, sent, failed int) error {
+	stmt := `
+	update invoice_runs
+	   set sent = $2,
+	       failed = $3,
+	       finished_at = now()
+	 where id = $1;
+	`
+
+	if _, err := m.DB.Exec(stmt, runID, sent, failed); err != nil {
+		return fmt.Errorf("failed finishing invoice run %d: %v", runID, err)
+	}
+	return nil
+}
+
+// InsertItemTx records the invoice as pending in the run. It is called in the
+// transaction that creates the invoice, so that every invoice of a run is
+// sent eventually.
+func (m *InvoiceRunModel) InsertItemTx(runID, invoiceID, userID int, tx *sql.Tx) error {
+	stmt := `
+	insert into invoice_run_items (run_id, invoice_id, user_id)
+	values ($1, $2, $3);
+	`
+
+	if _, err := tx.Exec(stmt, runID, invoiceID, userID); err != nil {
+		return fmt.Errorf("failed inserting invoice run item: %v", err)
+	}
+	return nil
+}
+
+// GetUnsentItems returns the pending and failed invoices of all runs that are
+// still drafts, oldest first. Invoices that were cancelled in the meantime are
+// not sent anymore.
+func (m *InvoiceRunModel) GetUnsentItems() ([]InvoiceRunItem, error) {
+	stmt := `
+	   select r.run_id,
+	          r.invoice_id,
+	          r.user_id,
+	          u.email,
+	          r.send_status,
+	          coalesce(r.error, ''),
+	          r.attempts
+	     from invoice_run_items r
+	     join invoices_v2 i
+	       on i.id = r.invoice_id
+	     join users u
+	       on u.id = r.user_id
+	    where r.send_status in ('pending', 'failed')
+	      and i.status = 'draft'
+	 order by r.created_at;
+	`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, fmt.Errorf("DB.Query(stmt): %v", err)
+	}
+	defer rows.Close()
+
+	var items []InvoiceRunItem
+	for rows.Next() {
+		var i InvoiceRunItem
+		err = rows.Scan(
+			&i.RunID,
+			&i.InvoiceID,
+			&i.UserID,
+			&i.Email,
+			&i.SendStatus,
+			&i.Error,
+			&i.Attempts,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("for rows.Next(): %v", err)
+		}
+		items = append(items, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %v", err)
+	}
+
+	return items, nil
+}
+
+// MarkSentTx marks the invoice as sent in its run, together with the status
+// change of the invoice itself.
+func (m *InvoiceRunModel) MarkSentTx(invoiceID int, tx *sql.Tx) error {
+	stmt := `
+	update invoice_run_items
+	   set send_status = 'sent',
+	       error = null,
+	       attempts = attempts + 1,
+	       updated_at = now()
+	 where invoice_id = $1;
+	`
+
+	if _, err := tx.Exec(stmt, invoiceID); err != nil {
+		return fmt.Errorf("failed marking invoice %d as sent: %v", invoiceID, err)
+	}
+	return nil
+}
+
+// MarkFailed records why the invoice could not be sent. It is retried by the
+// next run.
+func (m *InvoiceRunModel) MarkFailed(invoiceID int, cause error) error {
+	stmt := `
+	update invoice_run_items
+	   set send_status = 'failed',
+	       error = $2,
+	       attempts = attempts + 1,
+	       updated_at = now()
+	 where invoice_id = $1;
+	`
+
+	if _, err := m.DB.Exec(stmt, invoiceID, cause.Error()); err != nil {
+		return fmt.Errorf("failed marking invoice %d as failed: %v", invoiceID, err)
+	}
+	return nil
+}
@@ -2,6 +2,10 @@ package models
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -10,14 +14,357 @@ type InvoiceV2Model struct {
 }
 
 type InvoiceV2 struct {
-	ID        int
-	UserID    int
-	Status    string // draft sent paid cancelled
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID          int
+	UserID      int
+	Status      string // draft sent paid cancelled
+	Reference   string // ISO 11649 creditor reference, e.g. RF340000000042
+	Number      string // e.g. BV-2026-0042, assigned when it leaves draft, see AssignNumberTx
+	SentAt      sql.NullTime
+	PaidAt      sql.NullTime
+	CancelledAt sql.NullTime
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+
+	// CancellationReason is set together with the status cancelled.
+	CancellationReason string
 }
 
-func (m *InvoiceV2Model) ToggleState(ID int) {}
+const (
+	InvoiceStatusDraft     = "draft"
+	InvoiceStatusSent      = "sent"
+	InvoiceStatusPaid      = "paid"
+	InvoiceStatusCancelled = "cancelled"
+)
+
+// invoiceTransitions lists the allowed status changes per status.
+// paid and cancelled are final.
+var invoiceTransitions = map[string][]string{
+	InvoiceStatusDraft: {InvoiceStatusSent, InvoiceStatusCancelled},
+	InvoiceStatusSent:  {InvoiceStatusPaid, InvoiceStatusCancelled},
+}
+
+// NextInvoiceStatuses returns the statuses that an invoice in status can be
+// moved to.
+func NextInvoiceStatuses(status string) []string {
+	return invoiceTransitions[status]
+}
+
+func CanTransitionInvoice(from, to string) bool {
+	return slices.Contains(invoiceTransitions[from], to)
+}
+
+const getInvoiceStmt = `
+	select id,
+	       user_id,
+	       status,
+	       reference,
+	       coalesce(number, ''),
+	       coalesce(cancellation_reason, ''),
+	       sent_at,
+	       paid_at,
+	       cancelled_at,
+	       created_at,
+	       updated_at
+	  from invoices_v2
+	 where id = $1;
+	`
+
+func (m *InvoiceV2Model) Get(invoiceID int) (InvoiceV2, error) {
+	return scanInvoice(m.DB.QueryRow(getInvoiceStmt, invoiceID))
+}
+
+// GetTx reads the invoice within tx, e.g. with the number that tx assigned.
+func (m *InvoiceV2Model) GetTx(invoiceID int, tx *sql.Tx) (InvoiceV2, error) {
+	return scanInvoice(tx.QueryRow(getInvoiceStmt, invoiceID))
+}
+
+func scanInvoice(row *sql.Row) (InvoiceV2, error) {
+	var i InvoiceV2
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Status,
+		&i.Reference,
+		&i.Number,
+		&i.CancellationReason,
+		&i.SentAt,
+		&i.PaidAt,
+		&i.CancelledAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return InvoiceV2{}, ErrNoRecord
+		}
+		return InvoiceV2{}, fmt.Errorf("DB.QueryRow(): %v", err)
+	}
+
+	return i, nil
+}
+
+// SetStatus moves the invoice to status in its own transaction.
+// changedBy is the ID of the user who made the change, 0 means the system.
+func (m *InvoiceV2Model) SetStatus(invoiceID int, status string, changedBy int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := m.SetStatusTx(invoiceID, status, changedBy, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetStatusTx moves the invoice to status if the transition is allowed,
+// stamps the matching *_at column and records who made the change.
+// changedBy is the ID of the user who made the change, 0 means the system.
+func (m *InvoiceV2Model) SetStatusTx(invoiceID int, status string, changedBy int, tx *sql.Tx) error {
+	var from string
+	row := tx.QueryRow(`select status from invoices_v2 where id = $1 for update;`, invoiceID)
+	if err := row.Scan(&from); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return fmt.Errorf("failed reading invoice status: %v", err)
+	}
+
+	if !CanTransitionInvoice(from, status) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, status)
+	}
+
+	if status == InvoiceStatusSent {
+		if _, err := m.AssignNumberTx(invoiceID, tx); err != nil {
+			return err
+		}
+		if err := m.insertTaxLinesTx(invoiceID, tx); err != nil {
+			return err
+		}
+	}
+
+	var column string
+	switch status {
+	case InvoiceStatusSent:
+		column = "sent_at"
+	case InvoiceStatusPaid:
+		column = "paid_at"
+	case InvoiceStatusCancelled:
+		column = "cancelled_at"
+	}
+
+	// column is one of the constants above, never user input.
+	stmt := fmt.Sprintf(`
+	update invoices_v2
+	   set status = $2,
+	       %s = now(),
+	       updated_at = now()
+	 where id = $1;
+	`, column)
+	if _, err := tx.Exec(stmt, invoiceID, status); err != nil {
+		return fmt.Errorf("failed updating invoice status: %v", err)
+	}
+
+	stmt = `
+	insert into invoice_status_changes (
+		invoice_id, from_status, to_status, changed_by
+	) values (
+		$1,         $2,          $3,        $4
+	);
+	`
+	by := sql.NullInt32{Int32: int32(changedBy), Valid: changedBy != 0}
+	if _, err := tx.Exec(stmt, invoiceID, from, status, by); err != nil {
+		return fmt.Errorf("failed inserting invoice status change: %v", err)
+	}
+
+	return nil
+}
+
+const (
+	InvoiceNumberPrefix    = "BV"
+	CreditNoteNumberPrefix = "GS" // Gutschrift
+)
+
+// CancelTx cancels the invoice for reason, see SetStatusTx, releases the
+// payments allocated to it and fails its invoice email if it is still in the
+// outbox. What happens to its activities is up to the
+// caller, see ReleaseActivitiesTx and CreditNoteModel.InsertTx.
+func (m *InvoiceV2Model) CancelTx(invoiceID int, reason string, changedBy int, tx *sql.Tx) error {
+	if strings.TrimSpace(reason) == "" {
+		return ErrMissingReason
+	}
+
+	if err := m.SetStatusTx(invoiceID, InvoiceStatusCancelled, changedBy, tx); err != nil {
+		return err
+	}
+
+	stmt := `
+	update invoices_v2
+	   set cancellation_reason = $2
+	 where id = $1;
+	`
+	if _, err := tx.Exec(stmt, invoiceID, strings.TrimSpace(reason)); err != nil {
+		return fmt.Errorf("failed updating cancellation reason: %v", err)
+	}
+
+	// what was paid towards the invoice becomes credit again, see
+	// PaymentModel.CreditForUser and PaymentModel.AllocateTx.
+	if _, err := tx.Exec(`delete from payment_allocations where invoice_id = $1;`, invoiceID); err != nil {
+		return fmt.Errorf("failed releasing payment allocations: %v", err)
+	}
+
+	// the email of a draft that did not go out yet must not go out any more.
+	stmt = `
+	update email_outbox
+	   set status = 'failed',
+	       last_error = 'invoice cancelled'
+	 where invoice_id = $1
+	   and kind = 'invoice'
+	   and status = 'pending';
+	`
+	if _, err := tx.Exec(stmt, invoiceID); err != nil {
+		return fmt.Errorf("failed cancelling pending invoice emails: %v", err)
+	}
+
+	return nil
+}
+
+// insertTaxLinesTx records the revenue of the invoice per tax and financial
+// account as it is sent. The MWST report books it from there, also after a
+// cancellation released the activities.
+func (m *InvoiceV2Model) insertTaxLinesTx(invoiceID int, tx *sql.Tx) error {
+	stmt := `
+	   insert into invoice_tax_lines (
+	          invoice_id, tax_id, tax_rate, financial_account_id, gross
+	   )
+	   select a.invoice_id,
+	          c.tax_id,
+	          c.tax_rate,
+	          p.financial_account_id,
+	          sum(c.total_price)
+	     from activities a
+	     join consumptions c
+	       on c.activity_id = a.id
+	     join products p
+	       on p.id = c.product_id
+	    where a.invoice_id = $1
+	      and p.financial_account_id is not null
+	 group by a.invoice_id, c.tax_id, c.tax_rate, p.financial_account_id;
+	`
+	if _, err := tx.Exec(stmt, invoiceID); err != nil {
+		return fmt.Errorf("failed inserting tax lines: %v", err)
+	}
+	return nil
+}
+
+// ReleaseActivitiesTx unassigns all activities of the invoice, so that they
+// are invoiced again with the next invoice.
+func (m *InvoiceV2Model) ReleaseActivitiesTx(invoiceID int, tx *sql.Tx) (int, error) {
+	stmt := `
+	update activities
+	   set invoice_id = null,
+	       updated_at = now()
+	 where invoice_id = $1;
+	`
+
+	result, err := tx.Exec(stmt, invoiceID)
+	if err != nil {
+		return 0, fmt.Errorf("failed releasing activities: %v", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
+// InvoiceNumber formats the number of an invoice, e.g. BV-2026-0042.
+func InvoiceNumber(year, n int) string {
+	return documentNumber(InvoiceNumberPrefix, year, n)
+}
+
+func documentNumber(prefix string, year, n int) string {
+	return fmt.Sprintf("%s-%d-%04d", prefix, year, n)
+}
+
+// nextNumberTx returns the next gapless number of the current year for
+// prefix. The row lock on the sequence serializes concurrent transactions
+// until commit, a rollback gives the number back.
+func nextNumberTx(prefix string, tx *sql.Tx) (string, error) {
+	stmt := `
+	insert into invoice_number_sequences (
+		prefix, year, last_value
+	) values (
+		$1, extract(year from current_date)::int, 1
+	)
+	on conflict (prefix, year) do update
+	   set last_value = invoice_number_sequences.last_value + 1
+	returning year, last_value;
+	`
+	var year, n int
+	if err := tx.QueryRow(stmt, prefix).Scan(&year, &n); err != nil {
+		return "", fmt.Errorf("failed incrementing number sequence %s: %v", prefix, err)
+	}
+
+	return documentNumber(prefix, year, n), nil
+}
+
+// AssignNumber gives the invoice its number in its own transaction, see
+// AssignNumberTx.
+func (m *InvoiceV2Model) AssignNumber(invoiceID int) (string, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	number, err := m.AssignNumberTx(invoiceID, tx)
+	if err != nil {
+		return "", err
+	}
+
+	return number, tx.Commit()
+}
+
+// AssignNumberTx gives the invoice the next gapless number of the current
+// year. An invoice keeps its number once it has one, so calling it again
+// returns the same number. SetStatusTx calls it when the invoice leaves
+// draft, in the transaction that sends it, so a draft never has a number and
+// a failed email gives the number back.
+func (m *InvoiceV2Model) AssignNumberTx(invoiceID int, tx *sql.Tx) (string, error) {
+	var number sql.NullString
+	row := tx.QueryRow(`select number from invoices_v2 where id = $1 for update;`, invoiceID)
+	if err := row.Scan(&number); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNoRecord
+		}
+		return "", fmt.Errorf("failed reading invoice number: %v", err)
+	}
+	if number.Valid {
+		return number.String, nil
+	}
+
+	next, err := nextNumberTx(InvoiceNumberPrefix, tx)
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `
+	update invoices_v2
+	   set number = $2,
+	       updated_at = now()
+	 where id = $1;
+	`
+	if _, err := tx.Exec(stmt, invoiceID, next); err != nil {
+		return "", fmt.Errorf("failed updating invoice number: %v", err)
+	}
+
+	return next, nil
+}
 
 func (m *InvoiceV2Model) NewInvoiceTx(userID int, tx *sql.Tx) (InvoiceV2, error) {
 	stmt := `
@@ -27,7 +374,7 @@ func (m *InvoiceV2Model) NewInvoiceTx(userID int, tx *sql.Tx) (InvoiceV2, error)
 	values (
 		$1
 	)
-	returning id, status;
+	returning id, status, reference;
 	`
 
 	row := tx.QueryRow(stmt, userID)
@@ -36,7 +383,7 @@ func (m *InvoiceV2Model) NewInvoiceTx(userID int, tx *sql.Tx) (InvoiceV2, error)
 		UserID: userID,
 	}
 
-	err := row.Scan(&newInvoice.ID, &newInvoice.Status)
+	err := row.Scan(&newInvoice.ID, &newInvoice.Status, &newInvoice.Reference)
 	if err != nil {
 		return InvoiceV2{}, err
 	}
@@ -44,7 +391,10 @@ func (m *InvoiceV2Model) NewInvoiceTx(userID int, tx *sql.Tx) (InvoiceV2, error)
 	return newInvoice, nil
 }
 
-// NOTE: there is an idea there to assign activites of other users to an invoice, too
+// AssignOpenActivitiesByMonthToInvoiceForUserTx assigns the open activities
+// of the month to the invoice. Like the other AssignOpenActivities functions,
+// it includes the activities of the members of the household the user is the
+// billing contact of, see billedUsers.
 func (m *InvoiceV2Model) AssignOpenActivitiesByMonthToInvoiceForUserTx(
 	month time.Time,
 	userID int,
@@ -56,7 +406,7 @@ func (m *InvoiceV2Model) AssignOpenActivitiesByMonthToInvoiceForUserTx(
 	stmt := `
 	update activities
 	   set invoice_id = $1
-	 where user_id = $2
+	 where user_id in (` + billedUsers + `)
 	   AND date >= date_trunc('month', $3::date)
 	   AND date <  date_trunc('month', $3::date) + interval '1 month'
 	   and invoice_id is null;
@@ -87,7 +437,7 @@ func (m *InvoiceV2Model) AssignOpenActivitiesByRangeToInvoiceForUserTx(
 	stmt := `
 	update activities
 	   set invoice_id = $1
-	 where user_id = $2
+	 where user_id in (` + billedUsers + `)
 	   AND date >= date_trunc('month', $3::date)
 	   AND date <  date_trunc('month', $4::date)
 	   and invoice_id is null;
@@ -117,7 +467,7 @@ func (m *InvoiceV2Model) AssignOpenActivitiesBeforeDateToInvoiceForUserTx(
 	stmt := `
 	update activities
 	   set invoice_id = $1
-	 where user_id = $2
+	 where user_id in (` + billedUsers + `)
 	   AND date <  date_trunc('month', $3::date)
 	   and invoice_id is null;
 	`
@@ -145,7 +495,7 @@ func (m *InvoiceV2Model) AssignOpenActivitiesBeforeCurrentMonthForUserTx(
 	stmt := `
 	update activities
 	   set invoice_id = $1
-	 where user_id = $2
+	 where user_id in (` + billedUsers + `)
 	   and date < date_trunc('month', current_date)::date
 	   and invoice_id is null;
 	`
@@ -169,7 +519,7 @@ func (m *InvoiceV2Model) AssignAllOpenActivitiesToInvoiceTx(userID, invoceID int
 	stmt := `
 	update activities
 	   set invoice_id = $1
-	 where user_id = $2
+	 where user_id in (` + billedUsers + `)
 	   and invoice_id is null;
 	`
 
@@ -186,6 +536,73 @@ func (m *InvoiceV2Model) AssignAllOpenActivitiesToInvoiceTx(userID, invoceID int
 	return int(n), nil
 }
 
+// OpenInvoice is a sent invoice that waits for its payment.
+type OpenInvoice struct {
+	ID         int
+	UserID     int
+	Number     string
+	Reference  string
+	FirstName  string
+	LastName   string
+	TotalPrice int
+	CreatedAt  time.Time
+}
+
+// GetOpen returns all sent but not yet paid invoices, oldest first.
+func (m *InvoiceV2Model) GetOpen() ([]OpenInvoice, error) {
+	stmt := `
+	   select i.id,
+	          i.user_id,
+	          coalesce(i.number, ''),
+	          i.reference,
+	          u.first_name,
+	          u.last_name,
+	          coalesce(sum(c.total_price), 0),
+	          i.created_at
+	     from invoices_v2 i
+	     join users u
+	       on u.id = i.user_id
+	left join activities a
+	       on a.invoice_id = i.id
+	left join consumptions c
+	       on c.activity_id = a.id
+	    where i.status = 'sent'
+	 group by i.id, u.id
+	 order by i.created_at;
+	`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, fmt.Errorf("DB.Query(stmt): %v", err)
+	}
+	defer rows.Close()
+
+	var invoices []OpenInvoice
+	for rows.Next() {
+		var i OpenInvoice
+		err = rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Number,
+			&i.Reference,
+			&i.FirstName,
+			&i.LastName,
+			&i.TotalPrice,
+			&i.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("for rows.Next(): %v", err)
+		}
+		invoices = append(invoices, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %v", err)
+	}
+
+	return invoices, nil
+}
+
 type InvoiceFinAccSum struct {
 	FinancialAccountName string
 	Price                int
@@ -0,0 +1,42 @@
+package models
+
+import "testing"
+
+func TestCanTransitionInvoice(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{InvoiceStatusDraft, InvoiceStatusSent, true},
+		{InvoiceStatusDraft, InvoiceStatusCancelled, true},
+		{InvoiceStatusDraft, InvoiceStatusPaid, false},
+		{InvoiceStatusSent, InvoiceStatusPaid, true},
+		{InvoiceStatusSent, InvoiceStatusCancelled, true},
+		{InvoiceStatusSent, InvoiceStatusDraft, false},
+		{InvoiceStatusPaid, InvoiceStatusCancelled, false},
+		{InvoiceStatusCancelled, InvoiceStatusSent, false},
+		{InvoiceStatusDraft, "unknown", false},
+	}
+
+	for _, tt := range tests {
+		if got := CanTransitionInvoice(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransitionInvoice(%s, %s): expected %v, got %v", tt.from, tt.to, tt.want, got)
+		}
+	}
+}
+
+func TestInvoiceNumber(t *testing.T) {
+	for want, n := range map[string]int{
+		"BV-2026-0001":  1,
+		"BV-2026-0042":  42,
+		"BV-2026-12345": 12345,
+	} {
+		if got := InvoiceNumber(2026, n); got != want {
+			t.Errorf("InvoiceNumber(2026, %d): expected %s, got %s", n, want, got)
+		}
+	}
+
+	if got := documentNumber(CreditNoteNumberPrefix, 2026, 7); got != "GS-2026-0007" {
+		t.Errorf("expected credit note number GS-2026-0007, got %s", got)
+	}
+}
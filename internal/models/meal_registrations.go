@@ -0,0 +1,258 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Meals are the meals members can register for, by the code of their
+// product, in the order of the day.
+var Meals = []string{"breakfast", "lunch", "dinner"}
+
+var ErrInvalidMeal = errors.New("models: invalid meal")
+
+// MealRegistration is a member signed up for a meal, so the kitchen knows how
+// many to cook.
+type MealRegistration struct {
+	ID            int
+	UserID        int
+	Member        string // first and last name of the user
+	Date          time.Time
+	Meal          string // one of Meals
+	PriceCategory string
+	Notes         string // dietary notes, e.g. vegan
+	Converted     bool   // the registration became an activity
+}
+
+type MealRegistrations []MealRegistration
+
+// Pending counts the registrations that did not become activities yet.
+func (regs MealRegistrations) Pending() int {
+	n := 0
+	for _, reg := range regs {
+		if !reg.Converted {
+			n++
+		}
+	}
+	return n
+}
+
+// MealHeadcount is how many registered for a meal, in total and by price
+// category.
+type MealHeadcount struct {
+	Meal            string
+	Total           int
+	PriceCategories []PriceCategoryCount
+}
+
+type PriceCategoryCount struct {
+	Name  string
+	Count int
+}
+
+// Headcounts counts the registrations by meal, in the order of Meals, and by
+// price category. Meals without registrations are left out.
+func (regs MealRegistrations) Headcounts() []MealHeadcount {
+	var headcounts []MealHeadcount
+	for _, meal := range Meals {
+		hc := MealHeadcount{Meal: meal}
+		for _, reg := range regs {
+			if reg.Meal != meal {
+				continue
+			}
+			hc.Total++
+			i := slices.IndexFunc(hc.PriceCategories, func(c PriceCategoryCount) bool {
+				return c.Name == reg.PriceCategory
+			})
+			if i < 0 {
+				hc.PriceCategories = append(hc.PriceCategories, PriceCategoryCount{Name: reg.PriceCategory})
+				i = len(hc.PriceCategories) - 1
+			}
+			hc.PriceCategories[i].Count++
+		}
+		if hc.Total == 0 {
+			continue
+		}
+		slices.SortFunc(hc.PriceCategories, func(a, b PriceCategoryCount) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+		headcounts = append(headcounts, hc)
+	}
+	return headcounts
+}
+
+type MealRegistrationModel struct {
+	DB *sql.DB
+}
+
+// mealOrder sorts the meals in the order of the day.
+const mealOrder = `
+	case r.meal
+	     when 'breakfast' then 1
+	     when 'lunch' then 2
+	     else 3
+	end
+`
+
+// GetUpcomingForUser returns the registrations of the user from the day from
+// on, by date and meal.
+func (m *MealRegistrationModel) GetUpcomingForUser(userID int, from time.Time) (MealRegistrations, error) {
+	stmt := `
+	   select r.id,
+	          r.user_id,
+	          u.first_name || ' ' || u.last_name,
+	          r.date,
+	          r.meal,
+	          pc.name,
+	          coalesce(r.notes, ''),
+	          r.converted_at is not null
+	     from meal_registrations r
+	     join users u
+	       on u.id = r.user_id
+	     join price_categories pc
+	       on pc.id = r.price_category_id
+	    where r.user_id = $1
+	      and r.date >= $2
+	 order by r.date, ` + mealOrder
+
+	return m.getMultiple(stmt, userID, from)
+}
+
+// GetByDate returns the registrations of all members for date, by meal and
+// member.
+func (m *MealRegistrationModel) GetByDate(date time.Time) (MealRegistrations, error) {
+	stmt := `
+	   select r.id,
+	          r.user_id,
+	          u.first_name || ' ' || u.last_name,
+	          r.date,
+	          r.meal,
+	          pc.name,
+	          coalesce(r.notes, ''),
+	          r.converted_at is not null
+	     from meal_registrations r
+	     join users u
+	       on u.id = r.user_id
+	     join price_categories pc
+	       on pc.id = r.price_category_id
+	    where r.date = $1
+	 order by ` + mealOrder + `, u.first_name, u.last_name`
+
+	return m.getMultiple(stmt, date)
+}
+
+func (m *MealRegistrationModel) getMultiple(stmt string, args ...any) (MealRegistrations, error) {
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("DB.Query(stmt): %v", err)
+	}
+	defer rows.Close()
+
+	var regs MealRegistrations
+	for rows.Next() {
+		var r MealRegistration
+		err = rows.Scan(
+			&r.ID,
+			&r.UserID,
+			&r.Member,
+			&r.Date,
+			&r.Meal,
+			&r.PriceCategory,
+			&r.Notes,
+			&r.Converted,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("for rows.Next(): %v", err)
+		}
+		regs = append(regs, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %v", err)
+	}
+
+	return regs, nil
+}
+
+// Register signs the user up for the meals of date, else ErrInvalidMeal. A
+// registration for the same meal is updated, unless it was converted
+// already.
+func (m *MealRegistrationModel) Register(userID int, date time.Time, meals []string, priceCategory, notes string) error {
+	for _, meal := range meals {
+		if !slices.Contains(Meals, meal) {
+			return ErrInvalidMeal
+		}
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, meal := range meals {
+		stmt := `
+		insert into meal_registrations (user_id, date, meal, price_category_id, notes)
+		select $1, $2, $3, id, nullif($5, '')
+		  from price_categories
+		 where name = $4
+		on conflict (user_id, date, meal) do update
+		   set price_category_id = excluded.price_category_id,
+		       notes = excluded.notes,
+		       updated_at = now()
+		 where meal_registrations.converted_at is null
+		`
+		_, err = tx.Exec(stmt, userID, date, meal, priceCategory, notes)
+		if err != nil {
+			return fmt.Errorf("failed registering userID=%d for %s: %v", userID, meal, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Cancel deletes the registration of the user, else ErrNoRecord, also if it
+// was converted already.
+func (m *MealRegistrationModel) Cancel(userID, registrationID int) error {
+	stmt := `
+	delete from meal_registrations
+	 where id = $1
+	   and user_id = $2
+	   and converted_at is null
+	`
+	result, err := m.DB.Exec(stmt, registrationID, userID)
+	if err != nil {
+		return fmt.Errorf("failed cancelling meal registration %d: %v", registrationID, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
+
+// ConvertTx marks the registrations of the user on date as converted into the
+// activity.
+func (m *MealRegistrationModel) ConvertTx(userID int, date time.Time, activityID int, tx *sql.Tx) error {
+	stmt := `
+	update meal_registrations
+	   set converted_at = now(),
+	       activity_id = $3,
+	       updated_at = now()
+	 where user_id = $1
+	   and date = $2
+	   and converted_at is null
+	`
+	_, err := tx.Exec(stmt, userID, date, activityID)
+	if err != nil {
+		return fmt.Errorf("failed converting meal registrations of userID=%d: %v", userID, err)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMealRegistrationsHeadcounts(t *testing.T) {
+	regs := MealRegistrations{
+		{Meal: "dinner", PriceCategory: "regular"},
+		{Meal: "lunch", PriceCategory: "regular"},
+		{Meal: "lunch", PriceCategory: "reduced"},
+		{Meal: "lunch", PriceCategory: "regular"},
+	}
+
+	want := []MealHeadcount{
+		{Meal: "lunch", Total: 3, PriceCategories: []PriceCategoryCount{{"reduced", 1}, {"regular", 2}}},
+		{Meal: "dinner", Total: 1, PriceCategories: []PriceCategoryCount{{"regular", 1}}},
+	}
+
+	if got := regs.Headcounts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Headcounts() = %+v, want %+v", got, want)
+	}
+
+	if got := (MealRegistrations{}).Headcounts(); got != nil {
+		t.Errorf("Headcounts() of none = %+v, want nil", got)
+	}
+}
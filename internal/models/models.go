@@ -3,25 +3,43 @@ package models
 import "database/sql"
 
 type Models struct {
-	Users           UserModel
-	Invoices        InvoiceModel
-	InvoicesV2      InvoiceV2Model
-	Products        ProductModel
-	PriceCategories PriceCategoryModel
-	Consumptions    ConsumptionModel
-	Comments        CommentModel
-	Activities      ActivityModel
+	Users             UserModel
+	Invoices          InvoiceModel
+	InvoicesV2        InvoiceV2Model
+	Products          ProductModel
+	PriceCategories   PriceCategoryModel
+	Consumptions      ConsumptionModel
+	Comments          CommentModel
+	Activities        ActivityModel
+	BankTransactions  BankTransactionModel
+	CreditNotes       CreditNoteModel
+	Payments          PaymentModel
+	Reminders         ReminderModel
+	InvoiceRuns       InvoiceRunModel
+	Outbox            OutboxModel
+	Households        HouseholdModel
+	MealRegistrations MealRegistrationModel
+	ActivityTemplates ActivityTemplateModel
 }
 
 func New(db *sql.DB) Models {
 	return Models{
-		Users:           UserModel{DB: db},
-		Invoices:        InvoiceModel{DB: db},
-		InvoicesV2:      InvoiceV2Model{DB: db},
-		Products:        ProductModel{DB: db},
-		PriceCategories: PriceCategoryModel{DB: db},
-		Consumptions:    ConsumptionModel{DB: db},
-		Comments:        CommentModel{DB: db},
-		Activities:      ActivityModel{DB: db},
+		Users:             UserModel{DB: db},
+		Invoices:          InvoiceModel{DB: db},
+		InvoicesV2:        InvoiceV2Model{DB: db},
+		Products:          ProductModel{DB: db},
+		PriceCategories:   PriceCategoryModel{DB: db},
+		Consumptions:      ConsumptionModel{DB: db},
+		Comments:          CommentModel{DB: db},
+		Activities:        ActivityModel{DB: db},
+		BankTransactions:  BankTransactionModel{DB: db},
+		CreditNotes:       CreditNoteModel{DB: db},
+		Payments:          PaymentModel{DB: db},
+		Reminders:         ReminderModel{DB: db},
+		InvoiceRuns:       InvoiceRunModel{DB: db},
+		Outbox:            OutboxModel{DB: db},
+		Households:        HouseholdModel{DB: db},
+		MealRegistrations: MealRegistrationModel{DB: db},
+		ActivityTemplates: ActivityTemplateModel{DB: db},
 	}
 }
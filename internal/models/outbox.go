@@ -0,0 +1,267 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type OutboxModel struct {
+	DB *sql.DB
+}
+
+// kinds of outbox messages:
+const (
+	OutboxKindInvoice      = "invoice"
+	OutboxKindCancellation = "cancellation"
+	OutboxKindReminder     = "reminder"
+)
+
+// status of outbox messages:
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	OutboxFailed  = "failed"
+)
+
+// OutboxMaxAttempts is how often a message is tried before it is failed and
+// needs to be resent by an admin. With OutboxBackoff this is about a day.
+const OutboxMaxAttempts = 10
+
+type OutboxMessage struct {
+	ID            int
+	Kind          string
+	Recipient     string
+	Subject       string
+	Body          []byte
+	InvoiceID     sql.NullInt32
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+	SentAt        sql.NullTime
+}
+
+// OutboxBackoff is the delay after the given number of failed attempts:
+// 1 minute, 2, 4, ... up to 6 hours.
+func OutboxBackoff(attempts int) time.Duration {
+	const max = 6 * time.Hour
+	if attempts < 1 {
+		return 0
+	}
+	if attempts > 10 {
+		return max
+	}
+	return min(time.Minute<<(attempts-1), max)
+}
+
+// EnqueueTx writes msg to the outbox in the transaction of what it is about.
+// The worker delivers it after the commit.
+func (m *OutboxModel) EnqueueTx(msg OutboxMessage, tx *sql.Tx) (int, error) {
+	stmt := `
+	insert into email_outbox (kind, recipient, subject, body, invoice_id)
+	values ($1, $2, $3, $4, $5)
+	returning id;
+	`
+
+	var id int
+	err := tx.QueryRow(stmt, msg.Kind, msg.Recipient, msg.Subject, msg.Body, msg.InvoiceID).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed inserting outbox message: %v", err)
+	}
+	return id, nil
+}
+
+// GetDue returns up to limit pending messages whose next attempt is due,
+// oldest first.
+func (m *OutboxModel) GetDue(limit int) ([]OutboxMessage, error) {
+	stmt := `
+	  select id, kind, recipient, subject, body, invoice_id, status,
+	         attempts, next_attempt_at, coalesce(last_error, ''),
+	         created_at, sent_at
+	    from email_outbox
+	   where status = 'pending'
+	     and next_attempt_at <= now()
+	order by id
+	   limit $1;
+	`
+
+	return m.getMultiple(stmt, limit)
+}
+
+// GetByStatus returns the latest limit messages with status.
+func (m *OutboxModel) GetByStatus(status string, limit int) ([]OutboxMessage, error) {
+	stmt := `
+	  select id, kind, recipient, subject, ''::bytea, invoice_id, status,
+	         attempts, next_attempt_at, coalesce(last_error, ''),
+	         created_at, sent_at
+	    from email_outbox
+	   where status = $1
+	order by id desc
+	   limit $2;
+	`
+
+	return m.getMultiple(stmt, status, limit)
+}
+
+func (m *OutboxModel) getMultiple(stmt string, args ...any) ([]OutboxMessage, error) {
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("DB.Query(stmt): %v", err)
+	}
+	defer rows.Close()
+
+	var messages []OutboxMessage
+	for rows.Next() {
+		var msg OutboxMessage
+		err = rows.Scan(
+			&msg.ID,
+			&msg.Kind,
+			&msg.Recipient,
+			&msg.Subject,
+			&msg.Body,
+			&msg.InvoiceID,
+			&msg.Status,
+			&msg.Attempts,
+			&msg.NextAttemptAt,
+			&msg.LastError,
+			&msg.CreatedAt,
+			&msg.SentAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("for rows.Next(): %v", err)
+		}
+		messages = append(messages, msg)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %v", err)
+	}
+
+	return messages, nil
+}
+
+// SetContentTx stores the message that was rendered at delivery, e.g. an
+// invoice email, which shows the number the invoice got in tx.
+func (m *OutboxModel) SetContentTx(id int, subject string, body []byte, tx *sql.Tx) error {
+	stmt := `
+	update email_outbox
+	   set subject = $2,
+	       body = $3
+	 where id = $1;
+	`
+	if _, err := tx.Exec(stmt, id, subject, body); err != nil {
+		return fmt.Errorf("failed setting content of outbox message %d: %v", id, err)
+	}
+	return nil
+}
+
+// MarkSentTx records the successful delivery. It runs in one transaction with
+// whatever the delivery changes, e.g. the status of the invoice.
+func (m *OutboxModel) MarkSentTx(id int, tx *sql.Tx) error {
+	stmt := `
+	update email_outbox
+	   set status = 'sent',
+	       attempts = attempts + 1,
+	       last_error = null,
+	       sent_at = now()
+	 where id = $1;
+	`
+	if _, err := tx.Exec(stmt, id); err != nil {
+		return fmt.Errorf("failed marking outbox message %d as sent: %v", id, err)
+	}
+
+	stmt = `
+	insert into email_delivery_attempts (message_id)
+	values ($1);
+	`
+	if _, err := tx.Exec(stmt, id); err != nil {
+		return fmt.Errorf("failed inserting delivery attempt: %v", err)
+	}
+
+	return nil
+}
+
+// MarkAttemptFailed records the failed attempt and schedules the next one,
+// see OutboxBackoff. After OutboxMaxAttempts the message is failed.
+func (m *OutboxModel) MarkAttemptFailed(id int, cause error) error {
+	return m.markAttemptFailed(id, cause, false)
+}
+
+// MarkFailed records the failed attempt and fails the message right away,
+// e.g. the invoice email of an invoice that was cancelled meanwhile.
+func (m *OutboxModel) MarkFailed(id int, cause error) error {
+	return m.markAttemptFailed(id, cause, true)
+}
+
+func (m *OutboxModel) markAttemptFailed(id int, cause error, final bool) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var attempts int
+	err = tx.QueryRow(`select attempts + 1 from email_outbox where id = $1 for update;`, id).Scan(&attempts)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return fmt.Errorf("DB.QueryRow(): %v", err)
+	}
+
+	status := OutboxPending
+	if final || attempts >= OutboxMaxAttempts {
+		status = OutboxFailed
+	}
+
+	stmt := `
+	update email_outbox
+	   set status = $2,
+	       attempts = $3,
+	       last_error = $4,
+	       next_attempt_at = now() + $5 * interval '1 second'
+	 where id = $1;
+	`
+	_, err = tx.Exec(stmt, id, status, attempts, cause.Error(), int(OutboxBackoff(attempts).Seconds()))
+	if err != nil {
+		return fmt.Errorf("failed updating outbox message %d: %v", id, err)
+	}
+
+	stmt = `
+	insert into email_delivery_attempts (message_id, error)
+	values ($1, $2);
+	`
+	if _, err := tx.Exec(stmt, id, cause.Error()); err != nil {
+		return fmt.Errorf("failed inserting delivery attempt: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// Resend puts a failed message back into the queue with a fresh backoff.
+func (m *OutboxModel) Resend(id int) error {
+	stmt := `
+	update email_outbox
+	   set status = 'pending',
+	       attempts = 0,
+	       next_attempt_at = now()
+	 where id = $1
+	   and status = 'failed';
+	`
+
+	result, err := m.DB.Exec(stmt, id)
+	if err != nil {
+		return fmt.Errorf("failed resending outbox message %d: %v", id, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOutboxBackoff(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{
+		0:   0,
+		1:   time.Minute,
+		2:   2 * time.Minute,
+		5:   16 * time.Minute,
+		9:   256 * time.Minute,
+		10:  6 * time.Hour,
+		100: 6 * time.Hour,
+	} {
+		if got := OutboxBackoff(attempts); got != want {
+			t.Errorf("OutboxBackoff(%d): expected %v, got %v", attempts, want, got)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type PaymentModel struct {
+	DB *sql.DB
+}
+
+const (
+	PaymentMethodBank   = "bank"
+	PaymentMethodManual = "manual"
+)
+
+type Payment struct {
+	ID                int
+	UserID            int
+	Amount            int
+	BookingDate       time.Time
+	Method            string
+	BankTransactionID sql.NullInt32
+	CreatedBy         sql.NullInt32
+	CreatedAt         time.Time
+}
+
+type PaymentAllocation struct {
+	PaymentID int
+	InvoiceID int
+	Amount    int
+}
+
+// Balance of a member over all issued invoices and all payments.
+type Balance struct {
+	Invoiced int // sent and paid invoices
+	Paid     int
+}
+
+// Open is what the member owes, negative if they have credit.
+func (b Balance) Open() int {
+	return b.Invoiced - b.Paid
+}
+
+// Due is the open amount, 0 if the member has credit.
+func (b Balance) Due() int {
+	return max(b.Open(), 0)
+}
+
+// Credit is the amount the member paid in advance, 0 if they owe something.
+func (b Balance) Credit() int {
+	return max(-b.Open(), 0)
+}
+
+// InsertTx records a payment. p.CreatedBy is the admin who recorded it,
+// invalid for the system.
+func (m *PaymentModel) InsertTx(p Payment, tx *sql.Tx) (int, error) {
+	stmt := `
+	insert into payments (
+		user_id, amount, booking_date, method, bank_transaction_id, created_by
+	) values (
+		$1,      $2,     $3,           $4,     $5,                  $6
+	)
+	returning id;
+	`
+
+	if p.BookingDate.IsZero() {
+		p.BookingDate = time.Now()
+	}
+
+	var id int
+	err := tx.QueryRow(
+		stmt,
+		p.UserID,
+		p.Amount,
+		p.BookingDate,
+		p.Method,
+		p.BankTransactionID,
+		p.CreatedBy,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed inserting payment: %v", err)
+	}
+
+	return id, nil
+}
+
+// Allocate allocates the payments of the user in its own transaction, see
+// AllocateTx.
+func (m *PaymentModel) Allocate(userID int) ([]int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	paid, err := m.AllocateTx(userID, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return paid, tx.Commit()
+}
+
+// AllocateTx distributes what is left of the payments of the user over their
+// sent invoices, oldest first. Invoices that are paid in full are marked as
+// paid, their IDs are returned. Whatever is left stays as credit and is
+// allocated when the next invoice is sent.
+func (m *PaymentModel) AllocateTx(userID int, tx *sql.Tx) ([]int, error) {
+	// one allocation per user at a time.
+	if _, err := tx.Exec(`select id from users where id = $1 for update;`, userID); err != nil {
+		return nil, fmt.Errorf("failed locking user: %v", err)
+	}
+
+	payments, err := m.openAmounts(tx, `
+	   select p.id,
+	          p.amount - coalesce(sum(pa.amount), 0)
+	     from payments p
+	left join payment_allocations pa
+	       on pa.payment_id = p.id
+	    where p.user_id = $1
+	 group by p.id
+	   having p.amount - coalesce(sum(pa.amount), 0) > 0
+	 order by p.booking_date, p.id;
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading open payments: %v", err)
+	}
+
+	open, err := m.openAmounts(tx, `
+	   select i.id,
+	          (select coalesce(sum(c.total_price), 0)
+	             from activities a
+	             join consumptions c
+	               on c.activity_id = a.id
+	            where a.invoice_id = i.id)
+	        - (select coalesce(sum(pa.amount), 0)
+	             from payment_allocations pa
+	            where pa.invoice_id = i.id)
+	     from invoices_v2 i
+	    where i.user_id = $1
+	      and i.status = 'sent'
+	 order by i.sent_at, i.id;
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading open invoices: %v", err)
+	}
+
+	allocations, paid := allocate(payments, open)
+
+	stmt := `
+	insert into payment_allocations (
+		payment_id, invoice_id, amount
+	) values (
+		$1,         $2,         $3
+	);
+	`
+	for _, a := range allocations {
+		if _, err := tx.Exec(stmt, a.PaymentID, a.InvoiceID, a.Amount); err != nil {
+			return nil, fmt.Errorf("failed inserting payment allocation: %v", err)
+		}
+	}
+
+	invoices := &InvoiceV2Model{DB: m.DB}
+	for _, invoiceID := range paid {
+		if err := invoices.SetStatusTx(invoiceID, InvoiceStatusPaid, 0, tx); err != nil {
+			return nil, fmt.Errorf("could not mark invoice %d as paid: %v", invoiceID, err)
+		}
+	}
+
+	return paid, nil
+}
+
+// PayInvoiceTx records a manual payment over what is still open on the
+// invoice, allocates it to this invoice and marks it as paid. createdBy is
+// the admin who received the money.
+func (m *PaymentModel) PayInvoiceTx(invoiceID, createdBy int, tx *sql.Tx) error {
+	stmt := `
+	select i.user_id,
+	       (select coalesce(sum(c.total_price), 0)
+	          from activities a
+	          join consumptions c
+	            on c.activity_id = a.id
+	         where a.invoice_id = i.id)
+	     - (select coalesce(sum(pa.amount), 0)
+	          from payment_allocations pa
+	         where pa.invoice_id = i.id)
+	  from invoices_v2 i
+	 where i.id = $1;
+	`
+
+	var userID, due int
+	if err := tx.QueryRow(stmt, invoiceID).Scan(&userID, &due); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return fmt.Errorf("failed reading open amount of invoice: %v", err)
+	}
+
+	if due > 0 {
+		paymentID, err := m.InsertTx(Payment{
+			UserID:    userID,
+			Amount:    due,
+			Method:    PaymentMethodManual,
+			CreatedBy: sql.NullInt32{Int32: int32(createdBy), Valid: createdBy != 0},
+		}, tx)
+		if err != nil {
+			return err
+		}
+
+		stmt = `
+		insert into payment_allocations (
+			payment_id, invoice_id, amount
+		) values (
+			$1,         $2,         $3
+		);
+		`
+		if _, err := tx.Exec(stmt, paymentID, invoiceID, due); err != nil {
+			return fmt.Errorf("failed inserting payment allocation: %v", err)
+		}
+	}
+
+	invoices := &InvoiceV2Model{DB: m.DB}
+	return invoices.SetStatusTx(invoiceID, InvoiceStatusPaid, createdBy, tx)
+}
+
+// BalanceForUser sums up the issued invoices and the payments of the user.
+// Drafts and cancelled invoices are not part of the balance.
+func (m *PaymentModel) BalanceForUser(userID int) (Balance, error) {
+	stmt := `
+	select (select coalesce(sum(c.total_price), 0)
+	          from invoices_v2 i
+	          join activities a
+	            on a.invoice_id = i.id
+	          join consumptions c
+	            on c.activity_id = a.id
+	         where i.user_id = $1
+	           and i.status in ('sent', 'paid')),
+	       (select coalesce(sum(p.amount), 0)
+	          from payments p
+	         where p.user_id = $1);
+	`
+
+	var b Balance
+	if err := m.DB.QueryRow(stmt, userID).Scan(&b.Invoiced, &b.Paid); err != nil {
+		return Balance{}, fmt.Errorf("DB.QueryRow(): %v", err)
+	}
+
+	return b, nil
+}
+
+// CreditForUser returns what is left of the payments of the user after
+// allocation. It is deducted from the next invoice.
+func (m *PaymentModel) CreditForUser(userID int) (int, error) {
+	stmt := `
+	select coalesce(sum(p.amount), 0)
+	     - coalesce((select sum(pa.amount)
+	                   from payment_allocations pa
+	                   join payments p2
+	                     on p2.id = pa.payment_id
+	                  where p2.user_id = $1), 0)
+	  from payments p
+	 where p.user_id = $1;
+	`
+
+	var credit int
+	if err := m.DB.QueryRow(stmt, userID).Scan(&credit); err != nil {
+		return 0, fmt.Errorf("DB.QueryRow(): %v", err)
+	}
+
+	return credit, nil
+}
+
+type openAmount struct {
+	id     int
+	amount int
+}
+
+func (m *PaymentModel) openAmounts(tx *sql.Tx, stmt string, userID int) ([]openAmount, error) {
+	rows, err := tx.Query(stmt, userID)
+	if err != nil {
+		return nil, fmt.Errorf("tx.Query(stmt): %v", err)
+	}
+	defer rows.Close()
+
+	var amounts []openAmount
+	for rows.Next() {
+		var a openAmount
+		if err := rows.Scan(&a.id, &a.amount); err != nil {
+			return nil, fmt.Errorf("for rows.Next(): %v", err)
+		}
+		amounts = append(amounts, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %v", err)
+	}
+
+	return amounts, nil
+}
+
+// allocate pays the invoices in order with the payments in order. It
+// returns the allocations and the invoices that are paid in full, including
+// invoices with nothing left to pay.
+func allocate(payments, invoices []openAmount) ([]PaymentAllocation, []int) {
+	var allocations []PaymentAllocation
+	var paid []int
+
+	p := 0
+	for _, invoice := range invoices {
+		due := invoice.amount
+		for due > 0 && p < len(payments) {
+			amount := min(due, payments[p].amount)
+			allocations = append(allocations, PaymentAllocation{
+				PaymentID: payments[p].id,
+				InvoiceID: invoice.id,
+				Amount:    amount,
+			})
+			due -= amount
+			payments[p].amount -= amount
+			if payments[p].amount == 0 {
+				p++
+			}
+		}
+		if due <= 0 {
+			paid = append(paid, invoice.id)
+		}
+	}
+
+	return allocations, paid
+}
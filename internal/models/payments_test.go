@@ -0,0 +1,87 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name        string
+		payments    []openAmount
+		invoices    []openAmount
+		allocations []PaymentAllocation
+		paid        []int
+	}{
+		{
+			name:        "exact",
+			payments:    []openAmount{{1, 4850}},
+			invoices:    []openAmount{{10, 4850}},
+			allocations: []PaymentAllocation{{1, 10, 4850}},
+			paid:        []int{10},
+		},
+		{
+			name:        "partial",
+			payments:    []openAmount{{1, 2000}},
+			invoices:    []openAmount{{10, 4850}},
+			allocations: []PaymentAllocation{{1, 10, 2000}},
+		},
+		{
+			name:        "two invoices in one transfer",
+			payments:    []openAmount{{1, 7000}},
+			invoices:    []openAmount{{10, 4000}, {11, 3000}},
+			allocations: []PaymentAllocation{{1, 10, 4000}, {1, 11, 3000}},
+			paid:        []int{10, 11},
+		},
+		{
+			name:        "overpayment stays as credit",
+			payments:    []openAmount{{1, 5000}},
+			invoices:    []openAmount{{10, 4850}},
+			allocations: []PaymentAllocation{{1, 10, 4850}},
+			paid:        []int{10},
+		},
+		{
+			name:        "credit and new payment",
+			payments:    []openAmount{{1, 150}, {2, 3000}},
+			invoices:    []openAmount{{11, 3150}},
+			allocations: []PaymentAllocation{{1, 11, 150}, {2, 11, 3000}},
+			paid:        []int{11},
+		},
+		{
+			// the allocation to the cancelled invoice 10 was released, the
+			// payment pays the invoice that replaces it.
+			name:        "payment released by a cancelled invoice",
+			payments:    []openAmount{{1, 4850}},
+			invoices:    []openAmount{{11, 4000}},
+			allocations: []PaymentAllocation{{1, 11, 4000}},
+			paid:        []int{11},
+		},
+		{
+			name:     "nothing to pay",
+			invoices: []openAmount{{10, 0}},
+			paid:     []int{10},
+		},
+	}
+
+	for _, tt := range tests {
+		allocations, paid := allocate(tt.payments, tt.invoices)
+		if !reflect.DeepEqual(allocations, tt.allocations) {
+			t.Errorf("%s: expected allocations %v, got %v", tt.name, tt.allocations, allocations)
+		}
+		if !reflect.DeepEqual(paid, tt.paid) {
+			t.Errorf("%s: expected paid %v, got %v", tt.name, tt.paid, paid)
+		}
+	}
+}
+
+func TestBalance(t *testing.T) {
+	b := Balance{Invoiced: 10000, Paid: 12000}
+	if b.Open() != -2000 || b.Due() != 0 || b.Credit() != 2000 {
+		t.Errorf("unexpected balance with credit: %d %d %d", b.Open(), b.Due(), b.Credit())
+	}
+
+	b = Balance{Invoiced: 10000, Paid: 4000}
+	if b.Open() != 6000 || b.Due() != 6000 || b.Credit() != 0 {
+		t.Errorf("unexpected balance with debt: %d %d %d", b.Open(), b.Due(), b.Credit())
+	}
+}
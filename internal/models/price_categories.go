@@ -37,6 +37,7 @@ func (m *PriceCategoryModel) GetAll() (PriceCategories, error) {
 	stmt := `
 	SELECT id, name
 	  FROM price_categories
+	ORDER BY name
 	`
 
 	rows, err := m.DB.Query(stmt)
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"maps"
+	"slices"
+	"time"
 
 	"github.com/davidkuda/bellevue/internal/viewmodels"
 )
@@ -28,12 +30,62 @@ type ProductFormConfig struct {
 }
 
 // clones struct (nested map and slices needs recreation, otherwise,
-// updates app.productFormConfig)
+// updates the shared catalog snapshot of cmd/web)
 // and updates values, e.g. instead of 0 lunch, use 2 lunches, surplus.
 // intended to be called when editing an ActivityDay, i.e., UI form to edit
 // an existing submission.
 func (c ProductFormConfig) WithValues(activity *viewmodels.Activity) ProductFormConfig {
+	clone := c.clone()
 
+	for i, spec := range clone.Specs {
+		for _, consumption := range activity.Consumptions {
+			if spec.Code == consumption.ProductCode {
+				clone.Specs[i].Count = consumption.Quantity
+				clone.Specs[i].Amount = consumption.UnitPrice
+				for ipc, pc := range clone.Specs[i].PriceCategories {
+					clone.Specs[i].PriceCategories[ipc].Checked = false
+					if pc.Name == consumption.PriceCategory {
+						clone.Specs[i].PriceCategories[ipc].Checked = true
+					}
+				}
+			}
+		}
+	}
+
+	return clone
+}
+
+// WithPriceCategories clones the config with only the price categories the
+// user may pick, and the default of the user checked. If the user may not
+// pick the default either, the first category is checked. Call it before
+// WithValues, which checks the categories of an existing submission.
+func (c ProductFormConfig) WithPriceCategories(upc UserPriceCategories) ProductFormConfig {
+	clone := c.clone()
+
+	def := upc.DefaultCategory()
+
+	for i, spec := range clone.Specs {
+		if !spec.HasCategories {
+			continue
+		}
+
+		var options []PriceCategoryOption
+		for _, pc := range spec.PriceCategories {
+			if upc.Allows(pc.Name) {
+				pc.Checked = pc.Name == def
+				options = append(options, pc)
+			}
+		}
+		if len(options) > 0 && !slices.ContainsFunc(options, func(pc PriceCategoryOption) bool { return pc.Checked }) {
+			options[0].Checked = true
+		}
+		clone.Specs[i].PriceCategories = options
+	}
+
+	return clone
+}
+
+func (c ProductFormConfig) clone() ProductFormConfig {
 	clone := ProductFormConfig{
 		Prices: make(map[string]int, len(c.Prices)),
 		Specs:  make([]ProductFormSpec, len(c.Specs)),
@@ -55,21 +107,6 @@ func (c ProductFormConfig) WithValues(activity *viewmodels.Activity) ProductForm
 		}
 	}
 
-	for i, spec := range clone.Specs {
-		for _, consumption := range activity.Consumptions {
-			if spec.Code == consumption.ProductCode {
-				clone.Specs[i].Count = consumption.Quantity
-				clone.Specs[i].Amount = consumption.UnitPrice
-				for ipc, pc := range clone.Specs[i].PriceCategories {
-					clone.Specs[i].PriceCategories[ipc].Checked = false
-					if pc.Name == consumption.PriceCategory {
-						clone.Specs[i].PriceCategories[ipc].Checked = true
-					}
-				}
-			}
-		}
-	}
-
 	return clone
 }
 
@@ -100,11 +137,36 @@ type ProductModel struct {
 	DB *sql.DB
 }
 
+// Get returns the product with code and price category, "" for products
+// with a custom amount. ok is false if there is none.
+func (ps Products) Get(code, category string) (p Product, ok bool) {
+	for _, p := range ps {
+		if p.Code == code && p.PriceCategory.String == category {
+			return p, true
+		}
+	}
+	return Product{}, false
+}
+
+// A price change is a new row in products with the same code and price
+// category and a later valid_from. A removed price category or product ends
+// on the day before its deleted_at. validProducts selects the rows valid on
+// the date $1: the latest one per code and price category that became valid
+// on or before that day and had not ended.
+const validProducts = `
+	select distinct on (p.code, p.price_category_id) p.*
+	  from bellevue.products p
+	 where (p.deleted_at is null or p.deleted_at::date > $1::date)
+	   and p.valid_from::date <= $1::date
+  order by p.code, p.price_category_id, p.valid_from desc
+`
+
+// GetProductIDMap returns the IDs of the products valid today.
 func (m *ProductModel) GetProductIDMap() (ProductIDMap, error) {
 	pidm := ProductIDMap{}
-	products, err := m.GetAll()
+	products, err := m.GetAllAt(time.Now())
 	if err != nil {
-		return nil, fmt.Errorf("Products.GetAll: %v", err)
+		return nil, fmt.Errorf("Products.GetAllAt: %v", err)
 	}
 	for _, p := range products {
 		var pricecat string
@@ -117,9 +179,17 @@ func (m *ProductModel) GetProductIDMap() (ProductIDMap, error) {
 	return pidm, nil
 }
 
+// GetProductFormConfig returns the form with the prices valid today.
 func (m *ProductModel) GetProductFormConfig() (ProductFormConfig, error) {
+	return m.GetProductFormConfigAt(time.Now())
+}
+
+// GetProductFormConfigAt returns the form with the prices valid on date,
+// e.g. to edit an older activity.
+func (m *ProductModel) GetProductFormConfigAt(date time.Time) (ProductFormConfig, error) {
 	stmt := `
-with product_form_specs as (
+with valid_products as (` + validProducts + `
+), product_form_specs as (
        select p.name,
               p.code,
               bool_or(p.price_category_id is not null) as has_categories,
@@ -128,19 +198,18 @@ with product_form_specs as (
                 json_build_object(
                   'name',    pc.name,
                   'price',   p.price,
-                  'checked', (pc.name = 'regular')
+                  'checked', (pc.name = $2)
                 )
                 order by pc.name
                 )
                 filter (where pc.name is not null and p.price is not null)
               as categories_json,
               min(pfo.sort_order) as sort_order
-         from bellevue.products p
+         from valid_products p
     left join bellevue.price_categories pc
            on pc.id = p.price_category_id
     left join bellevue.product_form_order pfo
            on pfo.code = p.code
-        where p.deleted_at is null
         group by p.name, p.code
 )
   select name,
@@ -153,7 +222,7 @@ order by sort_order nulls last, code
 ;
 	`
 
-	rows, err := m.DB.Query(stmt)
+	rows, err := m.DB.Query(stmt, date, DefaultPriceCategory)
 	if err != nil {
 		return ProductFormConfig{}, fmt.Errorf("DB.Query(stmt): %v", err)
 	}
@@ -194,9 +263,8 @@ order by sort_order nulls last, code
 	return pfc, nil
 }
 
+// GetAll returns all products, including every price they ever had.
 func (m *ProductModel) GetAll() (Products, error) {
-	var err error
-
 	stmt := `
 	   SELECT p.id, p.name, p.code, p.pricing_mode, cat.name, p.price
 	     FROM products p
@@ -205,7 +273,26 @@ func (m *ProductModel) GetAll() (Products, error) {
 	;
 	`
 
-	rows, err := m.DB.Query(stmt)
+	return m.getMultiple(stmt)
+}
+
+// GetAllAt returns the products with the prices valid on date. Activities
+// are priced by their date, not by the day they were entered.
+func (m *ProductModel) GetAllAt(date time.Time) (Products, error) {
+	stmt := `
+	     with valid_products as (` + validProducts + `)
+	   SELECT p.id, p.name, p.code, p.pricing_mode, cat.name, p.price
+	     FROM valid_products p
+	LEFT JOIN price_categories cat
+	       ON cat.id = p.price_category_id
+	;
+	`
+
+	return m.getMultiple(stmt, date)
+}
+
+func (m *ProductModel) getMultiple(stmt string, args ...any) (Products, error) {
+	rows, err := m.DB.Query(stmt, args...)
 	if err != nil {
 		return nil, fmt.Errorf("DB.Query(stmt): %v", err)
 	}
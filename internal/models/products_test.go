@@ -0,0 +1,89 @@
+package models
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+func TestProductsGet(t *testing.T) {
+	products := Products{
+		{ID: 1, Code: "lunch", PriceCategory: sql.NullString{String: "regular", Valid: true}, Price: sql.NullInt64{Int64: 1500, Valid: true}},
+		{ID: 2, Code: "lunch", PriceCategory: sql.NullString{String: "reduced", Valid: true}, Price: sql.NullInt64{Int64: 1000, Valid: true}},
+		{ID: 3, Code: "food", PricingMode: "custom"},
+	}
+
+	tests := []struct {
+		code     string
+		category string
+		wantID   int
+		wantOK   bool
+	}{
+		{"lunch", "regular", 1, true},
+		{"lunch", "reduced", 2, true},
+		{"food", "", 3, true},
+		{"lunch", "", 0, false},
+		{"dinner", "regular", 0, false},
+	}
+
+	for _, tt := range tests {
+		p, ok := products.Get(tt.code, tt.category)
+		if ok != tt.wantOK || p.ID != tt.wantID {
+			t.Errorf("Get(%q, %q) = %d, %v, want %d, %v", tt.code, tt.category, p.ID, ok, tt.wantID, tt.wantOK)
+		}
+	}
+}
+
+func TestProductFormConfigWithPriceCategories(t *testing.T) {
+	config := ProductFormConfig{
+		Specs: []ProductFormSpec{
+			{Code: "lunch", HasCategories: true, PriceCategories: []PriceCategoryOption{
+				{Name: "reduced", Price: 1000},
+				{Name: "regular", Price: 1500, Checked: true},
+				{Name: "surplus", Price: 2000},
+			}},
+			{Code: "food", IsCustomAmount: true},
+		},
+	}
+
+	checked := func(c ProductFormConfig) (names []string, checked string) {
+		for _, pc := range c.Specs[0].PriceCategories {
+			names = append(names, pc.Name)
+			if pc.Checked {
+				checked += pc.Name
+			}
+		}
+		return names, checked
+	}
+
+	tests := []struct {
+		name        string
+		upc         UserPriceCategories
+		wantNames   []string
+		wantChecked string
+	}{
+		{"no settings", UserPriceCategories{}, []string{"reduced", "regular", "surplus"}, "regular"},
+		{"default", UserPriceCategories{Default: "reduced"}, []string{"reduced", "regular", "surplus"}, "reduced"},
+		{"allowed", UserPriceCategories{Allowed: []string{"regular", "surplus"}}, []string{"regular", "surplus"}, "regular"},
+		{"allowed without regular", UserPriceCategories{Allowed: []string{"surplus", "reduced"}}, []string{"reduced", "surplus"}, "reduced"},
+		{"allowed and default", UserPriceCategories{Default: "surplus", Allowed: []string{"regular", "surplus"}}, []string{"regular", "surplus"}, "surplus"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := config.WithPriceCategories(tt.upc)
+			names, c := checked(got)
+			if !reflect.DeepEqual(names, tt.wantNames) || c != tt.wantChecked {
+				t.Errorf("got %v with %q checked, want %v with %q checked", names, c, tt.wantNames, tt.wantChecked)
+			}
+			if len(got.Specs[1].PriceCategories) != 0 {
+				t.Errorf("custom amount got price categories %v", got.Specs[1].PriceCategories)
+			}
+		})
+	}
+
+	// the shared config is not modified.
+	if names, c := checked(config); len(names) != 3 || c != "regular" {
+		t.Errorf("config was modified: %v with %q checked", names, c)
+	}
+}
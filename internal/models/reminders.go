@@ -0,0 +1,202 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ReminderModel struct {
+	DB *sql.DB
+}
+
+// DunningLevel is a payment reminder that is sent After days once an invoice
+// was sent and is still not paid.
+type DunningLevel struct {
+	Level int
+	After int // days since the invoice was sent
+}
+
+// DefaultDunningLevels: a friendly reminder after 30 days, a second one
+// after 60 days.
+var DefaultDunningLevels = []DunningLevel{
+	{Level: 1, After: 30},
+	{Level: 2, After: 60},
+}
+
+// ParseDunningLevels parses a comma separated list of days, e.g. "30,60".
+// The first entry is level 1, the second level 2 and so on.
+func ParseDunningLevels(s string) ([]DunningLevel, error) {
+	var levels []DunningLevel
+	for i, field := range strings.Split(s, ",") {
+		days, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || days <= 0 {
+			return nil, fmt.Errorf("invalid dunning level %q: expected a positive number of days", field)
+		}
+		if i > 0 && days <= levels[i-1].After {
+			return nil, fmt.Errorf("invalid dunning level %q: days must be increasing", field)
+		}
+		levels = append(levels, DunningLevel{Level: i + 1, After: days})
+	}
+	return levels, nil
+}
+
+// OverdueInvoice is a sent invoice that is not paid in full.
+type OverdueInvoice struct {
+	InvoiceID  int
+	UserID     int
+	Number     string
+	Reference  string
+	FirstName  string
+	LastName   string
+	Email      string
+	TotalPrice int
+	Paid       int // allocated partial payments
+	SentAt     time.Time
+
+	// LastLevel is the highest reminder level sent so far, 0 if none.
+	LastLevel      int
+	LastReminderAt sql.NullTime
+}
+
+// Open is what is left to pay.
+func (o OverdueInvoice) Open() int {
+	return o.TotalPrice - o.Paid
+}
+
+// Days since the invoice was sent.
+func (o OverdueInvoice) Days(now time.Time) int {
+	return int(now.Sub(o.SentAt).Hours() / 24)
+}
+
+// NextReminder returns the highest dunning level that is due for o and was
+// not sent yet. Levels that were skipped, e.g. because reminders were turned
+// off for a while, are not sent afterwards.
+func NextReminder(levels []DunningLevel, o OverdueInvoice, now time.Time) (DunningLevel, bool) {
+	var next DunningLevel
+	var ok bool
+
+	if o.Open() <= 0 {
+		return next, false
+	}
+
+	days := o.Days(now)
+	for _, l := range levels {
+		if l.Level > o.LastLevel && l.After <= days {
+			next, ok = l, true
+		}
+	}
+	return next, ok
+}
+
+// GetOverdue returns all sent invoices that were sent at least after days
+// ago, oldest first.
+func (m *ReminderModel) GetOverdue(after int) ([]OverdueInvoice, error) {
+	stmt := `
+	     with totals as (
+	   select a.invoice_id,
+	          sum(c.total_price) as total
+	     from activities a
+	     join consumptions c
+	       on c.activity_id = a.id
+	    where a.invoice_id is not null
+	 group by a.invoice_id
+	), paid as (
+	   select invoice_id,
+	          sum(amount) as paid
+	     from payment_allocations
+	 group by invoice_id
+	), reminders as (
+	   select invoice_id,
+	          max(level) as level,
+	          max(sent_at) as sent_at
+	     from invoice_reminders
+	 group by invoice_id
+	)
+	   select i.id,
+	          i.user_id,
+	          coalesce(i.number, ''),
+	          i.reference,
+	          u.first_name,
+	          u.last_name,
+	          u.email,
+	          coalesce(t.total, 0),
+	          coalesce(p.paid, 0),
+	          i.sent_at,
+	          coalesce(r.level, 0),
+	          r.sent_at
+	     from invoices_v2 i
+	     join users u
+	       on u.id = i.user_id
+	left join totals t
+	       on t.invoice_id = i.id
+	left join paid p
+	       on p.invoice_id = i.id
+	left join reminders r
+	       on r.invoice_id = i.id
+	    where i.status = 'sent'
+	      and i.sent_at <= now() - make_interval(days => $1)
+	 order by i.sent_at;
+	`
+
+	rows, err := m.DB.Query(stmt, after)
+	if err != nil {
+		return nil, fmt.Errorf("DB.Query(stmt): %v", err)
+	}
+	defer rows.Close()
+
+	var invoices []OverdueInvoice
+	for rows.Next() {
+		var o OverdueInvoice
+		err = rows.Scan(
+			&o.InvoiceID,
+			&o.UserID,
+			&o.Number,
+			&o.Reference,
+			&o.FirstName,
+			&o.LastName,
+			&o.Email,
+			&o.TotalPrice,
+			&o.Paid,
+			&o.SentAt,
+			&o.LastLevel,
+			&o.LastReminderAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("for rows.Next(): %v", err)
+		}
+		invoices = append(invoices, o)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %v", err)
+	}
+
+	return invoices, nil
+}
+
+// InsertTx records that the reminder of level was sent for the invoice over
+// the open amount. ok is false if this level was already recorded, e.g. by a
+// concurrent run.
+func (m *ReminderModel) InsertTx(invoiceID, level, amount int, tx *sql.Tx) (ok bool, err error) {
+	stmt := `
+	insert into invoice_reminders (invoice_id, level, amount)
+	values ($1, $2, $3)
+	on conflict (invoice_id, level) do nothing
+	returning id;
+	`
+
+	var id int
+	err = tx.QueryRow(stmt, invoiceID, level, amount).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed inserting invoice reminder: %v", err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseDunningLevels(t *testing.T) {
+	levels, err := ParseDunningLevels("30, 60")
+	if err != nil {
+		t.Fatalf("ParseDunningLevels(): %v", err)
+	}
+	if !reflect.DeepEqual(levels, DefaultDunningLevels) {
+		t.Errorf("expected %v, got %v", DefaultDunningLevels, levels)
+	}
+
+	for _, s := range []string{"", "30,abc", "0", "60,30", "30,30"} {
+		if _, err := ParseDunningLevels(s); err == nil {
+			t.Errorf("ParseDunningLevels(%q): expected an error", s)
+		}
+	}
+}
+
+func TestNextReminder(t *testing.T) {
+	now := time.Date(2026, time.June, 30, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(n int) time.Time { return now.AddDate(0, 0, -n) }
+
+	tests := []struct {
+		name    string
+		invoice OverdueInvoice
+		level   int // 0 means no reminder
+	}{
+		{"not yet due", OverdueInvoice{TotalPrice: 4850, SentAt: daysAgo(29)}, 0},
+		{"first reminder", OverdueInvoice{TotalPrice: 4850, SentAt: daysAgo(30)}, 1},
+		{"first reminder already sent", OverdueInvoice{TotalPrice: 4850, SentAt: daysAgo(45), LastLevel: 1}, 0},
+		{"second reminder", OverdueInvoice{TotalPrice: 4850, SentAt: daysAgo(61), LastLevel: 1}, 2},
+		{"skipped level", OverdueInvoice{TotalPrice: 4850, SentAt: daysAgo(90)}, 2},
+		{"all levels sent", OverdueInvoice{TotalPrice: 4850, SentAt: daysAgo(120), LastLevel: 2}, 0},
+		{"paid in full", OverdueInvoice{TotalPrice: 4850, Paid: 4850, SentAt: daysAgo(61)}, 0},
+		{"partially paid", OverdueInvoice{TotalPrice: 4850, Paid: 2000, SentAt: daysAgo(31)}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, ok := NextReminder(DefaultDunningLevels, tt.invoice, now)
+			if tt.level == 0 {
+				if ok {
+					t.Errorf("expected no reminder, got level %d", next.Level)
+				}
+				return
+			}
+			if !ok || next.Level != tt.level {
+				t.Errorf("expected level %d, got %d (ok=%v)", tt.level, next.Level, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// DefaultPriceCategory is checked in the activity form of users without a
+// default of their own.
+const DefaultPriceCategory = "regular"
+
+var ErrPriceCategoryNotAllowed = errors.New("models: price category not allowed")
+
+// UserPriceCategories are the price categories a user may pick in the
+// activity form, and the one that is checked by default.
+type UserPriceCategories struct {
+	UserID  int
+	Default string   // "" for DefaultPriceCategory
+	Allowed []string // nil allows all price categories
+}
+
+// DefaultCategory returns the price category that is checked by default.
+func (c UserPriceCategories) DefaultCategory() string {
+	if c.Default == "" {
+		return DefaultPriceCategory
+	}
+	return c.Default
+}
+
+// Allows reports whether the user may pick the price category name.
+func (c UserPriceCategories) Allows(name string) bool {
+	return c.Allowed == nil || slices.Contains(c.Allowed, name)
+}
+
+// GetPriceCategories returns the price categories of the user.
+func (m *UserModel) GetPriceCategories(userID int) (UserPriceCategories, error) {
+	all, err := m.getPriceCategories(userID)
+	if err != nil {
+		return UserPriceCategories{}, err
+	}
+	if c, ok := all[userID]; ok {
+		return c, nil
+	}
+	return UserPriceCategories{UserID: userID}, nil
+}
+
+// GetAllPriceCategories returns the price categories of all users by their
+// ID, for the admin.
+func (m *UserModel) GetAllPriceCategories() (map[int]UserPriceCategories, error) {
+	return m.getPriceCategories(0)
+}
+
+// getPriceCategories returns the price categories of userID, of all users if
+// userID is 0.
+func (m *UserModel) getPriceCategories(userID int) (map[int]UserPriceCategories, error) {
+	stmt := `
+	   select u.id,
+	          coalesce(dpc.name, ''),
+	          coalesce(
+	            json_agg(apc.name order by apc.name) filter (where apc.name is not null),
+	            '[]'::json
+	          )
+	     from users u
+	left join price_categories dpc
+	       on dpc.id = u.default_price_category_id
+	left join user_price_categories upc
+	       on upc.user_id = u.id
+	left join price_categories apc
+	       on apc.id = upc.price_category_id
+	    where $1 = 0 or u.id = $1
+	 group by u.id, dpc.name
+	`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, fmt.Errorf("DB.Query(stmt): %v", err)
+	}
+	defer rows.Close()
+
+	all := map[int]UserPriceCategories{}
+	for rows.Next() {
+		var c UserPriceCategories
+		var allowedJSON []byte
+		if err := rows.Scan(&c.UserID, &c.Default, &allowedJSON); err != nil {
+			return nil, fmt.Errorf("for rows.Next(): %v", err)
+		}
+		var allowed []string
+		if err := json.Unmarshal(allowedJSON, &allowed); err != nil {
+			return nil, fmt.Errorf("unmarshal price categories of userID=%d: %w", c.UserID, err)
+		}
+		if len(allowed) > 0 {
+			c.Allowed = allowed
+		}
+		all[c.UserID] = c
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %v", err)
+	}
+
+	return all, nil
+}
+
+// SetDefaultPriceCategory sets the default price category of the user, ""
+// for DefaultPriceCategory. It must be an existing one the user may pick,
+// else ErrPriceCategoryNotAllowed.
+func (m *UserModel) SetDefaultPriceCategory(userID int, name string) error {
+	c, err := m.GetPriceCategories(userID)
+	if err != nil {
+		return err
+	}
+	if name != "" && !c.Allows(name) {
+		return ErrPriceCategoryNotAllowed
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := setDefaultPriceCategoryTx(userID, name, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetPriceCategories sets the price categories the user may pick and the
+// default one, as the admin does. Each allowed name must be a price
+// category and the default must be allowed, else ErrPriceCategoryNotAllowed.
+func (m *UserModel) SetPriceCategories(c UserPriceCategories) error {
+	if c.Default != "" && !c.Allows(c.Default) {
+		return ErrPriceCategoryNotAllowed
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`delete from user_price_categories where user_id = $1`, c.UserID)
+	if err != nil {
+		return fmt.Errorf("failed deleting price categories of userID=%d: %v", c.UserID, err)
+	}
+
+	for _, name := range c.Allowed {
+		stmt := `
+		insert into user_price_categories (user_id, price_category_id)
+		select $1, id
+		  from price_categories
+		 where name = $2
+		   and deleted_at is null
+		`
+		result, err := tx.Exec(stmt, c.UserID, name)
+		if err != nil {
+			return fmt.Errorf("failed inserting price category %s of userID=%d: %v", name, c.UserID, err)
+		}
+		// an unknown name would be dropped, and with it the restriction if
+		// no name is left.
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n != 1 {
+			return ErrPriceCategoryNotAllowed
+		}
+	}
+
+	if err := setDefaultPriceCategoryTx(c.UserID, c.Default, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// setDefaultPriceCategoryTx sets the default price category of the user, ""
+// for DefaultPriceCategory. A name that is no price category is
+// ErrPriceCategoryNotAllowed, it would clear the default.
+func setDefaultPriceCategoryTx(userID int, name string, tx *sql.Tx) error {
+	if name != "" {
+		var exists bool
+		stmt := `select exists (select 1 from price_categories where name = $1 and deleted_at is null)`
+		if err := tx.QueryRow(stmt, name).Scan(&exists); err != nil {
+			return fmt.Errorf("DB.QueryRow(): %v", err)
+		}
+		if !exists {
+			return ErrPriceCategoryNotAllowed
+		}
+	}
+
+	stmt := `
+	update users
+	   set default_price_category_id = (select id from price_categories where name = $2)
+	 where id = $1
+	`
+	result, err := tx.Exec(stmt, userID, name)
+	if err != nil {
+		return fmt.Errorf("failed updating default price category of userID=%d: %v", userID, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
@@ -14,6 +14,7 @@ type User struct {
 	Email     string
 	FirstName string
 	LastName  string
+	Role      string // RoleMember or RoleStaff
 	Method    string
 	// email signups / logins:
 	HashedPassword []byte
@@ -22,6 +23,13 @@ type User struct {
 	CreatedAt time.Time
 }
 
+const (
+	RoleMember = "member"
+	// RoleStaff may enter activities on behalf of any member, e.g. the
+	// kitchen.
+	RoleStaff = "staff"
+)
+
 type UserModel struct {
 	DB *sql.DB
 }
@@ -132,21 +140,31 @@ func (m *UserModel) GetUserIDByEmail(email string) (int, error) {
 
 func (m *UserModel) GetAll() ([]User, error) {
 	stmt := `
-	SELECT id, first_name, last_name, email
-	FROM users;
+	SELECT id, first_name, last_name, email, role
+	FROM users
+	ORDER BY first_name, last_name;
 	`
 	return m.getMultiple(stmt)
 }
 
+// GetAllWithUninvoicedActivities returns the users to invoice: those with
+// open activities, and the billing contacts of households whose members have
+// open activities. The members themselves are not returned.
 func (m *UserModel) GetAllWithUninvoicedActivities() ([]User, error) {
 	stmt := `
-	select u.id,
-	       u.first_name,
-	       u.last_name,
-	       u.email
-	  from users u
-	  join activities a
+	select distinct
+	       b.id,
+	       b.first_name,
+	       b.last_name,
+	       b.email,
+	       b.role
+	  from activities a
+	  join users u
 	    on u.id = a.user_id
+	left join households h
+	    on h.id = u.household_id
+	  join users b
+	    on b.id = coalesce(h.billing_user_id, u.id)
 	 where a.invoice_id is null
 	`
 	return m.getMultiple(stmt)
@@ -169,6 +187,7 @@ func (m *UserModel) getMultiple(stmt string) ([]User, error) {
 			&user.FirstName,
 			&user.LastName,
 			&user.Email,
+			&user.Role,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("rows.Scan: %v", err)
@@ -185,7 +204,7 @@ func (m *UserModel) getMultiple(stmt string) ([]User, error) {
 
 func (m *UserModel) GetUserByID(id int) (User, error) {
 	stmt := `
-	SELECT id, first_name, last_name, email
+	SELECT id, first_name, last_name, email, role
 	FROM users
 	WHERE id = $1;
 	`
@@ -196,14 +215,39 @@ func (m *UserModel) GetUserByID(id int) (User, error) {
 		&u.FirstName,
 		&u.LastName,
 		&u.Email,
+		&u.Role,
 	)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return u, ErrNoRecord
+		}
 		return u, fmt.Errorf("failed getting user by id with id=%d: %s", id, err)
 	}
 
 	return u, nil
 }
 
+// SetRole sets the role of the user, RoleMember or RoleStaff.
+func (m *UserModel) SetRole(userID int, role string) error {
+	stmt := `
+	update users
+	   set role = $2
+	 where id = $1
+	`
+	result, err := m.DB.Exec(stmt, userID, role)
+	if err != nil {
+		return fmt.Errorf("failed setting role of userID=%d: %v", userID, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
+
 func (m *UserModel) GetUserByEmail(email string) (User, error) {
 	stmt := `
 	SELECT id, first_name, last_name
@@ -0,0 +1,264 @@
+package qrbill
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/skip2/go-qrcode"
+)
+
+// all measures are in mm, the payment part is A6 landscape (148 x 105) and the
+// receipt 62 x 105, together they span the bottom of an A4 page.
+const (
+	pageWidth    = 210.0
+	pageHeight   = 297.0
+	slipHeight   = 105.0
+	receiptWidth = 62.0
+	qrSize       = 46.0
+	crossSize    = 7.0
+)
+
+// Draw renders the receipt and the payment part at the bottom of the current
+// page of pdf. The pdf must use mm as unit and A4 portrait.
+func (b Bill) Draw(pdf *fpdf.Fpdf) error {
+	payload, err := b.Payload()
+	if err != nil {
+		return err
+	}
+
+	qr, err := qrcode.New(payload, qrcode.Medium)
+	if err != nil {
+		return fmt.Errorf("qrcode.New: %w", err)
+	}
+	qr.DisableBorder = true
+
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	y0 := pageHeight - slipHeight
+
+	autoPageBreak, bottomMargin := pdf.GetAutoPageBreak()
+	pdf.SetAutoPageBreak(false, 0)
+	defer pdf.SetAutoPageBreak(autoPageBreak, bottomMargin)
+
+	// separation lines
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetLineWidth(0.2)
+	pdf.SetDashPattern([]float64{1, 1}, 0)
+	pdf.Line(0, y0, pageWidth, y0)
+	pdf.Line(receiptWidth, y0, receiptWidth, pageHeight)
+	pdf.SetDashPattern([]float64{}, 0)
+
+	b.drawReceipt(pdf, tr, y0)
+	b.drawPaymentPart(pdf, tr, y0, qr.Bitmap())
+
+	return pdf.Error()
+}
+
+func (b Bill) drawReceipt(pdf *fpdf.Fpdf, tr func(string) string, y0 float64) {
+	x := 5.0
+	w := receiptWidth - 10
+
+	pdf.SetFont("Helvetica", "B", 11)
+	pdf.Text(x, y0+9, tr("Empfangsschein"))
+
+	s := section{pdf: pdf, tr: tr, x: x, y: y0 + 12, w: w, headSize: 6, valSize: 8}
+	s.field("Konto / Zahlbar an", b.creditorLines()...)
+	if b.Reference != "" {
+		s.field("Referenz", FormatReference(b.Reference))
+	}
+	if b.Debtor != nil {
+		s.field("Zahlbar durch", displayAddress(b.Debtor)...)
+	} else {
+		s.heading("Zahlbar durch (Name/Adresse)")
+		cornerMarks(pdf, x, s.y+1, 52, 20)
+	}
+
+	b.drawAmount(pdf, tr, x, y0+68, 6, 8, x+12, 30, 10)
+
+	pdf.SetFont("Helvetica", "B", 6)
+	label := tr("Annahmestelle")
+	pdf.Text(receiptWidth-5-pdf.GetStringWidth(label), y0+85, label)
+}
+
+func (b Bill) drawPaymentPart(pdf *fpdf.Fpdf, tr func(string) string, y0 float64, bitmap [][]bool) {
+	x := receiptWidth + 5
+
+	pdf.SetFont("Helvetica", "B", 11)
+	pdf.Text(x, y0+9, tr("Zahlteil"))
+
+	drawQRCode(pdf, bitmap, x, y0+17)
+
+	b.drawAmount(pdf, tr, x, y0+68, 8, 10, x+14, 40, 15)
+
+	s := section{pdf: pdf, tr: tr, x: x + 51, y: y0 + 5, w: pageWidth - x - 56, headSize: 8, valSize: 10}
+	s.field("Konto / Zahlbar an", b.creditorLines()...)
+	if b.Reference != "" {
+		s.field("Referenz", FormatReference(b.Reference))
+	}
+	if b.Message != "" {
+		s.field("Zusätzliche Informationen", truncate(b.Message, 140))
+	}
+	if b.Debtor != nil {
+		s.field("Zahlbar durch", displayAddress(b.Debtor)...)
+	} else {
+		s.heading("Zahlbar durch (Name/Adresse)")
+		cornerMarks(pdf, s.x, s.y+1, 65, 25)
+	}
+}
+
+// drawAmount renders the currency and amount. Without an amount, a box with
+// corner marks is drawn instead, so that the debtor can fill it in by hand.
+func (b Bill) drawAmount(pdf *fpdf.Fpdf, tr func(string) string, x, y, headSize, valSize, amountX, boxW, boxH float64) {
+	pdf.SetFont("Helvetica", "B", headSize)
+	pdf.Text(x, y, tr("Währung"))
+	pdf.Text(amountX, y, tr("Betrag"))
+
+	pdf.SetFont("Helvetica", "", valSize)
+	pdf.Text(x, y+pt(valSize)+1, b.currency())
+	if b.Amount > 0 {
+		pdf.Text(amountX, y+pt(valSize)+1, FormatAmount(b.Amount))
+	} else {
+		cornerMarks(pdf, amountX, y+1, boxW, boxH)
+	}
+}
+
+func (b Bill) creditorLines() []string {
+	return append([]string{FormatIBAN(b.IBAN)}, displayAddress(&b.Creditor)...)
+}
+
+func displayAddress(a *Address) []string {
+	lines := []string{a.Name}
+	if a.Street != "" {
+		lines = append(lines, strings.TrimSpace(a.Street+" "+a.BuildingNumber))
+	}
+	town := strings.TrimSpace(a.PostalCode + " " + a.Town)
+	if a.Country != "" && a.Country != "CH" {
+		town = a.Country + "-" + town
+	}
+	return append(lines, town)
+}
+
+// section writes headings and values below each other.
+type section struct {
+	pdf      *fpdf.Fpdf
+	tr       func(string) string
+	x, y, w  float64
+	headSize float64
+	valSize  float64
+}
+
+func (s *section) heading(text string) {
+	s.pdf.SetFont("Helvetica", "B", s.headSize)
+	s.y += pt(s.headSize)
+	s.pdf.Text(s.x, s.y, s.tr(text))
+}
+
+func (s *section) field(heading string, values ...string) {
+	s.heading(heading)
+	s.pdf.SetFont("Helvetica", "", s.valSize)
+	for _, v := range values {
+		for _, line := range s.pdf.SplitText(s.tr(v), s.w) {
+			s.y += pt(s.valSize) + 0.4
+			s.pdf.Text(s.x, s.y, line)
+		}
+	}
+	s.y += pt(s.valSize)
+}
+
+// drawQRCode draws the modules as vector rectangles and puts the Swiss cross
+// in the middle. Adjacent modules in a row are merged into one rectangle.
+func drawQRCode(pdf *fpdf.Fpdf, bitmap [][]bool, x, y float64) {
+	n := len(bitmap)
+	m := qrSize / float64(n)
+
+	pdf.SetFillColor(0, 0, 0)
+	for row := range bitmap {
+		for col := 0; col < n; col++ {
+			if !bitmap[row][col] {
+				continue
+			}
+			start := col
+			for col < n && bitmap[row][col] {
+				col++
+			}
+			pdf.Rect(x+float64(start)*m, y+float64(row)*m, float64(col-start)*m, m, "F")
+		}
+	}
+
+	// Swiss cross: white border, black square, white cross.
+	cx := x + qrSize/2
+	cy := y + qrSize/2
+	pdf.SetFillColor(255, 255, 255)
+	pdf.Rect(cx-crossSize/2, cy-crossSize/2, crossSize, crossSize, "F")
+	pdf.SetFillColor(0, 0, 0)
+	inner := crossSize - 1
+	pdf.Rect(cx-inner/2, cy-inner/2, inner, inner, "F")
+	pdf.SetFillColor(255, 255, 255)
+	armLength := inner * 0.65
+	armWidth := armLength / 3.4
+	pdf.Rect(cx-armWidth/2, cy-armLength/2, armWidth, armLength, "F")
+	pdf.Rect(cx-armLength/2, cy-armWidth/2, armLength, armWidth, "F")
+}
+
+// cornerMarks draws the corners of a box that the debtor fills in by hand.
+func cornerMarks(pdf *fpdf.Fpdf, x, y, w, h float64) {
+	const l = 3.0
+	pdf.SetLineWidth(0.2)
+	pdf.Line(x, y, x+l, y)
+	pdf.Line(x, y, x, y+l)
+	pdf.Line(x+w-l, y, x+w, y)
+	pdf.Line(x+w, y, x+w, y+l)
+	pdf.Line(x, y+h, x+l, y+h)
+	pdf.Line(x, y+h-l, x, y+h)
+	pdf.Line(x+w-l, y+h, x+w, y+h)
+	pdf.Line(x+w, y+h-l, x+w, y+h)
+}
+
+// FormatIBAN groups the IBAN in blocks of four characters.
+func FormatIBAN(iban string) string {
+	return group(compact(iban), 4, 0)
+}
+
+// FormatReference groups a QR reference as 2 + 5x5 digits and a creditor
+// reference in blocks of four characters.
+func FormatReference(ref string) string {
+	ref = compact(ref)
+	if ValidQRReference(ref) {
+		return group(ref, 5, 2)
+	}
+	return group(ref, 4, 0)
+}
+
+// FormatAmount formats Rappen like "1 234.50", as required on the payment part.
+func FormatAmount(amount int) string {
+	francs := fmt.Sprintf("%d", amount/100)
+	var b strings.Builder
+	for i, r := range francs {
+		if i > 0 && (len(francs)-i)%3 == 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteRune(r)
+	}
+	return fmt.Sprintf("%s.%02d", b.String(), amount%100)
+}
+
+// group inserts a space every n characters, after an optional first block of
+// length first.
+func group(s string, n, first int) string {
+	var parts []string
+	if first > 0 && len(s) > first {
+		parts = append(parts, s[:first])
+		s = s[first:]
+	}
+	for len(s) > n {
+		parts = append(parts, s[:n])
+		s = s[n:]
+	}
+	parts = append(parts, s)
+	return strings.Join(parts, " ")
+}
+
+// pt converts a font size in points into mm.
+func pt(size float64) float64 {
+	return size * 0.3528
+}
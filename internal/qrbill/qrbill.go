@@ -0,0 +1,350 @@
+// Package qrbill implements the payment part of a Swiss QR-bill.
+//
+// The format follows the "Swiss Implementation Guidelines for the QR-bill",
+// version 2.3, see https://www.six-group.com/en/products-services/banking-services/payment-standardization/standards/qr-bill.html
+package qrbill
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrInvalidIBAN      = errors.New("qrbill: invalid IBAN")
+	ErrInvalidAddress   = errors.New("qrbill: invalid address")
+	ErrInvalidAmount    = errors.New("qrbill: invalid amount")
+	ErrInvalidReference = errors.New("qrbill: invalid reference")
+)
+
+const (
+	ReferenceTypeQR       = "QRR"
+	ReferenceTypeCreditor = "SCOR"
+	ReferenceTypeNone     = "NON"
+)
+
+// Address is a structured address (address type "S").
+type Address struct {
+	Name           string
+	Street         string
+	BuildingNumber string
+	PostalCode     string
+	Town           string
+	Country        string // ISO 3166-1 alpha-2, e.g. CH
+}
+
+// Bill holds everything that goes into the QR code and the payment part.
+type Bill struct {
+	IBAN     string
+	Creditor Address
+	Amount   int    // in Rappen, 0 means that the debtor fills in the amount
+	Currency string // CHF or EUR, defaults to CHF
+	Debtor   *Address
+	// Reference is either a QR reference (27 digits), an ISO 11649 creditor
+	// reference (RF...) or empty.
+	Reference string
+	// Message is the unstructured message. It is cut to 140 characters.
+	Message string
+}
+
+// ParseAddress builds a structured Address out of the two free-text lines we
+// have in the config, e.g. "Bellevue 1" and "8873 Amden".
+func ParseAddress(name, street, postalCodeTown string) Address {
+	a := Address{
+		Name:    strings.TrimSpace(name),
+		Country: "CH",
+	}
+
+	street = strings.TrimSpace(street)
+	if i := strings.LastIndex(street, " "); i > 0 && startsWithDigit(street[i+1:]) {
+		a.Street = street[:i]
+		a.BuildingNumber = street[i+1:]
+	} else {
+		a.Street = street
+	}
+
+	postalCodeTown = strings.TrimSpace(postalCodeTown)
+	if i := strings.Index(postalCodeTown, " "); i > 0 {
+		a.PostalCode = postalCodeTown[:i]
+		a.Town = strings.TrimSpace(postalCodeTown[i+1:])
+	} else {
+		a.Town = postalCodeTown
+	}
+
+	return a
+}
+
+// ReferenceType derives the reference type from the reference itself.
+func (b Bill) ReferenceType() string {
+	switch {
+	case b.Reference == "":
+		return ReferenceTypeNone
+	case strings.HasPrefix(strings.ToUpper(b.Reference), "RF"):
+		return ReferenceTypeCreditor
+	default:
+		return ReferenceTypeQR
+	}
+}
+
+// Payload returns the content of the Swiss QR code.
+func (b Bill) Payload() (string, error) {
+	if err := b.validate(); err != nil {
+		return "", err
+	}
+
+	lines := []string{
+		"SPC",  // QR type
+		"0200", // version
+		"1",    // coding type: UTF-8 restricted to the Latin character set
+		compact(b.IBAN),
+	}
+	lines = append(lines, addressLines(&b.Creditor)...)
+	// ultimate creditor, reserved for future use:
+	lines = append(lines, "", "", "", "", "", "", "")
+	lines = append(lines, b.amountString(), b.currency())
+	lines = append(lines, addressLines(b.Debtor)...)
+	lines = append(lines,
+		b.ReferenceType(),
+		compact(b.Reference),
+		truncate(b.Message, 140),
+		"EPD", // end payment data
+	)
+
+	return strings.Join(lines, "\n"), nil
+}
+
+func (b Bill) validate() error {
+	if err := validateIBAN(b.IBAN); err != nil {
+		return err
+	}
+	if err := validateAddress(&b.Creditor); err != nil {
+		return fmt.Errorf("creditor: %w", err)
+	}
+	if b.Debtor != nil {
+		if err := validateAddress(b.Debtor); err != nil {
+			return fmt.Errorf("debtor: %w", err)
+		}
+	}
+	if b.Amount < 0 || b.Amount > 99999999999 {
+		return ErrInvalidAmount
+	}
+	if c := b.currency(); c != "CHF" && c != "EUR" {
+		return fmt.Errorf("qrbill: unsupported currency %q", c)
+	}
+
+	// a QR-IBAN takes QR references only, any other IBAN never does.
+	qrIBAN := IsQRIBAN(b.IBAN)
+	if qrIBAN != (b.ReferenceType() == ReferenceTypeQR) {
+		if qrIBAN {
+			return fmt.Errorf("%w: a QR-IBAN requires a QR reference", ErrInvalidReference)
+		}
+		return fmt.Errorf("%w: a QR reference requires a QR-IBAN", ErrInvalidReference)
+	}
+
+	switch b.ReferenceType() {
+	case ReferenceTypeQR:
+		if !ValidQRReference(b.Reference) {
+			return ErrInvalidReference
+		}
+	case ReferenceTypeCreditor:
+		if !ValidCreditorReference(b.Reference) {
+			return ErrInvalidReference
+		}
+	}
+
+	return nil
+}
+
+func (b Bill) currency() string {
+	if b.Currency == "" {
+		return "CHF"
+	}
+	return b.Currency
+}
+
+func (b Bill) amountString() string {
+	if b.Amount == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d.%02d", b.Amount/100, b.Amount%100)
+}
+
+func addressLines(a *Address) []string {
+	if a == nil {
+		return []string{"", "", "", "", "", "", ""}
+	}
+	return []string{
+		"S",
+		truncate(a.Name, 70),
+		truncate(a.Street, 70),
+		truncate(a.BuildingNumber, 16),
+		truncate(a.PostalCode, 16),
+		truncate(a.Town, 35),
+		a.Country,
+	}
+}
+
+func validateAddress(a *Address) error {
+	if a.Name == "" || a.Town == "" || len(a.Country) != 2 {
+		return ErrInvalidAddress
+	}
+	return nil
+}
+
+// validateIBAN checks the format and the mod 97 checksum. Only Swiss and
+// Liechtenstein IBANs are allowed on a QR-bill.
+func validateIBAN(iban string) error {
+	iban = compact(iban)
+	if len(iban) != 21 {
+		return ErrInvalidIBAN
+	}
+	if !strings.HasPrefix(iban, "CH") && !strings.HasPrefix(iban, "LI") {
+		return ErrInvalidIBAN
+	}
+	if !mod97(iban[4:] + iban[:4]) {
+		return ErrInvalidIBAN
+	}
+	return nil
+}
+
+// IsQRIBAN reports whether the institution identification (IID) of the
+// IBAN is in the range 30000-31999 reserved for QR-IBANs.
+func IsQRIBAN(iban string) bool {
+	iban = compact(iban)
+	if len(iban) != 21 {
+		return false
+	}
+	iid := iban[4:9]
+	return iid >= "30000" && iid <= "31999"
+}
+
+// ValidQRReference reports whether ref is a 27 digit QR reference with a
+// valid recursive mod 10 check digit.
+func ValidQRReference(ref string) bool {
+	ref = compact(ref)
+	if len(ref) != 27 {
+		return false
+	}
+	for _, r := range ref {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return QRCheckDigit(ref[:26]) == ref[26]
+}
+
+// QRCheckDigit computes the check digit of a QR reference (recursive mod 10).
+func QRCheckDigit(digits string) byte {
+	table := [10]int{0, 9, 4, 6, 8, 2, 7, 1, 3, 5}
+	carry := 0
+	for i := 0; i < len(digits); i++ {
+		carry = table[(carry+int(digits[i]-'0'))%10]
+	}
+	return byte('0' + (10-carry)%10)
+}
+
+// ValidCreditorReference reports whether ref is a valid ISO 11649 creditor
+// reference, e.g. RF18539007547034.
+func ValidCreditorReference(ref string) bool {
+	ref = strings.ToUpper(compact(ref))
+	if len(ref) < 5 || len(ref) > 25 || !strings.HasPrefix(ref, "RF") {
+		return false
+	}
+	for _, r := range ref {
+		if !unicode.IsDigit(r) && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return mod97(ref[4:] + ref[:4])
+}
+
+// CreditorReference builds an ISO 11649 creditor reference out of base,
+// e.g. "0000000042" => "RF340000000042". The invoices_v2.reference column is
+// generated the same way in SQL.
+func CreditorReference(base string) string {
+	base = strings.ToUpper(compact(base))
+	return fmt.Sprintf("RF%02d%s", 98-mod97Remainder(base+"RF00"), base)
+}
+
+// QRReference builds a QR reference out of the digits of base, padded to 26
+// digits and followed by the check digit, e.g. "42" =>
+// "000000000000000000000000428".
+func QRReference(base string) string {
+	base = compact(base)
+	base = strings.Repeat("0", max(26-len(base), 0)) + base
+	return base + string(QRCheckDigit(base))
+}
+
+// ReferenceFor returns the reference to put on a bill to iban. A QR-IBAN
+// takes QR references only, so a creditor reference with digits, as of
+// CreditorReference, becomes the QR reference of the same digits.
+func ReferenceFor(iban, ref string) string {
+	ref = strings.ToUpper(compact(ref))
+	if !IsQRIBAN(iban) || !ValidCreditorReference(ref) {
+		return ref
+	}
+	base := ref[4:]
+	for _, r := range base {
+		if r < '0' || r > '9' {
+			return ref
+		}
+	}
+	return QRReference(base)
+}
+
+// CreditorReferenceFromQR undoes ReferenceFor for a QR reference of the
+// digits of a creditor reference with 10 digits, as invoices have. ok is
+// false for other references.
+func CreditorReferenceFromQR(ref string) (string, bool) {
+	ref = compact(ref)
+	if !ValidQRReference(ref) || strings.Trim(ref[:16], "0") != "" {
+		return "", false
+	}
+	return CreditorReference(ref[16:26]), true
+}
+
+// mod97 checks that the number mod 97 is 1, as done for both IBAN and
+// ISO 11649.
+func mod97(s string) bool {
+	return mod97Remainder(s) == 1
+}
+
+// mod97Remainder converts letters to numbers (A=10 ... Z=35) and returns the
+// result mod 97, or -1 if s contains other characters.
+func mod97Remainder(s string) int {
+	var digits strings.Builder
+	for _, r := range strings.ToUpper(s) {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r >= 'A' && r <= 'Z':
+			fmt.Fprintf(&digits, "%d", r-'A'+10)
+		default:
+			return -1
+		}
+	}
+
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+	if !ok {
+		return -1
+	}
+	return int(new(big.Int).Mod(n, big.NewInt(97)).Int64())
+}
+
+func compact(s string) string {
+	return strings.ReplaceAll(s, " ", "")
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+func startsWithDigit(s string) bool {
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
@@ -0,0 +1,164 @@
+package qrbill
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-pdf/fpdf"
+)
+
+func testBill() Bill {
+	return Bill{
+		IBAN:     "CH93 0076 2011 6238 5295 7",
+		Creditor: ParseAddress("Bellevue Amden", "Bellevue 1", "8873 Amden"),
+		Amount:   4850,
+		Message:  "Anna: Essen 45.00, Kiosk 3.50",
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	a := ParseAddress("Bellevue Amden", "Obere Strasse 12a", "8873 Amden SG")
+
+	if a.Street != "Obere Strasse" || a.BuildingNumber != "12a" {
+		t.Fatalf("unexpected street: %+v", a)
+	}
+	if a.PostalCode != "8873" || a.Town != "Amden SG" {
+		t.Fatalf("unexpected town: %+v", a)
+	}
+	if a.Country != "CH" {
+		t.Fatalf("expected country CH, got %s", a.Country)
+	}
+}
+
+func TestPayload(t *testing.T) {
+	payload, err := testBill().Payload()
+	if err != nil {
+		t.Fatalf("Payload(): %v", err)
+	}
+
+	lines := strings.Split(payload, "\n")
+	if len(lines) != 31 {
+		t.Fatalf("expected 31 lines, got %d", len(lines))
+	}
+
+	want := map[int]string{
+		0:  "SPC",
+		3:  "CH9300762011623852957",
+		4:  "S",
+		5:  "Bellevue Amden",
+		10: "CH",
+		18: "48.50",
+		19: "CHF",
+		27: "NON",
+		28: "",
+		29: "Anna: Essen 45.00, Kiosk 3.50",
+		30: "EPD",
+	}
+	for i, w := range want {
+		if lines[i] != w {
+			t.Errorf("line %d: expected %q, got %q", i, w, lines[i])
+		}
+	}
+}
+
+func TestPayloadInvalid(t *testing.T) {
+	b := testBill()
+	b.IBAN = "CH93 0076 2011 6238 5295 8"
+	if _, err := b.Payload(); err == nil {
+		t.Error("expected an error for an IBAN with a wrong checksum")
+	}
+
+	b = testBill()
+	b.Reference = "RF00539007547034"
+	if _, err := b.Payload(); err == nil {
+		t.Error("expected an error for an invalid creditor reference")
+	}
+}
+
+func TestPayloadQRIBAN(t *testing.T) {
+	const qrIBAN = "CH44 3199 9123 0008 8901 2"
+	const qrReference = "210000000003139471430009017"
+
+	tests := []struct {
+		name      string
+		iban      string
+		reference string
+		wantErr   bool
+	}{
+		{"QR-IBAN with QR reference", qrIBAN, qrReference, false},
+		{"QR-IBAN without reference", qrIBAN, "", true},
+		{"QR-IBAN with creditor reference", qrIBAN, "RF18539007547034", true},
+		{"IBAN with QR reference", "CH93 0076 2011 6238 5295 7", qrReference, true},
+		{"IBAN with creditor reference", "CH93 0076 2011 6238 5295 7", "RF18539007547034", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := testBill()
+			b.IBAN = tt.iban
+			b.Reference = tt.reference
+			_, err := b.Payload()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Payload(): expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestReferences(t *testing.T) {
+	if !ValidQRReference("21 00000 00003 13947 14300 09017") {
+		t.Error("expected valid QR reference")
+	}
+	if !ValidCreditorReference("RF18 5390 0754 7034") {
+		t.Error("expected valid creditor reference")
+	}
+	if got := CreditorReference("0000000042"); got != "RF340000000042" {
+		t.Errorf("unexpected creditor reference: %s", got)
+	}
+	if !ValidCreditorReference(CreditorReference("0000000001")) {
+		t.Error("expected generated creditor reference to be valid")
+	}
+	if got := FormatReference("210000000003139471430009017"); got != "21 00000 00003 13947 14300 09017" {
+		t.Errorf("unexpected formatting: %s", got)
+	}
+}
+
+func TestReferenceFor(t *testing.T) {
+	const qrIBAN = "CH44 3199 9123 0008 8901 2"
+
+	ref := ReferenceFor(qrIBAN, "RF340000000042")
+	if !ValidQRReference(ref) || !strings.HasPrefix(ref, "00000000000000000000000042") {
+		t.Errorf("unexpected QR reference %s", ref)
+	}
+	if got, ok := CreditorReferenceFromQR(ref); !ok || got != "RF340000000042" {
+		t.Errorf("CreditorReferenceFromQR(%s) = %s, %v", ref, got, ok)
+	}
+
+	if got := ReferenceFor("CH93 0076 2011 6238 5295 7", "RF340000000042"); got != "RF340000000042" {
+		t.Errorf("expected the creditor reference for an IBAN, got %s", got)
+	}
+	if _, ok := CreditorReferenceFromQR("210000000003139471430009017"); ok {
+		t.Error("expected no creditor reference for a QR reference of other digits")
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	for in, want := range map[int]string{
+		5:       "0.05",
+		4850:    "48.50",
+		123450:  "1 234.50",
+		1000000: "10 000.00",
+	} {
+		if got := FormatAmount(in); got != want {
+			t.Errorf("FormatAmount(%d): expected %s, got %s", in, want, got)
+		}
+	}
+}
+
+func TestDraw(t *testing.T) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	if err := testBill().Draw(pdf); err != nil {
+		t.Fatalf("Draw(): %v", err)
+	}
+}
@@ -0,0 +1,282 @@
+// Package reconcile imports bank statements and matches incoming payments to
+// open invoices. Matched entries are booked as payments of the member and
+// allocated to their open invoices, everything else ends up in the review
+// queue under /settings/bank-transactions.
+package reconcile
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/davidkuda/bellevue/internal/camt"
+	"github.com/davidkuda/bellevue/internal/models"
+	"github.com/davidkuda/bellevue/internal/qrbill"
+)
+
+type Importer struct {
+	DB     *sql.DB
+	Models models.Models
+	// IBAN is the account that members pay to (RECIPIENT_IBAN). Statements
+	// of other accounts are rejected.
+	IBAN string
+}
+
+type Result struct {
+	Imported   int // new credit entries
+	Duplicates int // entries that were imported before
+	Skipped    int // debit entries and foreign currencies
+	Matched    int
+	Unmatched  int
+}
+
+// Import parses a camt.053 or camt.054 file and stores all new credit entries
+// in one transaction. Entries that can be matched unambiguously are booked as
+// payments, see pay.
+func (im Importer) Import(r io.Reader) (Result, error) {
+	var res Result
+
+	statements, err := camt.Parse(r)
+	if err != nil {
+		return res, err
+	}
+
+	for _, s := range statements {
+		if !sameIBAN(s.IBAN, im.IBAN) {
+			return res, fmt.Errorf("statement is for account %s, expected %s", s.IBAN, im.IBAN)
+		}
+	}
+
+	open, err := im.Models.InvoicesV2.GetOpen()
+	if err != nil {
+		return res, fmt.Errorf("could not get open invoices: %v", err)
+	}
+
+	tx, err := im.DB.Begin()
+	if err != nil {
+		return res, fmt.Errorf("failed starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, s := range statements {
+		for _, e := range s.Entries {
+			if !e.Credit || e.Currency != "CHF" {
+				res.Skipped++
+				continue
+			}
+
+			id, ok, err := im.Models.BankTransactions.InsertTx(models.BankTransaction{
+				IBAN:        s.IBAN,
+				Reference:   e.Reference,
+				BookingDate: e.BookingDate,
+				Amount:      e.Amount,
+				Currency:    e.Currency,
+				DebtorName:  e.DebtorName,
+				CreditorRef: e.CreditorReference,
+				Message:     e.Message,
+			}, tx)
+			if err != nil {
+				return res, err
+			}
+			if !ok {
+				res.Duplicates++
+				continue
+			}
+			res.Imported++
+
+			invoice, ok := Match(e, open)
+			if !ok {
+				res.Unmatched++
+				continue
+			}
+
+			paid, err := im.pay(id, e, invoice, 0, tx)
+			if err != nil {
+				return res, err
+			}
+			open = slices.DeleteFunc(open, func(i models.OpenInvoice) bool {
+				return slices.Contains(paid, i.ID)
+			})
+			res.Matched++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return res, fmt.Errorf("failed committing transaction: %v", err)
+	}
+
+	return res, nil
+}
+
+// MatchManually is used by admins to resolve entries in the review queue.
+func (im Importer) MatchManually(transactionID, invoiceID, adminID int) error {
+	t, err := im.Models.BankTransactions.Get(transactionID)
+	if err != nil {
+		return err
+	}
+	invoice, err := im.Models.InvoicesV2.Get(invoiceID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := im.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	e := camt.Entry{
+		Amount:      t.Amount,
+		BookingDate: t.BookingDate,
+	}
+	open := models.OpenInvoice{
+		ID:     invoice.ID,
+		UserID: invoice.UserID,
+	}
+	if _, err := im.pay(transactionID, e, open, adminID, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// pay links the bank transaction to the invoice and books it as payment of
+// the member. The payment is allocated to the open invoices of the member,
+// oldest first, so it may pay more than one invoice or only part of one.
+// It returns the invoices that are paid in full now.
+func (im Importer) pay(transactionID int, e camt.Entry, invoice models.OpenInvoice, adminID int, tx *sql.Tx) ([]int, error) {
+	if err := im.Models.BankTransactions.MatchTx(transactionID, invoice.ID, adminID, tx); err != nil {
+		return nil, err
+	}
+
+	_, err := im.Models.Payments.InsertTx(models.Payment{
+		UserID:            invoice.UserID,
+		Amount:            e.Amount,
+		BookingDate:       e.BookingDate,
+		Method:            models.PaymentMethodBank,
+		BankTransactionID: sql.NullInt32{Int32: int32(transactionID), Valid: true},
+		CreatedBy:         sql.NullInt32{Int32: int32(adminID), Valid: adminID != 0},
+	}, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	paid, err := im.Models.Payments.AllocateTx(invoice.UserID, tx)
+	if err != nil {
+		return nil, fmt.Errorf("could not allocate payment of user %d: %v", invoice.UserID, err)
+	}
+
+	return paid, nil
+}
+
+// Match returns the open invoice that e pays for. The payment is booked for
+// the member of that invoice, so it does not have to match the amount.
+//
+// If the payment carries one of our creditor references, either structured
+// or typed into the message, the reference decides alone. A reference of an
+// invoice that is not open is left for an admin. Without a reference the
+// name of the member has to match, plus either the amount of one invoice or
+// the sum of all open invoices of the member. If more than one invoice
+// qualifies, nothing is matched and an admin has to decide.
+func Match(e camt.Entry, open []models.OpenInvoice) (models.OpenInvoice, bool) {
+	if ref := entryReference(e); ref != "" {
+		for _, invoice := range open {
+			if invoice.Reference == ref {
+				return invoice, true
+			}
+		}
+		return models.OpenInvoice{}, false
+	}
+
+	var candidates []models.OpenInvoice
+	sums := make(map[int]int)
+	for _, invoice := range open {
+		if !nameMatches(e.DebtorName, invoice.FirstName, invoice.LastName) {
+			continue
+		}
+		sums[invoice.UserID] += invoice.TotalPrice
+		if invoice.TotalPrice == e.Amount {
+			candidates = append(candidates, invoice)
+		}
+	}
+
+	if len(candidates) == 0 {
+		// one transfer for all open invoices of a member. open is ordered
+		// oldest first.
+		for _, invoice := range open {
+			if sums[invoice.UserID] == e.Amount && nameMatches(e.DebtorName, invoice.FirstName, invoice.LastName) {
+				candidates = append(candidates, invoice)
+				break
+			}
+		}
+	}
+
+	if len(candidates) != 1 {
+		return models.OpenInvoice{}, false
+	}
+
+	return candidates[0], true
+}
+
+// referenceInMessage finds references like RF340000000042 that members copy
+// into the message, with or without spaces.
+var referenceInMessage = regexp.MustCompile(`RF\d{12}`)
+
+// entryReference returns the creditor reference of e without spaces, or ""
+// if there is none. A QR reference is turned back into the creditor
+// reference of the invoice.
+func entryReference(e camt.Entry) string {
+	if ref := strings.ToUpper(strings.ReplaceAll(e.CreditorReference, " ", "")); qrbill.ValidCreditorReference(ref) {
+		return ref
+	}
+	// the QR reference of a bill to a QR-IBAN, see qrbill.ReferenceFor.
+	if ref, ok := qrbill.CreditorReferenceFromQR(e.CreditorReference); ok {
+		return ref
+	}
+
+	message := strings.ToUpper(strings.ReplaceAll(e.Message, " ", ""))
+	for _, ref := range referenceInMessage.FindAllString(message, -1) {
+		if qrbill.ValidCreditorReference(ref) {
+			return ref
+		}
+	}
+
+	return ""
+}
+
+// nameMatches reports whether both first and last name appear in the name of
+// the debtor. Banks often write "MUELLER ANNA" or drop the umlauts.
+func nameMatches(debtor, firstName, lastName string) bool {
+	if debtor == "" || firstName == "" || lastName == "" {
+		return false
+	}
+
+	words := strings.Fields(normalize(debtor))
+	has := func(name string) bool {
+		for _, part := range strings.Fields(normalize(name)) {
+			if !slices.Contains(words, part) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return has(firstName) && has(lastName)
+}
+
+var replacer = strings.NewReplacer(
+	"ä", "ae", "ö", "oe", "ü", "ue",
+	"é", "e", "è", "e", "ê", "e", "à", "a", "â", "a", "ç", "c",
+	"-", " ", ",", " ", ".", " ",
+)
+
+func normalize(s string) string {
+	return replacer.Replace(strings.ToLower(s))
+}
+
+func sameIBAN(a, b string) bool {
+	return strings.EqualFold(strings.ReplaceAll(a, " ", ""), strings.ReplaceAll(b, " ", ""))
+}
@@ -0,0 +1,52 @@
+package reconcile
+
+import (
+	"testing"
+
+	"github.com/davidkuda/bellevue/internal/camt"
+	"github.com/davidkuda/bellevue/internal/models"
+	"github.com/davidkuda/bellevue/internal/qrbill"
+)
+
+var open = []models.OpenInvoice{
+	{ID: 1, UserID: 1, Reference: "RF740000000001", FirstName: "Anna", LastName: "Müller", TotalPrice: 4850},
+	{ID: 2, UserID: 2, Reference: "RF470000000002", FirstName: "Beat", LastName: "Keller", TotalPrice: 4850},
+	{ID: 3, UserID: 3, Reference: "RF200000000003", FirstName: "Clara", LastName: "Frei", TotalPrice: 4000},
+	{ID: 4, UserID: 3, Reference: "RF900000000004", FirstName: "Clara", LastName: "Frei", TotalPrice: 4000},
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry camt.Entry
+		want  int // 0: no match
+	}{
+		{"amount and name", camt.Entry{Amount: 4850, DebtorName: "MUELLER ANNA"}, 1},
+		{"other member same amount", camt.Entry{Amount: 4850, DebtorName: "Beat Keller-Meier"}, 2},
+		{"wrong amount", camt.Entry{Amount: 4800, DebtorName: "Anna Müller"}, 0},
+		{"unknown name", camt.Entry{Amount: 4850, DebtorName: "Daniel Weber"}, 0},
+		{"ambiguous", camt.Entry{Amount: 4000, DebtorName: "Clara Frei"}, 0},
+		{"no name", camt.Entry{Amount: 4850}, 0},
+		{"reference", camt.Entry{Amount: 4000, CreditorReference: "RF200000000003"}, 3},
+		{"reference beats name", camt.Entry{Amount: 4850, DebtorName: "Anna Müller", CreditorReference: "RF470000000002"}, 2},
+		{"reference in message", camt.Entry{Amount: 4000, Message: "Rechnung rf90 0000 0000 04 danke"}, 4},
+		{"QR reference of a QR-IBAN", camt.Entry{Amount: 4000, CreditorReference: qrbill.ReferenceFor("CH44 3199 9123 0008 8901 2", "RF200000000003")}, 3},
+		{"reference partial payment", camt.Entry{Amount: 3900, CreditorReference: "RF200000000003"}, 3},
+		{"all open invoices of a member", camt.Entry{Amount: 8000, DebtorName: "FREI CLARA"}, 3},
+		{"sum of another member", camt.Entry{Amount: 8000, DebtorName: "Anna Müller"}, 0},
+		{"reference not open", camt.Entry{Amount: 4850, DebtorName: "Anna Müller", CreditorReference: "RF630000000005"}, 0},
+	}
+
+	for _, tt := range tests {
+		invoice, ok := Match(tt.entry, open)
+		if tt.want == 0 {
+			if ok {
+				t.Errorf("%s: expected no match, got invoice %d", tt.name, invoice.ID)
+			}
+			continue
+		}
+		if !ok || invoice.ID != tt.want {
+			t.Errorf("%s: expected invoice %d, got %d (ok=%v)", tt.name, tt.want, invoice.ID, ok)
+		}
+	}
+}
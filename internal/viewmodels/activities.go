@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -12,16 +14,78 @@ type ActivityViewModel struct {
 	DB *sql.DB
 }
 
+// querier is either a *sql.DB or a *sql.Tx, for reads that must see the
+// uncommitted changes of a transaction.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
 type Invoice struct {
 	ID         int
 	Sent       bool
 	Status     string
+	Number     string
+	Reference  string
 	Date       time.Time
 	MinDate    time.Time
 	MaxDate    time.Time
 	Activities []Activity
 	TotalPrice int
 	Categories []Category
+	Taxes      []TaxRate
+
+	// Members groups the activities by member. Household invoices have more
+	// than one, see HasMembers.
+	Members []InvoiceMember
+
+	// BilledTo is the name of the billing contact of the household who gets
+	// the open activities invoiced, "" if it is the user.
+	BilledTo string
+}
+
+// InvoiceMember are the activities of one member on an invoice.
+type InvoiceMember struct {
+	UserID     int
+	Name       string
+	Activities []Activity
+	TotalPrice int
+}
+
+// HasMembers reports whether the invoice covers the activities of more than
+// one member, i.e. it is a household invoice.
+func (i *Invoice) HasMembers() bool {
+	return len(i.Members) > 1
+}
+
+// groupByMember groups the activities by member, the member userID first and
+// the others by name. The activities keep their order.
+func groupByMember(activities []Activity, userID int) []InvoiceMember {
+	var members []InvoiceMember
+	index := map[int]int{}
+	for _, a := range activities {
+		i, ok := index[a.UserID]
+		if !ok {
+			i = len(members)
+			index[a.UserID] = i
+			members = append(members, InvoiceMember{UserID: a.UserID, Name: a.Member})
+		}
+		members[i].Activities = append(members[i].Activities, a)
+		members[i].TotalPrice += a.TotalPrice
+	}
+
+	slices.SortStableFunc(members, func(a, b InvoiceMember) int {
+		switch {
+		case a.UserID == b.UserID:
+			return 0
+		case a.UserID == userID:
+			return -1
+		case b.UserID == userID:
+			return 1
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return members
 }
 
 type UninvoicedActivities struct {
@@ -30,11 +94,16 @@ type UninvoicedActivities struct {
 }
 
 type Activity struct {
-	ID           int
-	Date         time.Time
-	Consumptions []Consumption
-	TotalPrice   int
-	Comment      string
+	ID            int
+	UserID        int
+	Member        string // first and last name of the user
+	EnteredBy     int    // the user, or someone who entered it on their behalf
+	EnteredByName string
+	GuestName     string // of a visitor billed to the user, if any
+	Date          time.Time
+	Consumptions  []Consumption
+	TotalPrice    int
+	Comment       string
 }
 
 type Consumption struct {
@@ -44,20 +113,27 @@ type Consumption struct {
 	Quantity      int
 	UnitPrice     int
 	TotalPrice    int
+	TaxRate       int // 8.1% => 810
 }
 
 // intermediate representation of query results
 type activityConsumptions []activityConsumption
 type activityConsumption struct {
-	activityID   int
-	date         time.Time
-	comment      string
-	productCode  string
-	productName  string
-	pricecatName string
-	quantity     int
-	unit_price   int
-	total_price  int
+	activityID    int
+	date          time.Time
+	comment       string
+	productCode   string
+	productName   string
+	pricecatName  string
+	quantity      int
+	unit_price    int
+	total_price   int
+	taxRate       int
+	userID        int
+	memberName    string
+	enteredBy     int
+	enteredByName string
+	guestName     string
 }
 
 func (m *ActivityViewModel) GetUninvoicedActivitiesForUser(userID int) (*Invoice, error) {
@@ -85,6 +161,7 @@ func (m *ActivityViewModel) GetUninvoicedActivitiesForUser(userID int) (*Invoice
 		Activities: activities,
 	}
 	uninvoicedActivities.MinDate, uninvoicedActivities.MaxDate = activityDateRange(activities)
+	uninvoicedActivities.Taxes = acs.taxBreakdown()
 
 	cats, err := m.GetUninvoicedCategoriesForUser(userID)
 	if err != nil {
@@ -97,14 +174,40 @@ func (m *ActivityViewModel) GetUninvoicedActivitiesForUser(userID int) (*Invoice
 	return &uninvoicedActivities, nil
 }
 
+// GetActivitiesEnteredForOthers returns the open activities that the user
+// entered on behalf of other members, nil if there are none. They are on the
+// invoices of those members.
+func (m *ActivityViewModel) GetActivitiesEnteredForOthers(userID int) (*Invoice, error) {
+	acs, err := m.getActivityConsumptionsEnteredForOthers(userID)
+	if err != nil {
+		return nil, fmt.Errorf("m.getActivityConsumptionsEnteredForOthers(%d): %s", userID, err)
+	}
+
+	if len(acs) == 0 {
+		return nil, nil
+	}
+
+	activities := acs.toViewModel()
+
+	entered := Invoice{Activities: activities}
+	for i := range activities {
+		entered.TotalPrice += activities[i].TotalPrice
+	}
+	entered.MinDate, entered.MaxDate = activityDateRange(activities)
+
+	return &entered, nil
+}
+
 func (m *ActivityViewModel) GetAllInvoicesForUser(userID int) ([]*Invoice, error) {
 	type inv struct {
-		id     int
-		status string
-		date   time.Time
+		id        int
+		status    string
+		number    string
+		reference string
+		date      time.Time
 	}
 	stmt := `
-	select id, status, created_at
+	select id, status, coalesce(number, ''), reference, created_at
 	from invoices_v2
 	where user_id = $1
 	order by created_at desc;`
@@ -118,7 +221,7 @@ func (m *ActivityViewModel) GetAllInvoicesForUser(userID int) ([]*Invoice, error
 
 	for rows.Next() {
 		var in inv
-		err = rows.Scan(&in.id, &in.status, &in.date)
+		err = rows.Scan(&in.id, &in.status, &in.number, &in.reference, &in.date)
 		if err != nil {
 			return nil, fmt.Errorf("for rows.Next(): %v", err)
 		}
@@ -133,6 +236,8 @@ func (m *ActivityViewModel) GetAllInvoicesForUser(userID int) ([]*Invoice, error
 		}
 		invoice.Date = in.date
 		invoice.Status = in.status
+		invoice.Number = in.number
+		invoice.Reference = in.reference
 		sentInvoices = append(sentInvoices, invoice)
 	}
 
@@ -140,7 +245,17 @@ func (m *ActivityViewModel) GetAllInvoicesForUser(userID int) ([]*Invoice, error
 }
 
 func (m *ActivityViewModel) GetInvoiceForUser(invoiceID, userID int) (*Invoice, error) {
-	acs, err := m.getActivityConsumptionsByInvoiceForUser(invoiceID, userID)
+	return m.getInvoiceForUser(m.DB, invoiceID, userID)
+}
+
+// GetInvoiceForUserTx reads the invoice within tx, e.g. to preview an invoice
+// that is rolled back afterwards.
+func (m *ActivityViewModel) GetInvoiceForUserTx(invoiceID, userID int, tx *sql.Tx) (*Invoice, error) {
+	return m.getInvoiceForUser(tx, invoiceID, userID)
+}
+
+func (m *ActivityViewModel) getInvoiceForUser(q querier, invoiceID, userID int) (*Invoice, error) {
+	acs, err := m.getActivityConsumptionsByInvoiceForUser(q, invoiceID, userID)
 	if err != nil {
 		return nil, fmt.Errorf("could not get activityConsumptions userID=%d: %s", userID, err)
 	}
@@ -161,12 +276,14 @@ func (m *ActivityViewModel) GetInvoiceForUser(invoiceID, userID int) (*Invoice,
 		Activities: activities,
 	}
 	invoice.MinDate, invoice.MaxDate = activityDateRange(activities)
+	invoice.Taxes = acs.taxBreakdown()
 
-	cats, err := m.GetCategoriesByInvoiceIDForUser(invoiceID, userID)
+	cats, err := m.getCategoriesByInvoiceIDForUser(q, invoiceID, userID)
 	if err != nil {
 		return nil, fmt.Errorf("could not get uninvoiced categories for user: %v", err)
 	}
 	invoice.Categories = cats
+	invoice.Members = groupByMember(activities, userID)
 
 	invoice.ID = invoiceID
 	invoice.Sent = true
@@ -193,6 +310,56 @@ func (m *ActivityViewModel) GetActivityByIDForUser(activityID, userID int) (*Act
 	return &activities[0], nil
 }
 
+// GetLatestActivityForUser returns the last activity of the user before the
+// day before, e.g. to copy it. nil if there is none.
+func (m *ActivityViewModel) GetLatestActivityForUser(userID int, before time.Time) (*Activity, error) {
+	stmt := activityConsumptionsSelect + `
+	    WHERE a.id = (
+	          SELECT id
+	            FROM activities
+	           WHERE user_id = $1
+	             AND date < $2
+	        ORDER BY date DESC, created_at DESC
+	           LIMIT 1
+	          )
+	;
+	`
+
+	acs, err := queryActivityConsumptions(m.DB, stmt, userID, before)
+	if err != nil {
+		return nil, fmt.Errorf("could not get latest activity of userID=%d: %v", userID, err)
+	}
+
+	if len(acs) == 0 {
+		return nil, nil
+	}
+
+	return &acs.toViewModel()[0], nil
+}
+
+// GetDailyActivities returns the activity of each member on date that the
+// daily grid of staff edits, by user ID: the first open one without a guest.
+func (m *ActivityViewModel) GetDailyActivities(date time.Time) (map[int]*Activity, error) {
+	acs, err := m.getDailyActivityConsumptions(date)
+	if err != nil {
+		return nil, fmt.Errorf("m.getDailyActivityConsumptions(%s): %s", date.Format("2006-01-02"), err)
+	}
+
+	daily := map[int]*Activity{}
+	if len(acs) == 0 {
+		return daily, nil
+	}
+
+	activities := acs.toViewModel()
+	for i := range activities {
+		if _, ok := daily[activities[i].UserID]; !ok {
+			daily[activities[i].UserID] = &activities[i]
+		}
+	}
+
+	return daily, nil
+}
+
 func activityDateRange(activities []Activity) (time.Time, time.Time) {
 	if len(activities) == 0 {
 		return time.Time{}, time.Time{}
@@ -213,10 +380,12 @@ func activityDateRange(activities []Activity) (time.Time, time.Time) {
 	return minDate, maxDate
 }
 
-func (m *ActivityViewModel) getUninvoicedActivityConsumptionsForUser(userID int) (activityConsumptions, error) {
-	// NOTE: case when ... would be redundant if price_categories had a category "free_amount"
-	// product.price_category_id can be null...
-	stmt := `
+// activityConsumptionsSelect selects the consumptions with their activity,
+// product and price category. The callers add the WHERE and ORDER BY.
+//
+// NOTE: case when ... would be redundant if price_categories had a category "free_amount"
+// product.price_category_id can be null...
+const activityConsumptionsSelect = `
 	   SELECT a.id,
 	          a.date,
 	          coalesce(a.comment, ''),
@@ -228,153 +397,92 @@ func (m *ActivityViewModel) getUninvoicedActivityConsumptionsForUser(userID int)
 	          end as pricecat_name,
 	          c.quantity,
 	          c.unit_price,
-	          c.total_price
+	          c.total_price,
+	          c.tax_rate,
+	          a.user_id,
+	          u.first_name || ' ' || u.last_name,
+	          a.entered_by,
+	          e.first_name || ' ' || e.last_name,
+	          coalesce(a.guest_name, '')
 	     FROM consumptions c
 	LEFT JOIN activities a
 	       ON a.id = c.activity_id
+	LEFT JOIN users u
+	       ON u.id = a.user_id
+	LEFT JOIN users e
+	       ON e.id = a.entered_by
 	LEFT JOIN products p
 	       ON p.id = c.product_id
 	LEFT JOIN price_categories pc
 	       ON pc.id = p.price_category_id
+`
+
+func (m *ActivityViewModel) getUninvoicedActivityConsumptionsForUser(userID int) (activityConsumptions, error) {
+	stmt := activityConsumptionsSelect + `
 	    WHERE a.invoice_id is null
-	      AND user_id = $1
+	      AND a.user_id = $1
 	 ORDER BY a.date DESC, a.created_at DESC
 	;
 	`
 
-	rows, err := m.DB.Query(stmt, userID)
-	if err != nil {
-		return nil, fmt.Errorf("DB.Query(stmt): %v", err)
-	}
-
-	defer rows.Close()
-
-	var res []activityConsumption
-
-	for rows.Next() {
-		var r activityConsumption
-		err = rows.Scan(
-			&r.activityID,
-			&r.date,
-			&r.comment,
-			&r.productCode,
-			&r.productName,
-			&r.pricecatName,
-			&r.quantity,
-			&r.unit_price,
-			&r.total_price,
-		)
+	return queryActivityConsumptions(m.DB, stmt, userID)
+}
 
-		if err != nil {
-			return nil, fmt.Errorf("for rows.Next(): %v", err)
-		}
+// getActivityConsumptionsEnteredForOthers returns the open consumptions that
+// the user entered on behalf of someone else.
+func (m *ActivityViewModel) getActivityConsumptionsEnteredForOthers(userID int) (activityConsumptions, error) {
+	stmt := activityConsumptionsSelect + `
+	    WHERE a.invoice_id is null
+	      AND a.entered_by = $1
+	      AND a.user_id <> $1
+	 ORDER BY a.date DESC, a.created_at DESC
+	;
+	`
 
-		res = append(res, r)
-	}
+	return queryActivityConsumptions(m.DB, stmt, userID)
+}
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows.Err(): %v", err)
-	}
+func (m *ActivityViewModel) getDailyActivityConsumptions(date time.Time) (activityConsumptions, error) {
+	stmt := activityConsumptionsSelect + `
+	    WHERE a.invoice_id is null
+	      AND a.date = $1
+	      AND a.guest_name is null
+	 ORDER BY a.user_id, a.id
+	;
+	`
 
-	return res, nil
+	return queryActivityConsumptions(m.DB, stmt, date)
 }
 
-func (m *ActivityViewModel) getActivityConsumptionsByInvoiceForUser(invoiceID, userID int) (activityConsumptions, error) {
-	// NOTE: case when ... would be redundant if price_categories had a category "free_amount"
-	// product.price_category_id can be null...
-	stmt := `
-	   SELECT a.id,
-	          a.date,
-	          coalesce(a.comment, ''),
-	          p.code as product_code,
-	          p.name as product_name,
-	          case
-	             when p.pricing_mode = 'custom' then 'free_amount'
-	             else pc.name
-	          end as pricecat_name,
-	          c.quantity,
-	          c.unit_price,
-	          c.total_price
-	     FROM consumptions c
-	LEFT JOIN activities a
-	       ON a.id = c.activity_id
-	LEFT JOIN products p
-	       ON p.id = c.product_id
-	LEFT JOIN price_categories pc
-	       ON pc.id = p.price_category_id
+// getActivityConsumptionsByInvoiceForUser returns the consumptions of the
+// invoice of the user. For a household invoice, they include those of the
+// other members.
+func (m *ActivityViewModel) getActivityConsumptionsByInvoiceForUser(q querier, invoiceID, userID int) (activityConsumptions, error) {
+	stmt := activityConsumptionsSelect + `
+	     JOIN invoices_v2 i
+	       ON i.id = a.invoice_id
 	    WHERE a.invoice_id = $1
-	      AND user_id = $2
+	      AND i.user_id = $2
 	 ORDER BY a.date DESC, a.created_at DESC
 	;
 	`
 
-	rows, err := m.DB.Query(stmt, invoiceID, userID)
-	if err != nil {
-		return nil, fmt.Errorf("DB.Query(stmt): %v", err)
-	}
-
-	defer rows.Close()
-
-	var res []activityConsumption
-
-	for rows.Next() {
-		var r activityConsumption
-		err = rows.Scan(
-			&r.activityID,
-			&r.date,
-			&r.comment,
-			&r.productCode,
-			&r.productName,
-			&r.pricecatName,
-			&r.quantity,
-			&r.unit_price,
-			&r.total_price,
-		)
-
-		if err != nil {
-			return nil, fmt.Errorf("for rows.Next(): %v", err)
-		}
-
-		res = append(res, r)
-	}
-
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows.Err(): %v", err)
-	}
-
-	return res, nil
+	return queryActivityConsumptions(q, stmt, invoiceID, userID)
 }
 
 func (m *ActivityViewModel) getActivityByIDForUser(activityID int, userID int) (activityConsumptions, error) {
-	// TODO: the only difference in this fn to the previous is the WHERE statment (and the parameters / signature)
-	// it feels kinda verbose to keep two such big functions...
-	stmt := `
-	   SELECT a.id,
-	          a.date,
-	          coalesce(a.comment, ''),
-	          p.code as product_code,
-	          p.name as product_name,
-	          case
-	             when p.pricing_mode = 'custom' then 'free_amount'
-	             else pc.name
-	          end as pricecat_name,
-	          c.quantity,
-	          c.unit_price,
-	          c.total_price
-	     FROM consumptions c
-	LEFT JOIN activities a
-	       ON a.id = c.activity_id
-	LEFT JOIN products p
-	       ON p.id = c.product_id
-	LEFT JOIN price_categories pc
-	       ON pc.id = p.price_category_id
+	stmt := activityConsumptionsSelect + `
 	    WHERE a.id = $1
-	      AND user_id = $2
+	      AND a.user_id = $2
 	 ORDER BY a.date DESC, a.id
 	;
 	`
 
-	rows, err := m.DB.Query(stmt, activityID, userID)
+	return queryActivityConsumptions(m.DB, stmt, activityID, userID)
+}
+
+func queryActivityConsumptions(q querier, stmt string, args ...any) (activityConsumptions, error) {
+	rows, err := q.Query(stmt, args...)
 	if err != nil {
 		return nil, fmt.Errorf("DB.Query(stmt): %v", err)
 	}
@@ -395,6 +503,12 @@ func (m *ActivityViewModel) getActivityByIDForUser(activityID int, userID int) (
 			&r.quantity,
 			&r.unit_price,
 			&r.total_price,
+			&r.taxRate,
+			&r.userID,
+			&r.memberName,
+			&r.enteredBy,
+			&r.enteredByName,
+			&r.guestName,
 		)
 
 		if err != nil {
@@ -416,11 +530,16 @@ func (acs activityConsumptions) toViewModel() []Activity {
 
 	groupID := acs[0].activityID
 	activity := Activity{
-		ID:           acs[0].activityID,
-		Date:         acs[0].date,
-		Consumptions: make([]Consumption, 0),
-		TotalPrice:   0,
-		Comment:      acs[0].comment,
+		ID:            acs[0].activityID,
+		UserID:        acs[0].userID,
+		Member:        acs[0].memberName,
+		EnteredBy:     acs[0].enteredBy,
+		EnteredByName: acs[0].enteredByName,
+		GuestName:     acs[0].guestName,
+		Date:          acs[0].date,
+		Consumptions:  make([]Consumption, 0),
+		TotalPrice:    0,
+		Comment:       acs[0].comment,
 	}
 
 	for _, ac := range acs {
@@ -429,11 +548,16 @@ func (acs activityConsumptions) toViewModel() []Activity {
 
 			groupID = ac.activityID
 			activity = Activity{
-				ID:           ac.activityID,
-				Date:         ac.date,
-				Consumptions: make([]Consumption, 0),
-				TotalPrice:   0,
-				Comment:      ac.comment,
+				ID:            ac.activityID,
+				UserID:        ac.userID,
+				Member:        ac.memberName,
+				EnteredBy:     ac.enteredBy,
+				EnteredByName: ac.enteredByName,
+				GuestName:     ac.guestName,
+				Date:          ac.date,
+				Consumptions:  make([]Consumption, 0),
+				TotalPrice:    0,
+				Comment:       ac.comment,
 			}
 		}
 
@@ -444,6 +568,7 @@ func (acs activityConsumptions) toViewModel() []Activity {
 			Quantity:      ac.quantity,
 			UnitPrice:     ac.unit_price,
 			TotalPrice:    ac.total_price,
+			TaxRate:       ac.taxRate,
 		}
 
 		activity.TotalPrice += consumption.TotalPrice
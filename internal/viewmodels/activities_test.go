@@ -1,6 +1,9 @@
 package viewmodels
 
 import (
+	"fmt"
+	"os"
+	"slices"
 	"testing"
 
 	"github.com/davidkuda/bellevue/internal/envcfg"
@@ -9,6 +12,11 @@ import (
 
 // this test needs a database connection and at least one uninvoiced activity
 func TestGetUninvoicedActivitiesForUser(t *testing.T) {
+	// envcfg.DB exits without the env vars, with it all tests of the package.
+	if os.Getenv("DB_NAME") == "" {
+		t.Skip("DB_NAME not set")
+	}
+
 	db, err := envcfg.DB()
 	if err != nil {
 		t.Fatalf("could not open DB: %v\n", err)
@@ -39,3 +47,28 @@ func TestGetUninvoicedActivitiesForUser(t *testing.T) {
 	}
 	t.Log(invoice)
 }
+
+func TestGroupByMember(t *testing.T) {
+	activities := []Activity{
+		{ID: 1, UserID: 3, Member: "Clara", TotalPrice: 100},
+		{ID: 2, UserID: 2, Member: "Ben", TotalPrice: 200},
+		{ID: 3, UserID: 1, Member: "Anna", TotalPrice: 300},
+		{ID: 4, UserID: 3, Member: "Clara", TotalPrice: 400},
+	}
+
+	members := groupByMember(activities, 3)
+
+	var got []string
+	for _, m := range members {
+		var ids []int
+		for _, a := range m.Activities {
+			ids = append(ids, a.ID)
+		}
+		got = append(got, fmt.Sprintf("%s %v %d", m.Name, ids, m.TotalPrice))
+	}
+
+	want := []string{"Clara [1 4] 500", "Anna [3] 300", "Ben [2] 200"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -60,6 +60,10 @@ func (m *ActivityViewModel) GetUninvoicedCategoriesForUser(userID int) ([]Catego
 }
 
 func (m *ActivityViewModel) GetCategoriesByInvoiceIDForUser(invoiceID, userID int) ([]Category, error) {
+	return m.getCategoriesByInvoiceIDForUser(m.DB, invoiceID, userID)
+}
+
+func (m *ActivityViewModel) getCategoriesByInvoiceIDForUser(q querier, invoiceID, userID int) ([]Category, error) {
 	stmt := `
 	  SELECT fa.view_name,
 	         sum(total_price) AS total_price
@@ -70,14 +74,16 @@ func (m *ActivityViewModel) GetCategoriesByInvoiceIDForUser(invoiceID, userID in
 	      ON c.product_id = p.id
 	    JOIN financial_accounts fa
 	      ON p.financial_account_id = fa.id
+	    JOIN invoices_v2 i
+	      ON i.id = a.invoice_id
 	   WHERE a.invoice_id = $1
-	     AND a.user_id = $2
+	     AND i.user_id = $2
 	GROUP BY fa.view_name
 	ORDER BY total_price DESC
 	;
 	`
 
-	rows, err := m.DB.Query(stmt, invoiceID, userID)
+	rows, err := q.Query(stmt, invoiceID, userID)
 	if err != nil {
 		return nil, fmt.Errorf("DB.Query(stmt): %v", err)
 	}
@@ -0,0 +1,102 @@
+package viewmodels
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type InvoiceViewModel struct {
+	DB *sql.DB
+}
+
+// AdminInvoice is one row in the invoice overview for admins.
+type AdminInvoice struct {
+	ID          int
+	Number      string
+	UserID      int
+	UserName    string
+	Email       string
+	Status      string
+	TotalPrice  int
+	CreatedAt   time.Time
+	SentAt      sql.NullTime
+	PaidAt      sql.NullTime
+	CancelledAt sql.NullTime
+	// CancellationReason and CreditNote are set for cancelled invoices.
+	CancellationReason string
+	CreditNote         string
+	NextStatuses       []string
+	Cancellable        bool
+}
+
+// GetAll returns all invoices, newest first. An empty status returns
+// invoices of all statuses.
+func (m *InvoiceViewModel) GetAll(status string) ([]AdminInvoice, error) {
+	stmt := `
+	   SELECT i.id,
+	          coalesce(i.number, ''),
+	          i.user_id,
+	          u.first_name || ' ' || u.last_name,
+	          u.email,
+	          i.status,
+	          coalesce(sum(c.total_price), 0) AS total_price,
+	          i.created_at,
+	          i.sent_at,
+	          i.paid_at,
+	          i.cancelled_at,
+	          coalesce(i.cancellation_reason, ''),
+	          coalesce(cn.number, '')
+	     FROM invoices_v2 i
+	     JOIN users u
+	       ON u.id = i.user_id
+	LEFT JOIN activities a
+	       ON a.invoice_id = i.id
+	LEFT JOIN consumptions c
+	       ON c.activity_id = a.id
+	LEFT JOIN credit_notes cn
+	       ON cn.invoice_id = i.id
+	    WHERE ($1 = '' OR i.status = $1)
+	 GROUP BY i.id, u.id, cn.id
+	 ORDER BY i.created_at DESC
+	;
+	`
+
+	rows, err := m.DB.Query(stmt, status)
+	if err != nil {
+		return nil, fmt.Errorf("DB.Query(stmt): %v", err)
+	}
+
+	defer rows.Close()
+
+	var invoices []AdminInvoice
+
+	for rows.Next() {
+		var i AdminInvoice
+		err = rows.Scan(
+			&i.ID,
+			&i.Number,
+			&i.UserID,
+			&i.UserName,
+			&i.Email,
+			&i.Status,
+			&i.TotalPrice,
+			&i.CreatedAt,
+			&i.SentAt,
+			&i.PaidAt,
+			&i.CancelledAt,
+			&i.CancellationReason,
+			&i.CreditNote,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("for rows.Next(): %v", err)
+		}
+		invoices = append(invoices, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %v", err)
+	}
+
+	return invoices, nil
+}
@@ -4,10 +4,14 @@ import "database/sql"
 
 type Models struct {
 	Activities ActivityViewModel
+	Invoices   InvoiceViewModel
+	Reports    ReportViewModel
 }
 
 func New(db *sql.DB) Models {
 	return Models{
 		Activities: ActivityViewModel{db},
+		Invoices:   InvoiceViewModel{db},
+		Reports:    ReportViewModel{db},
 	}
 }
@@ -0,0 +1,256 @@
+package viewmodels
+
+import (
+	"cmp"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ReportViewModel struct {
+	DB *sql.DB
+}
+
+// TaxPeriod is a quarter (2026-Q1) or a semester (2026-H1) of the MWST
+// return. Until is exclusive.
+type TaxPeriod struct {
+	Name  string
+	From  time.Time
+	Until time.Time
+}
+
+var ErrInvalidTaxPeriod = errors.New("invalid tax period, expected e.g. 2026-Q1 or 2026-H1")
+
+// ParseTaxPeriod parses periods like 2026-Q1 or 2026-H2.
+func ParseTaxPeriod(s string) (TaxPeriod, error) {
+	if len(s) != 7 || s[4] != '-' {
+		return TaxPeriod{}, ErrInvalidTaxPeriod
+	}
+
+	year, err := strconv.Atoi(s[:4])
+	if err != nil {
+		return TaxPeriod{}, ErrInvalidTaxPeriod
+	}
+
+	n := int(s[6] - '0')
+	var months int
+	switch {
+	case s[5] == 'Q' && n >= 1 && n <= 4:
+		months = 3
+	case s[5] == 'H' && n >= 1 && n <= 2:
+		months = 6
+	default:
+		return TaxPeriod{}, ErrInvalidTaxPeriod
+	}
+
+	from := time.Date(year, time.Month((n-1)*months+1), 1, 0, 0, 0, 0, time.Local)
+	return TaxPeriod{
+		Name:  s,
+		From:  from,
+		Until: from.AddDate(0, months, 0),
+	}, nil
+}
+
+// LastTaxPeriod returns the last completed quarter before t, which is
+// usually the one the treasurer has to declare.
+func LastTaxPeriod(t time.Time) TaxPeriod {
+	last := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local).AddDate(0, -3, 0)
+	p, _ := ParseTaxPeriod(fmt.Sprintf("%d-Q%d", last.Year(), (int(last.Month())-1)/3+1))
+	return p
+}
+
+// TaxPeriods lists the quarters and semesters of the given years, newest
+// first, e.g. for a select.
+func TaxPeriods(years ...int) []string {
+	var periods []string
+	for _, year := range years {
+		periods = append(periods,
+			fmt.Sprintf("%d-H2", year),
+			fmt.Sprintf("%d-Q4", year),
+			fmt.Sprintf("%d-Q3", year),
+			fmt.Sprintf("%d-H1", year),
+			fmt.Sprintf("%d-Q2", year),
+			fmt.Sprintf("%d-Q1", year),
+		)
+	}
+	return periods
+}
+
+// MWSTReportRow is the revenue of one financial account for one tax code.
+type MWSTReportRow struct {
+	TaxCode     string
+	AccountCode int
+	AccountName string
+	TaxRate
+}
+
+// MWSTTotal is the revenue of one tax code, as it goes into the return.
+type MWSTTotal struct {
+	TaxCode string
+	TaxRate
+}
+
+type MWSTReport struct {
+	Period TaxPeriod
+	Rows   []MWSTReportRow
+	Totals []MWSTTotal
+	Gross  int
+	VAT    int
+	Net    int
+}
+
+// mwstLine is the revenue of an invoice for one tax code and financial
+// account, with the dates it is booked on.
+type mwstLine struct {
+	MWSTReportRow
+	SentAt     time.Time
+	ReversedAt sql.NullTime // of the cancellation or the credit note
+}
+
+// MWST sums up the revenue of the invoices sent in the period per tax code
+// and financial account. A cancelled invoice stays in the period it was sent
+// in and is booked back as a negative amount in the period of its
+// cancellation or credit note, so a declared period does not change. Drafts
+// are not part of the revenue.
+func (m *ReportViewModel) MWST(p TaxPeriod) (*MWSTReport, error) {
+	stmt := `
+	   SELECT coalesce(t.code, ''),
+	          l.tax_rate,
+	          coalesce(f.code, 0),
+	          f.name,
+	          l.gross,
+	          i.sent_at,
+	          CASE WHEN i.status = 'cancelled'
+	               THEN coalesce(n.created_at, i.cancelled_at)
+	          END AS reversed_at
+	     FROM invoice_tax_lines l
+	     JOIN invoices_v2 i
+	       ON i.id = l.invoice_id
+	     JOIN taxes t
+	       ON t.id = l.tax_id
+	     JOIN financial_accounts f
+	       ON f.id = l.financial_account_id
+	LEFT JOIN credit_notes n
+	       ON n.invoice_id = i.id
+	    WHERE (i.sent_at >= $1 AND i.sent_at < $2)
+	       OR (i.status = 'cancelled'
+	           AND coalesce(n.created_at, i.cancelled_at) >= $1
+	           AND coalesce(n.created_at, i.cancelled_at) <  $2)
+	;
+	`
+
+	rows, err := m.DB.Query(stmt, p.From, p.Until)
+	if err != nil {
+		return nil, fmt.Errorf("DB.Query(stmt): %v", err)
+	}
+
+	defer rows.Close()
+
+	var lines []mwstLine
+
+	for rows.Next() {
+		var l mwstLine
+		err = rows.Scan(
+			&l.TaxCode,
+			&l.Rate,
+			&l.AccountCode,
+			&l.AccountName,
+			&l.Gross,
+			&l.SentAt,
+			&l.ReversedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("for rows.Next(): %v", err)
+		}
+		lines = append(lines, l)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %v", err)
+	}
+
+	report := MWSTReport{
+		Period: p,
+		Rows:   bookMWST(lines, p),
+	}
+	report.Totals = mwstTotals(report.Rows)
+	for _, t := range report.Totals {
+		report.Gross += t.Gross
+		report.VAT += t.VAT
+		report.Net += t.Net
+	}
+
+	return &report, nil
+}
+
+// bookMWST books the lines in the period: the revenue if the invoice was
+// sent in it, the revenue as a negative amount if the invoice was cancelled
+// in it. The rows are ordered by tax code, rate and account.
+func bookMWST(lines []mwstLine, p TaxPeriod) []MWSTReportRow {
+	in := func(t time.Time) bool {
+		return !t.Before(p.From) && t.Before(p.Until)
+	}
+
+	var rows []MWSTReportRow
+	book := func(l mwstLine, gross int) {
+		for i, r := range rows {
+			if r.TaxCode == l.TaxCode && r.Rate == l.Rate && r.AccountCode == l.AccountCode {
+				rows[i].Gross += gross
+				return
+			}
+		}
+		r := l.MWSTReportRow
+		r.Gross = gross
+		rows = append(rows, r)
+	}
+
+	for _, l := range lines {
+		if in(l.SentAt) {
+			book(l, l.Gross)
+		}
+		if l.ReversedAt.Valid && in(l.ReversedAt.Time) {
+			book(l, -l.Gross)
+		}
+	}
+
+	slices.SortFunc(rows, func(a, b MWSTReportRow) int {
+		return cmp.Or(
+			strings.Compare(a.TaxCode, b.TaxCode),
+			cmp.Compare(a.Rate, b.Rate),
+			cmp.Compare(a.AccountCode, b.AccountCode),
+		)
+	})
+
+	for i := range rows {
+		rows[i].VAT = includedVAT(rows[i].Gross, rows[i].Rate)
+		rows[i].Net = rows[i].Gross - rows[i].VAT
+	}
+
+	return rows
+}
+
+// mwstTotals sums up the rows per tax code. rows must be ordered by tax code
+// and rate. The VAT is calculated on the total and not summed up per account,
+// so it can differ by a Rappen from the sum of the rows.
+func mwstTotals(rows []MWSTReportRow) []MWSTTotal {
+	var totals []MWSTTotal
+	for _, r := range rows {
+		n := len(totals)
+		if n == 0 || totals[n-1].TaxCode != r.TaxCode || totals[n-1].Rate != r.Rate {
+			totals = append(totals, MWSTTotal{TaxCode: r.TaxCode, TaxRate: TaxRate{Rate: r.Rate}})
+			n++
+		}
+		totals[n-1].Gross += r.Gross
+	}
+
+	for i := range totals {
+		totals[i].VAT = includedVAT(totals[i].Gross, totals[i].Rate)
+		totals[i].Net = totals[i].Gross - totals[i].VAT
+	}
+
+	return totals
+}
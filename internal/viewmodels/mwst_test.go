@@ -0,0 +1,110 @@
+package viewmodels
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestParseTaxPeriod(t *testing.T) {
+	tests := []struct {
+		in          string
+		from, until string
+	}{
+		{"2026-Q1", "2026-01-01", "2026-04-01"},
+		{"2026-Q4", "2026-10-01", "2027-01-01"},
+		{"2026-H1", "2026-01-01", "2026-07-01"},
+		{"2026-H2", "2026-07-01", "2027-01-01"},
+	}
+
+	for _, tt := range tests {
+		p, err := ParseTaxPeriod(tt.in)
+		if err != nil {
+			t.Fatalf("ParseTaxPeriod(%s): %v", tt.in, err)
+		}
+		if got := p.From.Format(time.DateOnly); got != tt.from {
+			t.Errorf("%s: expected from %s, got %s", tt.in, tt.from, got)
+		}
+		if got := p.Until.Format(time.DateOnly); got != tt.until {
+			t.Errorf("%s: expected until %s, got %s", tt.in, tt.until, got)
+		}
+	}
+
+	for _, in := range []string{"", "2026", "2026-Q5", "2026-H3", "2026-Q0", "26-Q1", "2026/Q1"} {
+		if _, err := ParseTaxPeriod(in); err == nil {
+			t.Errorf("ParseTaxPeriod(%q): expected an error", in)
+		}
+	}
+}
+
+func TestLastTaxPeriod(t *testing.T) {
+	for in, want := range map[string]string{
+		"2026-01-15": "2025-Q4",
+		"2026-05-31": "2026-Q1",
+		"2026-12-31": "2026-Q3",
+	} {
+		d, _ := time.Parse(time.DateOnly, in)
+		if got := LastTaxPeriod(d).Name; got != want {
+			t.Errorf("LastTaxPeriod(%s): expected %s, got %s", in, want, got)
+		}
+	}
+}
+
+func TestMWSTTotals(t *testing.T) {
+	rows := []MWSTReportRow{
+		{TaxCode: "B26", AccountCode: 3000, TaxRate: TaxRate{Rate: 260, Gross: 10000}},
+		{TaxCode: "B81", AccountCode: 3200, TaxRate: TaxRate{Rate: 810, Gross: 5000}},
+		{TaxCode: "B81", AccountCode: 3400, TaxRate: TaxRate{Rate: 810, Gross: 5810}},
+	}
+
+	totals := mwstTotals(rows)
+	if len(totals) != 2 {
+		t.Fatalf("expected 2 totals, got %d", len(totals))
+	}
+	if totals[1].TaxCode != "B81" || totals[1].Gross != 10810 || totals[1].VAT != 810 || totals[1].Net != 10000 {
+		t.Errorf("unexpected total for B81: %+v", totals[1])
+	}
+}
+
+func TestBookMWST(t *testing.T) {
+	q1, _ := ParseTaxPeriod("2026-Q1")
+	q2, _ := ParseTaxPeriod("2026-Q2")
+	day := func(s string) time.Time {
+		d, _ := time.ParseInLocation(time.DateOnly, s, time.Local)
+		return d
+	}
+	line := func(account, gross int, sent string, reversed string) mwstLine {
+		l := mwstLine{
+			MWSTReportRow: MWSTReportRow{TaxCode: "B81", AccountCode: account, TaxRate: TaxRate{Rate: 810, Gross: gross}},
+			SentAt:        day(sent),
+		}
+		if reversed != "" {
+			l.ReversedAt = sql.NullTime{Time: day(reversed), Valid: true}
+		}
+		return l
+	}
+
+	lines := []mwstLine{
+		line(3200, 10810, "2026-02-10", ""),
+		// sent in Q1, cancelled in Q2: declared in Q1, booked back in Q2.
+		line(3200, 5405, "2026-03-20", "2026-04-02"),
+		// sent and cancelled in Q2.
+		line(3400, 2000, "2026-05-01", "2026-05-03"),
+	}
+
+	rows := bookMWST(lines, q1)
+	if len(rows) != 1 || rows[0].AccountCode != 3200 || rows[0].Gross != 16215 {
+		t.Fatalf("Q1: expected 162.15 on 3200, got %+v", rows)
+	}
+
+	rows = bookMWST(lines, q2)
+	if len(rows) != 2 {
+		t.Fatalf("Q2: expected 2 rows, got %+v", rows)
+	}
+	if rows[0].AccountCode != 3200 || rows[0].Gross != -5405 || rows[0].VAT != -405 || rows[0].Net != -5000 {
+		t.Errorf("Q2: expected -54.05 on 3200, got %+v", rows[0])
+	}
+	if rows[1].AccountCode != 3400 || rows[1].Gross != 0 {
+		t.Errorf("Q2: expected 0 on 3400, got %+v", rows[1])
+	}
+}
@@ -0,0 +1,58 @@
+package viewmodels
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// TaxRate is the MWST of an invoice for one rate. Our prices include MWST,
+// so Net and VAT are calculated back from Gross.
+type TaxRate struct {
+	Rate  int // 8.1% => 810
+	Net   int
+	VAT   int
+	Gross int
+}
+
+// Percent formats the rate without the percent sign, e.g. 810 => "8.1".
+func (t TaxRate) Percent() string {
+	s := fmt.Sprintf("%d.%02d", t.Rate/100, t.Rate%100)
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+// includedVAT returns the VAT contained in gross, rounded to Rappen. A
+// negative gross, e.g. a cancellation, is rounded like its positive amount.
+func includedVAT(gross, rate int) int {
+	if gross < 0 {
+		return -includedVAT(-gross, rate)
+	}
+	d := 10000 + rate
+	return (2*gross*rate + d) / (2 * d)
+}
+
+// taxBreakdown sums up the consumptions per tax rate, lowest rate first.
+// The VAT is calculated per rate and not per consumption, as it appears on
+// the invoice.
+func (acs activityConsumptions) taxBreakdown() []TaxRate {
+	gross := make(map[int]int)
+	for _, ac := range acs {
+		gross[ac.taxRate] += ac.total_price
+	}
+
+	taxes := make([]TaxRate, 0, len(gross))
+	for rate, g := range gross {
+		vat := includedVAT(g, rate)
+		taxes = append(taxes, TaxRate{
+			Rate:  rate,
+			Net:   g - vat,
+			VAT:   vat,
+			Gross: g,
+		})
+	}
+	slices.SortFunc(taxes, func(a, b TaxRate) int {
+		return a.Rate - b.Rate
+	})
+
+	return taxes
+}
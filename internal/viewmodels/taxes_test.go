@@ -0,0 +1,42 @@
+package viewmodels
+
+import "testing"
+
+func TestTaxBreakdown(t *testing.T) {
+	acs := activityConsumptions{
+		{total_price: 1100, taxRate: 810},
+		{total_price: 350, taxRate: 810},
+		{total_price: 4000, taxRate: 380},
+		{total_price: 2000, taxRate: 0},
+	}
+
+	want := []TaxRate{
+		{Rate: 0, Net: 2000, VAT: 0, Gross: 2000},
+		{Rate: 380, Net: 3854, VAT: 146, Gross: 4000},
+		{Rate: 810, Net: 1341, VAT: 109, Gross: 1450},
+	}
+
+	got := acs.taxBreakdown()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d rates, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("rate %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestTaxRatePercent(t *testing.T) {
+	for rate, want := range map[int]string{
+		810:  "8.1",
+		260:  "2.6",
+		0:    "0",
+		1000: "10",
+		775:  "7.75",
+	} {
+		if got := (TaxRate{Rate: rate}).Percent(); got != want {
+			t.Errorf("Percent(%d): expected %s, got %s", rate, want, got)
+		}
+	}
+}
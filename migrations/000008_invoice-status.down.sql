@@ -0,0 +1,12 @@
+begin;
+
+set role developer;
+
+drop table invoice_status_changes;
+
+alter table invoices_v2
+drop column sent_at,
+drop column paid_at,
+drop column cancelled_at;
+
+commit;
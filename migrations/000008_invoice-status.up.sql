@@ -0,0 +1,29 @@
+begin;
+
+set role developer;
+
+-- one timestamp per transition, see InvoiceV2Model.SetStatusTx:
+-- draft -> sent -> paid
+--       \-> cancelled <-/
+alter table invoices_v2
+add column sent_at      timestamptz,
+add column paid_at      timestamptz,
+add column cancelled_at timestamptz;
+
+-- changed_by is null if the system made the change, e.g. cmd/email after
+-- the email was sent successfully.
+create table bellevue.invoice_status_changes (
+	id          int generated by default as identity primary key,
+	invoice_id  int not null
+	            references invoices_v2(id),
+	from_status text not null,
+	to_status   text not null,
+	changed_by  int
+	            references users(id),
+
+	created_at  timestamptz not null default now()
+);
+
+create index on bellevue.invoice_status_changes (invoice_id);
+
+commit;
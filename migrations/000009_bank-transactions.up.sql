@@ -0,0 +1,37 @@
+begin;
+
+set role developer;
+
+-- credit entries imported from camt.053 / camt.054 files.
+-- reference: AcctSvcrRef of the bank, used to skip entries that were
+-- already imported.
+-- matched_by is null if the entry was matched automatically.
+create table bellevue.bank_transactions (
+	id            int generated by default as identity primary key,
+	iban          text not null,
+	reference     text not null unique,
+	booking_date  date not null,
+	amount        int not null, -- 48.50 CHF => 4850
+	currency      text not null,
+	debtor_name   text,
+	creditor_ref  text,
+	message       text,
+	status        text not null default 'unmatched'
+	              check (status in ('unmatched', 'matched', 'ignored')),
+	invoice_id    int
+	              references invoices_v2(id),
+	              check (
+	                (status = 'matched' and invoice_id is not null) or
+	                (status <> 'matched' and invoice_id is null)
+	              ),
+	matched_by    int
+	              references users(id),
+
+	created_at    timestamptz not null default now(),
+	updated_at    timestamptz not null default now()
+);
+
+create index on bellevue.bank_transactions (status);
+create index on bellevue.bank_transactions (invoice_id);
+
+commit;
@@ -0,0 +1,8 @@
+begin;
+
+set role developer;
+
+alter table invoices_v2
+drop column reference;
+
+commit;
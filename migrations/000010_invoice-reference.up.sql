@@ -0,0 +1,23 @@
+begin;
+
+set role developer;
+
+-- ISO 11649 creditor reference, derived from the invoice id, e.g.
+-- id 42 => RF340000000042
+--
+-- RF + 2 check digits + id padded to 10 digits. The check digits are
+-- computed as for an IBAN: move "RF00" to the end, replace R=27 F=15,
+-- check = 98 - (number mod 97).
+-- See qrbill.CreditorReference for the same in Go.
+alter table invoices_v2
+add column reference text
+generated always as (
+	'RF'
+	|| lpad((98 - ((lpad(id::text, 10, '0') || '271500')::numeric % 97))::text, 2, '0')
+	|| lpad(id::text, 10, '0')
+) stored;
+
+alter table invoices_v2
+add constraint invoices_v2_reference_key unique (reference);
+
+commit;
@@ -0,0 +1,10 @@
+begin;
+
+set role developer;
+
+alter table invoices_v2
+drop column number;
+
+drop table bellevue.invoice_number_sequences;
+
+commit;
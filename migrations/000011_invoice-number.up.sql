@@ -0,0 +1,23 @@
+begin;
+
+set role developer;
+
+-- gapless invoice numbers per year, e.g. BV-2026-0042.
+--
+-- The serial id of invoices_v2 has gaps whenever a transaction rolls back.
+-- The number is taken from this table in the same transaction that issues
+-- the invoice, see InvoiceV2Model.AssignNumberTx. The row lock serializes
+-- concurrent runs and a rollback gives the number back.
+create table bellevue.invoice_number_sequences (
+	year       int primary key,
+	last_value int not null
+);
+
+-- null as long as the invoice is a draft, assigned when it is sent.
+alter table invoices_v2
+add column number text;
+
+alter table invoices_v2
+add constraint invoices_v2_number_key unique (number);
+
+commit;
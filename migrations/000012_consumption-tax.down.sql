@@ -0,0 +1,9 @@
+begin;
+
+set role developer;
+
+alter table consumptions
+drop column tax_id,
+drop column tax_rate;
+
+commit;
@@ -0,0 +1,26 @@
+begin;
+
+set role developer;
+
+-- Every consumption keeps the tax it was sold with. tax_rate is a snapshot
+-- of taxes.mwst_satz (8.1% => 810), so that a later change of the rate does
+-- not change issued invoices. See ConsumptionModel.InsertManyWithTransaction.
+alter table consumptions
+add column tax_id   int
+                    references taxes(id),
+add column tax_rate smallint
+                    check (tax_rate between 0 and 10000);
+
+update consumptions c
+   set tax_id   = t.id,
+       tax_rate = t.mwst_satz
+  from products p
+  join taxes t
+    on t.id = p.tax_id
+ where p.id = c.product_id;
+
+alter table consumptions
+alter column tax_id   set not null,
+alter column tax_rate set not null;
+
+commit;
@@ -0,0 +1,22 @@
+begin;
+
+set role developer;
+
+drop table bellevue.credit_notes;
+
+alter table invoices_v2
+drop column cancellation_reason;
+
+delete from invoice_number_sequences
+ where prefix <> 'BV';
+
+alter table invoice_number_sequences
+drop constraint invoice_number_sequences_pkey;
+
+alter table invoice_number_sequences
+drop column prefix;
+
+alter table invoice_number_sequences
+add primary key (year);
+
+commit;
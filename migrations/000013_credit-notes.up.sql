@@ -0,0 +1,35 @@
+begin;
+
+set role developer;
+
+-- credit notes are numbered like invoices, but in their own sequence,
+-- e.g. GS-2026-0001. See InvoiceV2Model.nextNumberTx.
+alter table invoice_number_sequences
+add column prefix text not null default 'BV';
+
+alter table invoice_number_sequences
+drop constraint invoice_number_sequences_pkey;
+
+alter table invoice_number_sequences
+add primary key (prefix, year);
+
+alter table invoices_v2
+add column cancellation_reason text;
+
+-- A credit note reverses a cancelled invoice in full. Its activities stay
+-- assigned to the invoice, so they are not invoiced again.
+create table bellevue.credit_notes (
+	id          int generated by default as identity primary key,
+	invoice_id  int not null unique
+	            references invoices_v2(id),
+	number      text not null unique,
+	amount      int not null
+	            check (amount >= 0),
+	reason      text not null,
+	created_by  int
+	            references users(id),
+
+	created_at  timestamptz not null default now()
+);
+
+commit;
@@ -0,0 +1,9 @@
+begin;
+
+set role developer;
+
+drop table bellevue.payment_allocations;
+
+drop table bellevue.payments;
+
+commit;
@@ -0,0 +1,101 @@
+begin;
+
+set role developer;
+
+-- Money received from a member. A payment is allocated to the open
+-- invoices of the member, oldest first, see PaymentModel.AllocateTx. What is
+-- left over is credit for the next invoice.
+--
+-- method: bank for imported bank transactions, manual for payments that an
+-- admin recorded, e.g. cash.
+create table bellevue.payments (
+	id                  int generated by default as identity primary key,
+	user_id             int not null
+	                    references users(id),
+	amount              int not null
+	                    check (amount > 0),
+	booking_date        date not null default current_date,
+	method              text not null
+	                    check (method in ('bank', 'manual')),
+	bank_transaction_id int unique
+	                    references bank_transactions(id),
+	created_by          int
+	                    references users(id),
+
+	created_at          timestamptz not null default now()
+);
+
+create index on bellevue.payments (user_id);
+
+create table bellevue.payment_allocations (
+	id          int generated by default as identity primary key,
+	payment_id  int not null
+	            references payments(id),
+	invoice_id  int not null
+	            references invoices_v2(id),
+	amount      int not null
+	            check (amount > 0),
+
+	created_at  timestamptz not null default now()
+);
+
+create index on bellevue.payment_allocations (payment_id);
+create index on bellevue.payment_allocations (invoice_id);
+
+-- backfill: invoices that were marked as paid before, either by a matched
+-- bank transaction or by an admin. Each payment pays exactly its invoice.
+do $$
+declare
+	t              record;
+	new_payment_id int;
+begin
+	for t in
+		   select i.id,
+		          i.user_id,
+		          coalesce(i.paid_at, i.updated_at)::date as paid_at,
+		          coalesce(sum(c.total_price), 0) as total,
+		          b.id as bank_transaction_id,
+		          b.amount as bank_amount,
+		          b.booking_date,
+		          b.matched_by
+		     from invoices_v2 i
+		left join activities a
+		       on a.invoice_id = i.id
+		left join consumptions c
+		       on c.activity_id = a.id
+		left join bank_transactions b
+		       on b.invoice_id = i.id
+		      and b.status = 'matched'
+		    where i.status = 'paid'
+		 group by i.id, b.id
+	loop
+		if t.bank_transaction_id is not null then
+			insert into payments (
+				user_id, amount, booking_date, method, bank_transaction_id, created_by
+			) values (
+				t.user_id, t.bank_amount, t.booking_date, 'bank', t.bank_transaction_id, t.matched_by
+			)
+			returning id into new_payment_id;
+		elsif t.total > 0 then
+			insert into payments (
+				user_id, amount, booking_date, method
+			) values (
+				t.user_id, t.total, t.paid_at, 'manual'
+			)
+			returning id into new_payment_id;
+		else
+			continue;
+		end if;
+
+		if least(t.total, coalesce(t.bank_amount, t.total)) > 0 then
+			insert into payment_allocations (
+				payment_id, invoice_id, amount
+			) values (
+				new_payment_id, t.id, least(t.total, coalesce(t.bank_amount, t.total))
+			);
+		end if;
+	end loop;
+end
+$$;
+
+commit;
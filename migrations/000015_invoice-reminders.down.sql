@@ -0,0 +1,7 @@
+begin;
+
+set role developer;
+
+drop table bellevue.invoice_reminders;
+
+commit;
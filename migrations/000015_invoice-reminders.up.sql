@@ -0,0 +1,21 @@
+begin;
+
+set role developer;
+
+-- Payment reminders (Mahnungen) sent for an invoice. level is the dunning
+-- level, 1 for the friendly reminder, 2 for the second one and so on. Every
+-- level is sent at most once per invoice.
+create table bellevue.invoice_reminders (
+	id         int generated by default as identity primary key,
+	invoice_id int not null
+	           references invoices_v2(id),
+	level      smallint not null
+	           check (level > 0),
+	amount     int not null,
+
+	sent_at    timestamptz not null default now(),
+
+	unique (invoice_id, level)
+);
+
+commit;
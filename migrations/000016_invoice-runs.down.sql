@@ -0,0 +1,9 @@
+begin;
+
+set role developer;
+
+drop table bellevue.invoice_run_items;
+
+drop table bellevue.invoice_runs;
+
+commit;
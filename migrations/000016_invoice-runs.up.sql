@@ -0,0 +1,37 @@
+begin;
+
+set role developer;
+
+-- One execution of cmd/email. mode is the -mode flag, e.g. month=2026-03.
+create table bellevue.invoice_runs (
+	id          int generated by default as identity primary key,
+	mode        text not null,
+	sent        int not null default 0,
+	failed      int not null default 0,
+
+	started_at  timestamptz not null default now(),
+	finished_at timestamptz
+);
+
+-- The send status of every invoice that a run created. An invoice is created
+-- once, pending and failed ones are retried by the next run.
+create table bellevue.invoice_run_items (
+	id          int generated by default as identity primary key,
+	run_id      int not null
+	            references invoice_runs(id),
+	invoice_id  int not null unique
+	            references invoices_v2(id),
+	user_id     int not null
+	            references users(id),
+	send_status text not null default 'pending'
+	            check (send_status in ('pending', 'sent', 'failed')),
+	error       text,
+	attempts    int not null default 0,
+
+	created_at  timestamptz not null default now(),
+	updated_at  timestamptz not null default now()
+);
+
+create index on bellevue.invoice_run_items (send_status);
+
+commit;
@@ -0,0 +1,9 @@
+begin;
+
+set role developer;
+
+drop table bellevue.email_delivery_attempts;
+
+drop table bellevue.email_outbox;
+
+commit;
@@ -0,0 +1,45 @@
+begin;
+
+set role developer;
+
+-- Emails are written to the outbox in the transaction of what they are
+-- about, and delivered by a worker in the web process. body is the complete
+-- message including the headers.
+--
+-- kind: invoice emails mark their invoice as sent once they are delivered.
+-- They are rendered only then, because the invoice gets its number when it
+-- is sent, and subject and body are filled in at delivery.
+create table bellevue.email_outbox (
+	id              int generated by default as identity primary key,
+	kind            text not null
+	                check (kind in ('invoice', 'cancellation', 'reminder')),
+	recipient       text not null,
+	subject         text not null,
+	body            bytea not null,
+	invoice_id      int
+	                references invoices_v2(id),
+	status          text not null default 'pending'
+	                check (status in ('pending', 'sent', 'failed')),
+	attempts        int not null default 0,
+	next_attempt_at timestamptz not null default now(),
+	last_error      text,
+
+	created_at      timestamptz not null default now(),
+	sent_at         timestamptz
+);
+
+create index on bellevue.email_outbox (status, next_attempt_at);
+
+-- error is null for the successful attempt.
+create table bellevue.email_delivery_attempts (
+	id           int generated by default as identity primary key,
+	message_id   int not null
+	             references email_outbox(id),
+	error        text,
+
+	attempted_at timestamptz not null default now()
+);
+
+create index on bellevue.email_delivery_attempts (message_id);
+
+commit;
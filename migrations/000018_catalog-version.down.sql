@@ -0,0 +1,15 @@
+begin;
+
+set role developer;
+
+drop trigger bump_catalog_version on bellevue.product_form_order;
+
+drop trigger bump_catalog_version on bellevue.price_categories;
+
+drop trigger bump_catalog_version on bellevue.products;
+
+drop function bellevue.bump_catalog_version();
+
+drop table bellevue.catalog_version;
+
+commit;
@@ -0,0 +1,40 @@
+begin;
+
+set role developer;
+
+-- The web process caches the product form and the price categories. Every
+-- change to the catalog bumps the version, the web process polls it and
+-- reloads its cache when it changed.
+create table bellevue.catalog_version (
+	id         bool primary key default true
+	           check (id),
+	version    bigint not null default 0,
+
+	changed_at timestamptz not null default now()
+);
+
+insert into bellevue.catalog_version default values;
+
+create function bellevue.bump_catalog_version() returns trigger
+language plpgsql as $$
+begin
+	update bellevue.catalog_version
+	   set version = version + 1,
+	       changed_at = now();
+	return null;
+end;
+$$;
+
+create trigger bump_catalog_version
+after insert or update or delete or truncate on bellevue.products
+for each statement execute function bellevue.bump_catalog_version();
+
+create trigger bump_catalog_version
+after insert or update or delete or truncate on bellevue.price_categories
+for each statement execute function bellevue.bump_catalog_version();
+
+create trigger bump_catalog_version
+after insert or update or delete or truncate on bellevue.product_form_order
+for each statement execute function bellevue.bump_catalog_version();
+
+commit;
@@ -0,0 +1,10 @@
+begin;
+
+set role developer;
+
+drop table bellevue.user_price_categories;
+
+alter table bellevue.users
+drop column default_price_category_id;
+
+commit;
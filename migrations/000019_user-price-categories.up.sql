@@ -0,0 +1,24 @@
+begin;
+
+set role developer;
+
+-- The price category that is checked in the activity form of the user, e.g.
+-- reduced for students. null checks the regular price.
+alter table bellevue.users
+add column default_price_category_id int
+           references price_categories(id);
+
+-- The price categories an admin allows the user to pick. A user without rows
+-- may pick all of them.
+create table bellevue.user_price_categories (
+	user_id           int not null
+	                  references users(id),
+	price_category_id int not null
+	                  references price_categories(id),
+
+	created_at        timestamptz not null default now(),
+
+	primary key (user_id, price_category_id)
+);
+
+commit;
@@ -0,0 +1,10 @@
+begin;
+
+set role developer;
+
+alter table bellevue.users
+drop column household_id;
+
+drop table bellevue.households;
+
+commit;
@@ -0,0 +1,24 @@
+begin;
+
+set role developer;
+
+-- Members of a household, e.g. a couple or a family, get one invoice. It is
+-- addressed to the billing contact and lists the activities of all members.
+-- Members without a household are invoiced on their own.
+create table bellevue.households (
+	id              int generated by default as identity primary key,
+	name            text not null,
+	billing_user_id int not null
+	                references users(id),
+
+	created_at      timestamptz not null default now(),
+	updated_at      timestamptz not null default now()
+);
+
+alter table bellevue.users
+add column household_id int
+           references households(id);
+
+create index on bellevue.users (household_id);
+
+commit;
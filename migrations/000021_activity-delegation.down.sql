@@ -0,0 +1,14 @@
+begin;
+
+set role developer;
+
+alter table bellevue.activities
+drop column guest_name;
+
+alter table bellevue.activities
+drop column entered_by;
+
+alter table bellevue.users
+drop column role;
+
+commit;
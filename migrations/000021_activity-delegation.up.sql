@@ -0,0 +1,26 @@
+begin;
+
+set role developer;
+
+-- staff, e.g. the kitchen, may enter activities for any member. The admin is
+-- still user 1.
+alter table bellevue.users
+add column role text not null default 'member'
+           check (role in ('member', 'staff'));
+
+-- who entered the activity: the user themselves, or someone on their behalf.
+alter table bellevue.activities
+add column entered_by int
+           references users(id);
+
+update bellevue.activities
+   set entered_by = user_id;
+
+alter table bellevue.activities
+alter column entered_by set not null;
+
+-- a visitor without a login, whose activity is billed to the user.
+alter table bellevue.activities
+add column guest_name text;
+
+commit;
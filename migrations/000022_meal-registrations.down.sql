@@ -0,0 +1,7 @@
+begin;
+
+set role developer;
+
+drop table bellevue.meal_registrations;
+
+commit;
@@ -0,0 +1,34 @@
+begin;
+
+set role developer;
+
+-- Members sign up in advance for a meal, so the kitchen knows how many to
+-- cook. The meal is the code of its product, e.g. lunch. After the day, the
+-- registrations become activities, see converted_at.
+create table bellevue.meal_registrations (
+	id                int generated by default as identity primary key,
+	user_id           int not null
+	                  references users(id),
+	date              date not null,
+	meal              text not null
+	                  check (meal in ('breakfast', 'lunch', 'dinner')),
+	price_category_id int not null
+	                  references price_categories(id),
+	-- dietary notes, e.g. vegan or no nuts
+	notes             text,
+	-- when the registration became an activity. A member may correct or
+	-- delete the activity, the registration stays converted.
+	converted_at      timestamptz,
+	activity_id       int
+	                  references activities(id)
+	                  on delete set null,
+
+	created_at        timestamptz not null default now(),
+	updated_at        timestamptz not null default now(),
+
+	unique (user_id, date, meal)
+);
+
+create index on bellevue.meal_registrations (date);
+
+commit;
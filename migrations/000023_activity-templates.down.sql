@@ -0,0 +1,11 @@
+begin;
+
+set role developer;
+
+drop table bellevue.activity_template_runs;
+
+drop table bellevue.activity_template_items;
+
+drop table bellevue.activity_templates;
+
+commit;
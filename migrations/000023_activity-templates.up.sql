@@ -0,0 +1,55 @@
+begin;
+
+set role developer;
+
+-- What a member logs nearly every day, e.g. breakfast, lunch and two coffees,
+-- to prefill the activity form. A recurring template proposes a draft
+-- activity on the days of its rule, which the member confirms or dismisses.
+create table bellevue.activity_templates (
+	id         int generated by default as identity primary key,
+	user_id    int not null
+	           references users(id),
+	name       text not null,
+	recurrence text
+	           check (recurrence in ('daily', 'weekdays')),
+
+	created_at timestamptz not null default now(),
+	updated_at timestamptz not null default now()
+);
+
+create index on bellevue.activity_templates (user_id);
+
+-- The products by code, so that a template keeps the prices valid on the day
+-- it is used.
+create table bellevue.activity_template_items (
+	template_id       int not null
+	                  references activity_templates(id)
+	                  on delete cascade,
+	product_code      text not null,
+	-- null for custom amounts
+	price_category_id int
+	                  references price_categories(id),
+	quantity          int not null default 1,
+	-- in Rappen, for custom amounts
+	amount            int not null default 0
+);
+
+create index on bellevue.activity_template_items (template_id);
+
+-- The days a recurring template was confirmed, with its activity, or
+-- dismissed, without one.
+create table bellevue.activity_template_runs (
+	template_id int not null
+	            references activity_templates(id)
+	            on delete cascade,
+	date        date not null,
+	activity_id int
+	            references activities(id)
+	            on delete set null,
+
+	created_at  timestamptz not null default now(),
+
+	primary key (template_id, date)
+);
+
+commit;
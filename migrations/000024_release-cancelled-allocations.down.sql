@@ -0,0 +1,8 @@
+begin;
+
+set role developer;
+
+-- the released allocations are not restored, they paid invoices that are
+-- cancelled.
+
+commit;
@@ -0,0 +1,12 @@
+begin;
+
+set role developer;
+
+-- payments allocated to invoices that were cancelled since are credit
+-- again, as InvoiceV2Model.CancelTx does from now on.
+delete from bellevue.payment_allocations pa
+ using bellevue.invoices_v2 i
+ where i.id = pa.invoice_id
+   and i.status = 'cancelled';
+
+commit;
@@ -0,0 +1,44 @@
+begin;
+
+set role developer;
+
+-- The revenue of an invoice per tax and financial account, written when the
+-- invoice is sent, see InvoiceV2Model.SetStatusTx. The MWST report books it
+-- in the period the invoice was sent in and, if it is cancelled, books it
+-- back in the period of the cancellation. A cancellation can release the
+-- activities of the invoice, so they cannot tell any more what was invoiced.
+create table bellevue.invoice_tax_lines (
+	invoice_id           int not null
+	                     references invoices_v2(id),
+	tax_id               int not null
+	                     references taxes(id),
+	tax_rate             int not null,
+	financial_account_id int not null
+	                     references financial_accounts(id),
+	gross                int not null,
+
+	primary key (invoice_id, tax_id, tax_rate, financial_account_id)
+);
+
+-- invoices that were cancelled with their activities released before this
+-- migration have nothing left to book back.
+insert into bellevue.invoice_tax_lines (
+       invoice_id, tax_id, tax_rate, financial_account_id, gross
+)
+select a.invoice_id,
+       c.tax_id,
+       c.tax_rate,
+       p.financial_account_id,
+       sum(c.total_price)
+  from bellevue.activities a
+  join bellevue.invoices_v2 i
+    on i.id = a.invoice_id
+  join bellevue.consumptions c
+    on c.activity_id = a.id
+  join bellevue.products p
+    on p.id = c.product_id
+ where i.sent_at is not null
+   and p.financial_account_id is not null
+ group by a.invoice_id, c.tax_id, c.tax_rate, p.financial_account_id;
+
+commit;